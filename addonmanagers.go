@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// addon managers whose update sessions can trigger a sync, by the folder their app data lives in
+// both are electron apps, so that's under the OS's per-user config folder and they keep their logs in it
+var _addonManagers = map[string]string{
+	"WowUp":      "WowUp",
+	"CurseForge": "CurseForge",
+}
+
+// how often the addon managers' logs are checked
+const addonManagerPollInterval = 5 * time.Second
+
+// an update session counts as finished once the manager has stopped writing its logs for this long
+const addonManagerQuietPeriod = 30 * time.Second
+
+// an addon manager found on this machine
+type addonManager struct {
+	name string
+	logs string
+}
+
+// the addon managers that are installed, i.e. whose log folders exist
+func findAddonManagers() []addonManager {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	var managers []addonManager
+	for name, folder := range _addonManagers {
+		logs := filepath.Join(configDir, folder, "logs")
+		if info, err := os.Stat(logs); err == nil && info.IsDir() {
+			managers = append(managers, addonManager{name, logs})
+		}
+	}
+	sort.Slice(managers, func(i, j int) bool { return managers[i].name < managers[j].name })
+	return managers
+}
+
+// when the manager last wrote any of its logs
+func (m addonManager) lastActivity() time.Time {
+	var latest time.Time
+	filepath.WalkDir(m.logs, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest
+}
+
+// runs syncs every time one of managers finishes an update session, until the process is stopped
+// anything logged before watching started is treated as an earlier session that was already synced
+func (t *syncTrigger) syncAfterAddonUpdates(managers []addonManager, syncs []string) {
+	var names []string
+	seen := make(map[string]time.Time)
+	for _, manager := range managers {
+		names = append(names, manager.name)
+		seen[manager.name] = manager.lastActivity()
+	}
+	pterm.Info.Printfln("Running %s after %s update addons", strings.Join(syncs, ", "), strings.Join(names, " or "))
+
+	for range time.Tick(addonManagerPollInterval) {
+		for _, manager := range managers {
+			activity := manager.lastActivity()
+			if !activity.After(seen[manager.name]) || time.Since(activity) < addonManagerQuietPeriod {
+				continue
+			}
+			seen[manager.name] = activity
+			pterm.Info.Printfln("%s finished updating addons", manager.name)
+			for _, name := range syncs {
+				t.trigger(name)
+			}
+		}
+	}
+}
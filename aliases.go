@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expands an @alias in a --src or --dst value into the version/account/server/character it stands for
+// @last-src and @last-dst are the most recent sync's, @template is the template setting, anything else comes from aliases
+// values that don't start with @ are returned as they are
+func expandTargetAlias(spec string, config Config) (string, error) {
+	if !strings.HasPrefix(spec, "@") {
+		return spec, nil
+	}
+	name := strings.TrimPrefix(spec, "@")
+
+	switch name {
+	case "last-src", "last-dst":
+		manifests, err := readSyncManifests()
+		if err != nil {
+			return "", err
+		}
+		if len(manifests) == 0 {
+			return "", fmt.Errorf("%s needs an earlier copy to refer to, and nothing has been copied yet", spec)
+		}
+		if name == "last-src" {
+			return manifests[0].Source.String(), nil
+		}
+		return manifests[0].Destination.String(), nil
+	case "template":
+		if config.Template == "" {
+			return "", fmt.Errorf("@template needs template set in config.yaml")
+		}
+		return config.Template, nil
+	}
+
+	target, ok := config.Aliases[name]
+	if !ok {
+		return "", fmt.Errorf("there's no alias named %s, add it under aliases in config.yaml", spec)
+	}
+	return target, nil
+}
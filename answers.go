@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// set by --answers, every prompt is answered from it instead of the terminal
+var _answers *scriptedAnswers
+
+// prompt answers given up front, either by prompt name from a YAML file or one line per prompt from stdin
+type scriptedAnswers struct {
+	source   string
+	byPrompt map[string]scriptedAnswer
+	lines    *bufio.Reader
+}
+
+// a single answer, or a list of them for prompts that pick several options
+type scriptedAnswer []string
+
+func (a *scriptedAnswer) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*a = values
+		return nil
+	}
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	*a = scriptedAnswer{value}
+	return nil
+}
+
+// reads answers from path, "-" answers prompts in order from the lines on stdin
+func loadScriptedAnswers(path string) (*scriptedAnswers, error) {
+	if path == "-" {
+		return &scriptedAnswers{source: "stdin", lines: bufio.NewReader(os.Stdin)}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	answers := &scriptedAnswers{source: path}
+	if err := yaml.Unmarshal(data, &answers.byPrompt); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return answers, nil
+}
+
+// the answer to the prompt named key, text is what the prompt would have shown
+// on stdin the prompt text is echoed to stderr first, so expect-style scripts have something to wait for
+func (a *scriptedAnswers) answer(key string, text string) scriptedAnswer {
+	if a.lines == nil {
+		answer, ok := a.byPrompt[key]
+		if !ok {
+			fatalf("%s has no answer for %s (%q)", a.source, key, firstLine(text))
+		}
+		return answer
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [%s]: ", firstLine(text), key)
+	line, err := a.lines.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		fatalf("stdin ran out of answers at %s (%q)", key, firstLine(text))
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		fatal(err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil
+	}
+	// several options on one line are comma separated
+	var answer scriptedAnswer
+	for _, value := range strings.Split(line, ",") {
+		answer = append(answer, strings.TrimSpace(value))
+	}
+	return answer
+}
+
+// finds the option an answer means: an exact match, then ignoring case, then the only option it starts
+func matchScriptedOption(key string, value string, options []string) string {
+	for _, option := range options {
+		if option == value {
+			return option
+		}
+	}
+	for _, option := range options {
+		if strings.EqualFold(option, value) {
+			return option
+		}
+	}
+	var matches []string
+	for _, option := range options {
+		if strings.HasPrefix(strings.ToLower(option), strings.ToLower(value)) {
+			matches = append(matches, option)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	fatalf("%q is not an option for %s, expected one of:\n%s", value, key, strings.Join(options, "\n"))
+	return ""
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+// fails the prompt named key when there's nobody to answer it, e.g. in a batch job's copy, instead of waiting on
+// input that never comes
+func requireTerminal(key string, text string) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fatalf("Can't ask %q (%s) without a terminal, pass it as a flag or answer it with --answers", firstLine(text), key)
+	}
+}
+
+// shows printer, or answers it from --answers under key
+func askSelect(key string, printer *pterm.InteractiveSelectPrinter) string {
+	if _answers == nil {
+		requireTerminal(key, printer.DefaultText)
+		choice, _ := printer.Show()
+		return choice
+	}
+	answer := _answers.answer(key, printer.DefaultText)
+	if len(answer) == 0 {
+		if printer.DefaultOption != "" {
+			return printer.DefaultOption
+		}
+		return printer.Options[0]
+	}
+	return matchScriptedOption(key, answer[0], printer.Options)
+}
+
+func askMultiselect(key string, printer *pterm.InteractiveMultiselectPrinter) []string {
+	if _answers == nil {
+		requireTerminal(key, printer.DefaultText)
+		choices, _ := printer.Show()
+		return choices
+	}
+	var choices []string
+	for _, value := range _answers.answer(key, printer.DefaultText) {
+		choices = append(choices, matchScriptedOption(key, value, printer.Options))
+	}
+	return choices
+}
+
+func askText(key string, printer *pterm.InteractiveTextInputPrinter) string {
+	if _answers == nil {
+		requireTerminal(key, printer.DefaultText)
+		text, _ := printer.Show()
+		return text
+	}
+	return strings.Join(_answers.answer(key, printer.DefaultText), ",")
+}
+
+// yes/no answers also accept y/n and true/false, an empty one takes the prompt's default
+func askConfirm(key string, printer *pterm.InteractiveConfirmPrinter) bool {
+	if _answers == nil {
+		requireTerminal(key, printer.DefaultText)
+		confirmed, _ := printer.Show()
+		return confirmed
+	}
+	answer := _answers.answer(key, printer.DefaultText)
+	if len(answer) == 0 {
+		return printer.DefaultValue
+	}
+	switch strings.ToLower(answer[0]) {
+	case "y", "yes", "true":
+		return true
+	case "n", "no", "false":
+		return false
+	}
+	fatalf("%q is not an answer for %s, expected yes or no", answer[0], key)
+	return false
+}
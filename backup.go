@@ -0,0 +1,26 @@
+package main
+
+import (
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// creates a Copier whose backups land in a fresh timestamped directory under backupsDir
+func newCopier(opts copyplan.CopyOptions, installDirectory string) (*copyplan.Copier, error) {
+	dir, err := backupsDir()
+	if err != nil {
+		return copyplan.NewCopier(opts, installDirectory, ""), err
+	}
+	return copyplan.NewCopier(opts, installDirectory, dir), nil
+}
+
+// backs up every file in target's account and character folders and their SavedVariables, returning where the
+// backup went and how many files are in it
+func (wow WowInstall) backupTarget(target wtf.Target, opts copyplan.CopyOptions) (string, int, error) {
+	copier, err := newCopier(opts, wow.Dir)
+	if err != nil {
+		return "", 0, err
+	}
+	count, err := copier.SnapshotTarget(wow.Install, target)
+	return copier.BackupDirectory, count, err
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+// what a job does, each as the command of the same name would
+const (
+	operationBackup  = "backup"
+	operationCopy    = "copy"
+	operationRewrite = "rewrite"
+)
+
+// the operations in the order batch runs them: every backup finishes before the first copy starts, and every copy
+// before the first rewrite, so nothing is copied over before it's backed up or rewritten before it's copied
+var _jobOperations = []string{operationBackup, operationCopy, operationRewrite}
+
+// a file of jobs to run together, e.g. "sync my alts" after every UI change, YAML or JSON
+type jobFile struct {
+	InstallDir string `yaml:"install_dir"`
+	Jobs       []job  `yaml:"jobs"`
+
+	// how many jobs run at once, overall and per operation, 1 (one after the other) when left out
+	Concurrency          int            `yaml:"concurrency"`
+	OperationConcurrency map[string]int `yaml:"operation_concurrency"`
+}
+
+// one job of a job file, as if its values were passed as flags to the command its operation names
+type job struct {
+	Name       string   `yaml:"name"`
+	Operation  string   `yaml:"operation"`
+	InstallDir string   `yaml:"install_dir"`
+	Src        string   `yaml:"src"`
+	Dst        []string `yaml:"dst"`
+	Only       []string `yaml:"only"`
+	Include    []string `yaml:"include"`
+	Exclude    []string `yaml:"exclude"`
+
+	// rewrite's
+	Dir       string `yaml:"dir"`
+	From      string `yaml:"from"`
+	To        string `yaml:"to"`
+	FromRealm string `yaml:"from_realm"`
+	ToRealm   string `yaml:"to_realm"`
+}
+
+// how one job of a batch went, skipped says why it never ran
+type jobResult struct {
+	job     job
+	took    time.Duration
+	err     error
+	skipped string
+}
+
+// reads and checks a job file, jobs without a name are named by their place in it, and without an operation copy
+func readJobFile(path string) (jobFile, error) {
+	var file jobFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := validateDocument(data, _jobSchema); err != nil {
+		return file, fmt.Errorf("%s is not a valid job file, %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("%s is not a valid job file: %w", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return file, fmt.Errorf("%s has no jobs", path)
+	}
+	for i := range file.Jobs {
+		j := &file.Jobs[i]
+		if j.Name == "" {
+			j.Name = fmt.Sprintf("job %d", i+1)
+		}
+		if j.Operation == "" {
+			j.Operation = operationCopy
+		}
+		if j.InstallDir == "" {
+			j.InstallDir = file.InstallDir
+		}
+		if err := j.check(); err != nil {
+			return file, fmt.Errorf("%s is not a valid job file, %s %w", path, j.Name, err)
+		}
+	}
+	return file, nil
+}
+
+// what j is missing for its operation, the schema can't tell which fields each one needs
+func (j job) check() error {
+	switch j.Operation {
+	case operationCopy:
+		if j.Src == "" || len(j.Dst) == 0 {
+			return errors.New("needs src and dst to copy")
+		}
+	case operationBackup:
+		if j.Src == "" {
+			return errors.New("needs src, the character to back up")
+		}
+	case operationRewrite:
+		characterRename := j.From != "" && j.To != ""
+		realmRename := j.FromRealm != "" && j.ToRealm != ""
+		if j.Dir == "" || characterRename == realmRename {
+			return errors.New("needs dir, and either from and to or from_realm and to_realm, to rewrite")
+		}
+	}
+	return nil
+}
+
+// the arguments that run j as a program of its own, installDir stands in when neither j nor its file name one
+func (j job) args(installDir string, ifChanged bool) []string {
+	var args []string
+	switch j.Operation {
+	case operationBackup:
+		args = []string{"backup", "--no-pause", "--src", j.Src}
+	case operationRewrite:
+		args = []string{"rewrite", "--yes", "--no-pause", "--dir", j.Dir}
+		if j.FromRealm != "" {
+			args = append(args, "--from-realm", j.FromRealm, "--to-realm", j.ToRealm)
+		} else {
+			args = append(args, "--from", j.From, "--to", j.To)
+		}
+	default:
+		args = []string{"copy", "--yes", "--no-pause", "--src", j.Src}
+		for _, dst := range j.Dst {
+			args = append(args, "--dst", dst)
+		}
+		if len(j.Only) > 0 {
+			args = append(args, "--only", strings.Join(j.Only, ","))
+		}
+		for _, pattern := range j.Include {
+			args = append(args, "--include", pattern)
+		}
+		for _, pattern := range j.Exclude {
+			args = append(args, "--exclude", pattern)
+		}
+		if ifChanged {
+			args = append(args, "--if-changed")
+		}
+	}
+	if j.InstallDir != "" {
+		installDir = j.InstallDir
+	}
+	if installDir != "" && j.Operation != operationRewrite {
+		args = append(args, "--install-dir", installDir)
+	}
+	if _dataDirOverride != "" {
+		args = append(args, "--data-dir", _dataDirOverride)
+	}
+	return args
+}
+
+// the install j locks while it runs, see acquireInstallLock, false when it doesn't take one
+// backups only read the install, and a rewrite only locks the install its folder is in, if any
+func (j job) lockedInstall(installDir string) (string, bool) {
+	switch j.Operation {
+	case operationBackup:
+		return "", false
+	case operationRewrite:
+		install := containingInstall(j.Dir)
+		return install, install != ""
+	}
+	if j.InstallDir != "" {
+		installDir = j.InstallDir
+	}
+	// "" is whichever install the copy finds, the same for every job that doesn't name one
+	return installDir, true
+}
+
+// a table of the jobs in file, for the confirmation
+func (file jobFile) table() [][]string {
+	table := [][]string{{"Job", "Operation", "What"}}
+	for _, j := range file.Jobs {
+		var what string
+		switch j.Operation {
+		case operationBackup:
+			what = j.Src
+		case operationRewrite:
+			from, to := j.From, j.To
+			if j.FromRealm != "" {
+				from, to = "realm "+j.FromRealm, j.ToRealm
+			}
+			what = fmt.Sprintf("%s to %s in %s", from, to, j.Dir)
+		default:
+			only := "everything"
+			if len(j.Only) > 0 {
+				only = strings.Join(j.Only, ", ")
+			}
+			what = fmt.Sprintf("%s of %s to %s", only, j.Src, strings.Join(j.Dst, ", "))
+		}
+		table = append(table, []string{j.Name, j.Operation, what})
+	}
+	return table
+}
+
+// how many jobs of operation may run at once
+func (file jobFile) limit(operation string) int {
+	limit := file.Concurrency
+	if limit < 1 {
+		limit = 1
+	}
+	if perOperation := file.OperationConcurrency[operation]; perOperation > 0 && perOperation < limit {
+		limit = perOperation
+	}
+	return limit
+}
+
+type jobState int
+
+const (
+	jobPending jobState = iota
+	jobRunning
+	jobFinished
+)
+
+// decides which of a job file's jobs run when: operations in the order of _jobOperations, no more at once than
+// the file's concurrency settings allow, and one job at a time per install, the install lock would fail any other
+type jobScheduler struct {
+	file       jobFile
+	installDir string
+	states     []jobState
+	results    []jobResult
+}
+
+func newJobScheduler(file jobFile, installDir string) *jobScheduler {
+	s := &jobScheduler{file: file, installDir: installDir, states: make([]jobState, len(file.Jobs)), results: make([]jobResult, len(file.Jobs))}
+	for i, j := range file.Jobs {
+		s.results[i].job = j
+	}
+	return s
+}
+
+func operationRank(operation string) int {
+	for rank, known := range _jobOperations {
+		if known == operation {
+			return rank
+		}
+	}
+	return len(_jobOperations)
+}
+
+// the jobs to start now, and the ones to skip because a job of an earlier operation failed (with why)
+func (s *jobScheduler) next() (start []int, skip map[int]string) {
+	skip = make(map[int]string)
+	running := 0
+	runningByOperation := make(map[string]int)
+	busyInstalls := make(map[string]bool)
+	for i, state := range s.states {
+		if state == jobRunning {
+			j := s.file.Jobs[i]
+			running++
+			runningByOperation[j.Operation]++
+			if install, locks := j.lockedInstall(s.installDir); locks {
+				busyInstalls[install] = true
+			}
+		}
+	}
+
+	for i, state := range s.states {
+		if state != jobPending {
+			continue
+		}
+		j := s.file.Jobs[i]
+		waiting, failed := false, -1
+		for k, earlier := range s.file.Jobs {
+			if operationRank(earlier.Operation) >= operationRank(j.Operation) {
+				continue
+			}
+			if s.states[k] != jobFinished {
+				waiting = true
+			} else if s.results[k].err != nil || s.results[k].skipped != "" {
+				failed = k
+			}
+		}
+		if failed >= 0 {
+			earlier := s.file.Jobs[failed]
+			skip[i] = fmt.Sprintf("%s didn't get done, and a %s waits for every %s", earlier.Name, j.Operation, earlier.Operation)
+			continue
+		}
+		if waiting || running >= s.file.limit("") || runningByOperation[j.Operation] >= s.file.limit(j.Operation) {
+			continue
+		}
+		install, locks := j.lockedInstall(s.installDir)
+		if locks && busyInstalls[install] {
+			continue
+		}
+		start = append(start, i)
+		running++
+		runningByOperation[j.Operation]++
+		if locks {
+			busyInstalls[install] = true
+		}
+	}
+	return start, skip
+}
+
+// how far each operation has got, e.g. "backup 2/2 done; copy 1/3 done, 2 running; rewrite 0/1 done"
+func (s *jobScheduler) progress() string {
+	var parts []string
+	for _, operation := range _jobOperations {
+		total, finished, running := 0, 0, 0
+		for i, j := range s.file.Jobs {
+			if j.Operation != operation {
+				continue
+			}
+			total++
+			switch s.states[i] {
+			case jobFinished:
+				finished++
+			case jobRunning:
+				running++
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s %d/%d done", operation, finished, total)
+		if running > 0 {
+			part += fmt.Sprintf(", %d running", running)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// runs the jobs in file as jobScheduler orders them, each as a separate copy of this program so one failing
+// doesn't stop the rest (only the jobs of later operations, which wait for it)
+// one job at a time, its output goes straight to the terminal under a heading of its own; with several at once,
+// each one's output is kept until it finishes and printed in one piece, with the progress of every operation
+// printed whenever a job starts or finishes
+func runJobs(file jobFile, installDir string, ifChanged bool) ([]jobResult, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	s := newJobScheduler(file, installDir)
+	concurrent := false
+	for _, operation := range _jobOperations {
+		concurrent = concurrent || file.limit(operation) > 1
+	}
+
+	type finishedJob struct {
+		index  int
+		err    error
+		took   time.Duration
+		output []byte
+	}
+	finished := make(chan finishedJob)
+	running := 0
+	for {
+		start, skip := s.next()
+		for i, reason := range skip {
+			s.states[i] = jobFinished
+			s.results[i].skipped = reason
+			pterm.Warning.Printfln("Skipped %s, %s", file.Jobs[i].Name, reason)
+		}
+		for _, i := range start {
+			j := file.Jobs[i]
+			s.states[i] = jobRunning
+			running++
+			cmd := exec.Command(executable, j.args(installDir, ifChanged)...)
+			// no stdin, a prompt --yes doesn't cover fails the job instead of waiting for an answer nobody gives
+			cmd.Stdin = nil
+			var output bytes.Buffer
+			if concurrent {
+				pterm.Info.Printfln("Started %s: %s", j.Name, s.progress())
+				cmd.Stdout, cmd.Stderr = &output, &output
+			} else {
+				pterm.DefaultSection.Printfln("%s (%d of %d)", j.Name, i+1, len(file.Jobs))
+				cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			}
+			started := time.Now()
+			go func(i int) {
+				err := cmd.Run()
+				finished <- finishedJob{i, err, time.Since(started).Round(time.Second), output.Bytes()}
+			}(i)
+		}
+		if running == 0 {
+			if len(skip) == 0 {
+				return s.results, nil
+			}
+			continue
+		}
+
+		done := <-finished
+		running--
+		s.states[done.index] = jobFinished
+		s.results[done.index].err = done.err
+		s.results[done.index].took = done.took
+		if concurrent {
+			j := file.Jobs[done.index]
+			pterm.DefaultSection.Printfln("%s (%s)", j.Name, j.Operation)
+			io.Copy(os.Stdout, bytes.NewReader(done.output))
+			pterm.Info.Printfln("Finished %s: %s", j.Name, s.progress())
+		}
+	}
+}
+
+// a table of how each job went, with how many failed or were skipped
+func jobResultsTable(results []jobResult) ([][]string, int) {
+	table := [][]string{{"Job", "Operation", "Result", "Took"}}
+	failed := 0
+	for _, result := range results {
+		outcome := pterm.Green("done")
+		switch {
+		case result.skipped != "":
+			outcome = pterm.Yellow("skipped: " + result.skipped)
+			failed++
+		case result.err != nil:
+			outcome = pterm.Red("failed: " + result.err.Error())
+			failed++
+		}
+		table = append(table, []string{result.job.Name, result.job.Operation, outcome, result.took.String()})
+	}
+	return table, failed
+}
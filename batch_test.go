@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// the jobs next starts, one round at a time, with every job started in a round finishing before the next
+func scheduleRounds(t *testing.T, file jobFile, failing string) [][]string {
+	t.Helper()
+	s := newJobScheduler(file, "")
+	var rounds [][]string
+	for len(rounds) <= len(file.Jobs) {
+		start, skip := s.next()
+		for i := range skip {
+			s.states[i] = jobFinished
+			s.results[i].skipped = skip[i]
+		}
+		if len(start) == 0 && len(skip) == 0 {
+			return rounds
+		}
+		var names []string
+		for _, i := range start {
+			names = append(names, file.Jobs[i].Name)
+			s.states[i] = jobFinished
+			if file.Jobs[i].Name == failing {
+				s.results[i].err = errors.New("failed")
+			}
+		}
+		if len(names) > 0 {
+			rounds = append(rounds, names)
+		}
+	}
+	t.Fatal("the scheduler never finished")
+	return nil
+}
+
+func TestJobSchedulerOrder(t *testing.T) {
+	jobs := []job{
+		{Name: "rewrite", Operation: operationRewrite, Dir: t.TempDir()},
+		{Name: "copy to alt", Operation: operationCopy},
+		{Name: "copy to classic", Operation: operationCopy, InstallDir: "classic"},
+		{Name: "copy to bank", Operation: operationCopy},
+		{Name: "backup alt", Operation: operationBackup},
+		{Name: "backup bank", Operation: operationBackup},
+	}
+	for _, test := range []struct {
+		name                 string
+		concurrency          int
+		operationConcurrency map[string]int
+		failing              string
+		rounds               [][]string
+	}{
+		{"one at a time", 0, nil, "", [][]string{
+			{"backup alt"}, {"backup bank"}, {"copy to alt"}, {"copy to classic"}, {"copy to bank"}, {"rewrite"}}},
+		{"copies to the same install take turns", 4, nil, "", [][]string{
+			{"backup alt", "backup bank"}, {"copy to alt", "copy to classic"}, {"copy to bank"}, {"rewrite"}}},
+		{"per operation", 4, map[string]int{"backup": 1}, "", [][]string{
+			{"backup alt"}, {"backup bank"}, {"copy to alt", "copy to classic"}, {"copy to bank"}, {"rewrite"}}},
+		{"a failed backup skips the copies and rewrites", 4, nil, "backup bank", [][]string{
+			{"backup alt", "backup bank"}}},
+		{"a failed copy skips only the rewrites", 4, nil, "copy to alt", [][]string{
+			{"backup alt", "backup bank"}, {"copy to alt", "copy to classic"}, {"copy to bank"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := jobFile{Jobs: jobs, Concurrency: test.concurrency, OperationConcurrency: test.operationConcurrency}
+			if rounds := scheduleRounds(t, file, test.failing); !reflect.DeepEqual(rounds, test.rounds) {
+				t.Errorf("got  %q\nwant %q", rounds, test.rounds)
+			}
+		})
+	}
+}
+
+func TestJobCheck(t *testing.T) {
+	for _, test := range []struct {
+		job   job
+		valid bool
+	}{
+		{job{Operation: operationCopy, Src: "@main", Dst: []string{"@alt"}}, true},
+		{job{Operation: operationCopy, Src: "@main"}, false},
+		{job{Operation: operationBackup, Src: "@main"}, true},
+		{job{Operation: operationBackup}, false},
+		{job{Operation: operationRewrite, Dir: "SavedVariables", From: "Main-Old", To: "Main-New"}, true},
+		{job{Operation: operationRewrite, Dir: "SavedVariables", FromRealm: "Old", ToRealm: "New"}, true},
+		{job{Operation: operationRewrite, Dir: "SavedVariables", From: "Main-Old", To: "Main-New", FromRealm: "Old", ToRealm: "New"}, false},
+		{job{Operation: operationRewrite, From: "Main-Old", To: "Main-New"}, false},
+	} {
+		if err := test.job.check(); (err == nil) != test.valid {
+			t.Errorf("%+v: got %v, want valid %v", test.job, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/wtf"
+)
+
+// stands in for the character (and server) of a destination, to copy to every character in its scope instead of one
+const bulkWildcard = "*"
+
+// the select entries for copying to every character the account has on the chosen realm, or on any realm, or
+// that any account has
+const bulkRealmOption = "[Every character on this realm]"
+const bulkAccountOption = "[Every character on this account, all realms]"
+const bulkVersionOption = "[Every character on this version, all accounts]"
+
+// whether target is a scope of characters rather than a single one
+func isBulk(target wtf.Target) bool {
+	return target.Name == bulkWildcard
+}
+
+// the realm a bulk scope covers, or "every realm" (of every account)
+func bulkScopeName(target wtf.Target) string {
+	if target.Account == bulkWildcard {
+		return "every account"
+	}
+	if target.Server == bulkWildcard {
+		return "every realm"
+	}
+	return target.Server
+}
+
+// parses a version/account/server/* or version/account/*/* destination, checking that the account has characters in it
+func parseBulkTarget(spec string, wow WowInstall) (wtf.Target, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 4 || parts[3] != bulkWildcard || parts[1] == bulkWildcard && parts[2] != bulkWildcard {
+		return wtf.Target{}, fmt.Errorf("%q should look like <version>/<account>/<server>/%s, <version>/<account>/%s/%s, or <version>/%s/%s/%s", spec, bulkWildcard, bulkWildcard, bulkWildcard, bulkWildcard, bulkWildcard, bulkWildcard)
+	}
+	target := wtf.Target{
+		Character: wtf.Character{Account: parts[1], Server: parts[2], Name: bulkWildcard},
+		Version:   resolveVersionName(parts[0]),
+	}
+
+	// an account or realm typed in another case finds its folders, like a single destination does
+	var scopes []wtf.Character
+	for _, character := range wow.getWtfConfigurations(target.Version) {
+		scope := wtf.Character{Account: character.Account, Server: character.Server, Name: bulkWildcard}
+		if target.Account == bulkWildcard {
+			scope.Account = bulkWildcard
+		}
+		if target.Server == bulkWildcard {
+			scope.Server = bulkWildcard
+		}
+		scopes = append(scopes, scope)
+	}
+	if character, err := matchWtfCase(target.Character, deduplicateWtfs(scopes)); err != nil {
+		return target, err
+	} else if character != nil {
+		target.Character = *character
+	}
+
+	if len(wow.expandBulkTarget(target, wtf.Target{})) > 0 {
+		return target, nil
+	}
+	if target.Account == bulkWildcard {
+		return target, fmt.Errorf("there are no characters in %s", parts[0])
+	}
+	return target, fmt.Errorf("account %s has no characters on %s in %s", target.Account, bulkScopeName(target), parts[0])
+}
+
+// wtfs without the repeated ones, in the order they first appear
+func deduplicateWtfs(wtfs []wtf.Character) []wtf.Character {
+	seen := make(map[wtf.Character]bool)
+	var unique []wtf.Character
+	for _, character := range wtfs {
+		if !seen[character] {
+			seen[character] = true
+			unique = append(unique, character)
+		}
+	}
+	return unique
+}
+
+// every character in the bulk destination scope, leaving out src so it isn't copied onto itself
+func (wow WowInstall) expandBulkTarget(scope wtf.Target, src wtf.Target) []wtf.Target {
+	var targets []wtf.Target
+	for _, character := range wow.getWtfConfigurations(scope.Version) {
+		if (scope.Account != bulkWildcard && character.Account != scope.Account) || (scope.Server != bulkWildcard && character.Server != scope.Server) {
+			continue
+		}
+		target := wtf.Target{Character: character, Version: scope.Version}
+		if target == src {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// the characters to copy to, from --dst or by asking, where a bulk scope stands for every character in it
+// src is left out of bulk scopes, and srcWow tells whether it's even in the same install
+// characters in config's bulk_exclusions for src are left out, when interactive they're listed for review along
+// with the rest and any of them can be left out (and remembered as excluded)
+func resolveDestinations(wow WowInstall, dstSpec string, create bool, srcWow WowInstall, src wtf.Target, config Config, interactive bool) []wtf.Target {
+	var scope wtf.Target
+	if dstSpec == "" {
+		pterm.Info.Println("Next, pick the Version, Account, Server, and Character to apply that configuration data to.")
+		scope = wow.selectWtf(false, true)
+		if !isBulk(scope) {
+			return []wtf.Target{checkDestinationName(wow, scope)}
+		}
+	} else if strings.HasSuffix(dstSpec, "/"+bulkWildcard) {
+		var err error
+		if scope, err = parseBulkTarget(dstSpec, wow); err != nil {
+			fatal(err)
+		}
+	} else {
+		return []wtf.Target{resolveDestination(wow, dstSpec, create)}
+	}
+
+	sameInstall := srcWow.Dir == wow.Dir
+	var targets []wtf.Target
+	if sameInstall {
+		targets = wow.expandBulkTarget(scope, src)
+	} else {
+		targets = wow.expandBulkTarget(scope, wtf.Target{})
+	}
+	if len(targets) == 0 && scope.Account == bulkWildcard {
+		fatalf("There are no other characters in %s to copy to", _wowInstanceFolderNames[scope.Version])
+	}
+	if len(targets) == 0 {
+		fatalf("%s has no other characters on %s to copy to", scope.Account, bulkScopeName(scope))
+	}
+	if interactive {
+		return reviewBulkTargets(scope, targets, src, config)
+	}
+
+	var names, excluded []string
+	var included []wtf.Target
+	for _, target := range targets {
+		if config.isBulkExcluded(src, target) {
+			excluded = append(excluded, target.CharacterName())
+			continue
+		}
+		included = append(included, target)
+		names = append(names, target.CharacterName())
+	}
+	if len(excluded) > 0 {
+		pterm.Info.Printfln("Leaving %s alone, they're in bulk_exclusions for this source", strings.Join(excluded, ", "))
+	}
+	if len(included) == 0 {
+		fatalf("Every character on %s is excluded for this source in bulk_exclusions", bulkScopeName(scope))
+	}
+	pterm.Info.Printfln("Copying to every character on %s (%d): %s", bulkScopeName(scope), len(included), strings.Join(names, ", "))
+	return included
+}
+
+// lists every character in a bulk scope, selected unless bulk_exclusions leaves it out for src, so the ones that
+// should be left alone can be deselected
+// a changed selection can be saved to bulk_exclusions, so the bank alt stays deselected next time
+func reviewBulkTargets(scope wtf.Target, targets []wtf.Target, src wtf.Target, config Config) []wtf.Target {
+	var labels, selected []string
+	byLabel := make(map[string]wtf.Target)
+	for _, target := range targets {
+		label := target.CharacterName()
+		// the same name can be taken on a realm of another region
+		if scope.Account == bulkWildcard {
+			label = fmt.Sprintf("%s (%s)", label, target.Account)
+		}
+		labels = append(labels, label)
+		byLabel[label] = target
+		if !config.isBulkExcluded(src, target) {
+			selected = append(selected, label)
+		}
+	}
+
+	chosen := askMultiselect("bulk.review", pterm.DefaultInteractiveMultiselect.
+		WithOptions(labels).
+		WithDefaultOptions(selected).
+		WithDefaultText(fmt.Sprintf("Copy to these %d characters on %s? Deselect any to leave alone", len(targets), bulkScopeName(scope))).
+		WithMaxHeight(selectMaxHeight()))
+	if len(chosen) == 0 {
+		pterm.Error.Println("Every character was deselected, nothing was copied")
+		exit(1)
+	}
+	var reviewed []wtf.Target
+	for _, label := range chosen {
+		reviewed = append(reviewed, byLabel[label])
+	}
+	if skipped := len(targets) - len(reviewed); skipped > 0 {
+		pterm.Info.Printfln("Leaving %d of %d characters on %s alone", skipped, len(targets), bulkScopeName(scope))
+	}
+
+	if !sameStrings(chosen, selected) && _answers == nil && askConfirm("bulk.remember", pterm.DefaultInteractiveConfirm.
+		WithDefaultText(fmt.Sprintf("Remember the deselected characters for bulk copies from %s?", src.CharacterName())).
+		WithDefaultValue(true)) {
+		if err := rememberBulkExclusions(config, src, targets, reviewed); err != nil {
+			pterm.Warning.Printfln("Couldn't save bulk_exclusions: %s", err)
+		}
+	}
+	return reviewed
+}
+
+// whether a and b hold the same strings, ignoring order
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+		if count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// saves which of targets were left out of chosen as src's bulk_exclusions in config.yaml
+// exclusions outside targets (e.g. on other realms) are kept
+func rememberBulkExclusions(config Config, src wtf.Target, targets []wtf.Target, chosen []wtf.Target) error {
+	key := newPlanDocumentTarget(src).String()
+	for source := range config.BulkExclusions {
+		if targetMatchesSpec(source, src) {
+			key = source
+		}
+	}
+
+	inScope := make(map[string]bool)
+	for _, target := range targets {
+		inScope[newPlanDocumentTarget(target).String()] = true
+	}
+	var excluded []string
+	for _, spec := range config.BulkExclusions[key] {
+		if parts := strings.Split(spec, "/"); len(parts) == 4 {
+			spec = newPlanDocumentTarget(wtf.Target{Character: wtf.Character{Account: parts[1], Server: parts[2], Name: parts[3]}, Version: resolveVersionName(parts[0])}).String()
+		}
+		if !inScope[spec] {
+			excluded = append(excluded, spec)
+		}
+	}
+	kept := make(map[wtf.Target]bool)
+	for _, target := range chosen {
+		kept[target] = true
+	}
+	for _, target := range targets {
+		if !kept[target] {
+			excluded = append(excluded, newPlanDocumentTarget(target).String())
+		}
+	}
+
+	exclusions := make(map[string][]string)
+	for source, specs := range config.BulkExclusions {
+		exclusions[source] = specs
+	}
+	if len(excluded) == 0 {
+		delete(exclusions, key)
+	} else {
+		exclusions[key] = excluded
+	}
+	if err := saveConfigValue("bulk_exclusions", exclusions); err != nil {
+		return err
+	}
+	path, _ := configFilePath()
+	pterm.Success.Printfln("Saved to bulk_exclusions in %s", path)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/pterm/pterm"
+)
+
+// what was picked last time at each prompt, keyed by prompt, loaded on first use
+var _rememberedChoices map[string]string
+
+func rememberedChoicesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "choices.json"), nil
+}
+
+// a missing or unreadable file just means nothing is preselected
+func loadRememberedChoices() map[string]string {
+	if _rememberedChoices != nil {
+		return _rememberedChoices
+	}
+	_rememberedChoices = make(map[string]string)
+	path, err := rememberedChoicesPath()
+	if err != nil {
+		return _rememberedChoices
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			pterm.Debug.Printfln("couldn't read %s: %s", path, err)
+		}
+		return _rememberedChoices
+	}
+	if err := json.Unmarshal(data, &_rememberedChoices); err != nil {
+		pterm.Debug.Printfln("couldn't read %s: %s", path, err)
+		_rememberedChoices = make(map[string]string)
+	}
+	return _rememberedChoices
+}
+
+// stores choice as the default for the prompt named key next time
+// failing to save is never worth stopping a copy for, it only costs keystrokes next run
+func rememberChoice(key string, choice string) {
+	choices := loadRememberedChoices()
+	if choice == "" || choices[key] == choice {
+		return
+	}
+	choices[key] = choice
+
+	path, err := rememberedChoicesPath()
+	if err == nil {
+		var data []byte
+		data, err = json.MarshalIndent(choices, "", "  ")
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+	}
+	if err != nil {
+		pterm.Debug.Printfln("couldn't remember %s: %s", key, err)
+	}
+}
+
+// an interactive select that starts on whatever was picked at the prompt named key last time, or on fallback
+// (e.g. a default from config.yaml) when nothing was, the choice is only used while it's still one of options
+func selectRemembered(key string, options []string, text string, fallback string) string {
+	printer := pterm.DefaultInteractiveSelect.
+		WithOptions(options).
+		WithDefaultText(text).
+		WithMaxHeight(selectMaxHeight())
+	previous, ok := loadRememberedChoices()[key]
+	if !ok {
+		previous = fallback
+	}
+	for _, option := range options {
+		if option == previous {
+			printer = printer.WithDefaultOption(previous)
+			break
+		}
+	}
+
+	choice := askSelect(key, printer)
+	// scripted runs shouldn't change what the user sees next time
+	if _answers == nil {
+		rememberChoice(key, choice)
+	}
+	return choice
+}
@@ -0,0 +1,1047 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// a subcommand, picked by the first argument, copy when there's none
+type subcommand struct {
+	name        string
+	arg         string // what it takes after its name, "" when it takes nothing
+	description string
+	// registers the command's own flags and returns what runs it once they're parsed, with its argument
+	define func(fs *flag.FlagSet, common *commonFlags) func(arg string)
+}
+
+// every subcommand, in the order usage lists them
+var _subcommands = []subcommand{
+	{"copy", "", "resolve what to copy and copy it in one go (the default)", copyCommand},
+	{"plan", "", "resolve what to copy and save it as a plan, without copying", planCommand},
+	{"apply", "", "run a saved plan", savedPlanCommand("apply")},
+	{"edit-plan", "", "go through a saved plan's steps, reorder them or turn them off, then save or run it", savedPlanCommand("edit-plan")},
+	{"list", "", "list the accounts, realms, and characters of every version", listCommand},
+	{"diff", "", "show which files copying --src onto --dst would change", diffCommand},
+	{"backup", "", "back up a character's account and character files", backupCommand},
+	{"restore", "[backup]", "put back the files an earlier copy or backup saved, undoing a bad copy", restoreCommand},
+	{"share", "", "upload a profile", shareCommand},
+	{"import", "<code>", "copy a shared profile", importCommand},
+	{"import-string", "", "queue an in-game export string (WeakAuras, ElvUI, Plater) for a character", importStringCommand},
+	{"status", "", "show which synced pairs have changed in the source since their last sync", statusCommand},
+	{"check", "", "report whether the last copy survived the destination's first login", checkCommand},
+	{"du", "", "show which accounts, characters, and addons take up the most space", duCommand},
+	{"stats", "", "show how the WTF folders and each addon's SavedVariables have grown over time", statsCommand},
+	{"relocate", "[version]", "move a version's WTF folder to another drive and leave a link behind", relocateCommand},
+	{"batch", "<job file>", "run the copies listed in a YAML or JSON job file one after the other, with a summary at the end", batchCommand},
+	{"serve", "", "wait for local HTTP triggers and run the syncs configured in config.yaml", serveCommand},
+	{"explore", "", "browse the WTF folders and preview files without writing anything", exploreCommand},
+	{"rewrite", "", "fix character and realm names in SavedVariables copied by hand or restored from a backup", rewriteCommand},
+	{"spread-addon", "<addon>", "copy one addon's character SavedVariables to the other characters", spreadAddonCommand},
+	{"summary", "", "write a readable overview of a character's profile, for sharing without the files", summaryCommand},
+	{"lint", "", "check a character's settings files for anything that would make the game reset them", lintCommand},
+	{"convert", "<file>", "convert a SavedVariables .lua file to JSON, or an edited .json file back to Lua", convertCommand},
+	{"sign", "<file>", "sign a file, e.g. presets.json, so those with your public key in trusted_keys can trust it", signCommand},
+}
+
+// the subcommand called name, nil when there's none
+func findSubcommand(name string) *subcommand {
+	for i := range _subcommands {
+		if _subcommands[i].name == name {
+			return &_subcommands[i]
+		}
+	}
+	return nil
+}
+
+// whether name is one of _subcommands
+func isSubcommand(name string) bool {
+	return findSubcommand(name) != nil
+}
+
+// the names of _subcommands, as "a, b, or c"
+func subcommandNames() string {
+	var names []string
+	for _, command := range _subcommands {
+		names = append(names, command.name)
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+}
+
+// the command's flags, its own and the common ones, and what runs it once they're parsed
+func (c subcommand) flagSet() (*flag.FlagSet, *commonFlags, func(string)) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	common := &commonFlags{}
+	run := c.define(fs, common)
+	common.register(fs)
+	return fs, common, run
+}
+
+// the other subcommands that take the flag name, for telling someone who passed it to the wrong one
+func commandsTaking(name string) []string {
+	var commands []string
+	for _, command := range _subcommands {
+		if fs, _, _ := command.flagSet(); fs.Lookup(name) != nil {
+			commands = append(commands, command.name)
+		}
+	}
+	return commands
+}
+
+// parses args as the command's flags and runs it, exiting with 2 on a flag it doesn't take
+// its argument, if it takes one, may come before or after the flags
+func (c subcommand) run(args []string) {
+	fs, _, run := c.flagSet()
+	fs.SetOutput(io.Discard)
+	var arg string
+	if c.arg != "" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		arg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		c.printUsage(fs)
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		const undefinedPrefix = "flag provided but not defined: -"
+		name := strings.TrimPrefix(err.Error(), undefinedPrefix)
+		if others := commandsTaking(name); strings.HasPrefix(err.Error(), undefinedPrefix) && len(others) > 0 {
+			list := others[0]
+			if len(others) > 1 {
+				list = strings.Join(others[:len(others)-1], ", ") + " and " + others[len(others)-1]
+			}
+			fmt.Fprintf(os.Stderr, "--%s doesn't apply to %s, it's a flag of %s\n", name, c.name, list)
+		}
+		fmt.Fprintf(os.Stderr, "Run %s %s -h for the flags it takes\n", filepath.Base(os.Args[0]), c.name)
+		os.Exit(2)
+	}
+	rest := fs.Args()
+	if c.arg != "" && arg == "" && len(rest) > 0 {
+		arg, rest = rest[0], rest[1:]
+	}
+	// the flag package stops at the first argument that isn't a flag, anything after it would be ignored silently
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "%s doesn't take %q, run %s %s -h for what it takes\n", c.name, rest[0], filepath.Base(os.Args[0]), c.name)
+		os.Exit(2)
+	}
+	run(arg)
+}
+
+// prints what the command does and its flags, for -h
+// copy is the default, so its usage lists the other commands too
+func (c subcommand) printUsage(fs *flag.FlagSet) {
+	out := os.Stderr
+	if c.name == "copy" {
+		printUsage()
+		fmt.Fprintf(out, "\nFlags of copy (the other commands list theirs with %s <command> -h):\n", filepath.Base(os.Args[0]))
+	} else {
+		fmt.Fprintf(out, "Usage: %s\n\n%s\n\nFlags:\n", strings.TrimSpace(fmt.Sprintf("%s %s [flags] %s", filepath.Base(os.Args[0]), c.name, c.arg)), c.description)
+	}
+	fs.SetOutput(out)
+	fs.PrintDefaults()
+}
+
+// prints the subcommands, for -h
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, command := range _subcommands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", command.name, command.description)
+	}
+}
+
+// the flags every subcommand takes: where the install and our own files are, and how the run looks
+type commonFlags struct {
+	installDir    string
+	dataDir       string
+	portable      bool
+	noPause       bool
+	updatePresets bool
+	printDefaults bool
+	theme         string
+	answers       string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.installDir, "install-dir", "", "path to the World of Warcraft install, skips auto-detection and the directory browser")
+	fs.BoolVar(&_rescanInstall, "rescan", false, "look for the WoW install again, instead of using the one confirmed at the last run")
+	fs.StringVar(&c.dataDir, "data-dir", "", "keep config, backups, and caches in this directory instead of the usual per-user locations")
+	fs.BoolVar(&c.portable, "portable", false, "keep config, backups, and caches in a folder next to the executable (also on when "+portableSentinelName+" is next to it)")
+	fs.BoolVar(&c.noPause, "no-pause", false, "don't wait for Enter before exiting when started by double-click")
+	fs.BoolVar(&c.updatePresets, "update-presets", false, "re-download the community presets even if the cached copy is recent")
+	fs.BoolVar(&c.printDefaults, "print-defaults", false, "print the built-in file lists and exclusions (merged with any defaults.json override) and exit")
+	fs.StringVar(&c.theme, "theme", "", "output theme for this run: default, high-contrast, colorblind, or monochrome (set theme in config.yaml to keep it)")
+	fs.StringVar(&c.answers, "answers", "", "answer the prompts from this YAML file of prompt: answer pairs instead of the terminal (- reads one answer per line from stdin)")
+}
+
+// what a subcommand runs with once the common flags are applied
+type session struct {
+	config      Config
+	installDir  string // --install-dir, or config.yaml's
+	terminal    bool
+	interactive bool
+}
+
+// applies the common flags, then loads config.yaml, the theme, and the community presets
+// without a terminal or scripted answers only a fully flag-driven run can work, so unless headlessReady (the
+// command's flags answer everything it would ask) this exits with the headless usage
+func (c commonFlags) start(command string, headlessReady bool) session {
+	if c.dataDir != "" {
+		absolute, err := filepath.Abs(c.dataDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		_dataDirOverride = absolute
+	} else {
+		dir, err := portableDataDir(c.portable)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		_dataDirOverride = dir
+	}
+
+	if err := loadDefaultsOverride(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if c.printDefaults {
+		if err := printDefaults(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if c.answers != "" {
+		answers, err := loadScriptedAnswers(c.answers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		_answers = answers
+	}
+
+	s := session{terminal: isInteractiveTerminal()}
+	s.interactive = s.terminal || _answers != nil
+	if !s.interactive && !headlessReady {
+		if relaunchInConsole() {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, headlessUsage)
+		os.Exit(2)
+	}
+
+	// make windows users feel at home, and keep the window around long enough to read it
+	pauseBeforeExit = s.terminal && _answers == nil && !c.noPause && ownsConsole()
+	if s.terminal {
+		setConsoleTitle("wow-profile-copy")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fatal(explainFileError(err))
+	}
+	s.config = config
+	// config.yaml's defaults, for whatever the flags leave unset
+	s.installDir = c.installDir
+	if s.installDir == "" {
+		s.installDir = config.InstallDir
+	}
+
+	theme := config.Theme
+	if c.theme != "" {
+		theme = c.theme
+	}
+	if err := applyTheme(theme); err != nil {
+		fatal(err)
+	}
+
+	// only commands that decide what to copy use the presets, the others never go online for them
+	refresh := _presetCommands[command] && !config.OfflinePresets
+	presets, err := loadPresets(config.PresetsURL, c.updatePresets, refresh, config.TrustedKeys)
+	if err != nil {
+		pterm.Warning.Printfln("Couldn't load the community presets, using what could be loaded and the built-in lists: %s", err)
+	}
+	presets.apply()
+
+	// hashing the last syncs' destinations is only worth it right before copying again
+	if command == "copy" || command == "apply" || command == "batch" {
+		if err := warnAboutRevertedSyncs(); err != nil {
+			pterm.Debug.Printfln("couldn't check earlier syncs: %s", err)
+		}
+	}
+	return s
+}
+
+// the commands whose choice of what to copy the presets' lists go into
+var _presetCommands = map[string]bool{
+	"copy": true, "plan": true, "apply": true, "edit-plan": true, "import": true, "diff": true, "spread-addon": true, "serve": true,
+}
+
+// the install the run is about, from --install-dir, config.yaml, or what was picked before
+func (s session) resolveInstall() WowInstall {
+	return resolveInstall(s.installDir, s.config, s.interactive)
+}
+
+// spec with an @alias expanded to the version/account/server/character it stands for
+func (s session) expandAlias(spec string) string {
+	expanded, err := expandTargetAlias(spec, s.config)
+	if err != nil {
+		fatal(err)
+	}
+	return expanded
+}
+
+// the flags about how files are written, each command registers the ones that apply to it
+type copyFlags struct {
+	force         bool
+	noBackup      bool
+	noVerify      bool
+	normalizeText bool
+	copyRisky     bool
+	throttle      float64
+}
+
+func (f *copyFlags) register(fs *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		switch name {
+		case "force":
+			fs.BoolVar(&f.force, name, false, "raw full overwrite: no backup, no verification, and copy every SavedVariables file")
+		case "no-backup":
+			fs.BoolVar(&f.noBackup, name, false, "don't back up destination files before overwriting them")
+		case "no-verify":
+			fs.BoolVar(&f.noVerify, name, false, "don't verify copied files against their source")
+		case "normalize-text":
+			fs.BoolVar(&f.normalizeText, name, false, "strip byte order marks from copied .wtf and .txt files and give them this OS's line endings, for files made by the client on another OS")
+		case "copy-risky":
+			fs.BoolVar(&f.copyRisky, name, false, "copy account-wide aggregate SavedVariables (DataStore, Altoholic, TSM) between different accounts without asking")
+		case "throttle":
+			fs.Float64Var(&f.throttle, name, 0, "limit copying to this many MB/s, so a big sync doesn't slow down the game or other programs on the same drive")
+		default:
+			panic("unknown copy flag " + name)
+		}
+	}
+}
+
+// --throttle in bytes, exiting when it's negative
+func (f copyFlags) bytesPerSecond() int64 {
+	if f.throttle < 0 {
+		fmt.Fprintln(os.Stderr, "--throttle must be a positive number of MB/s")
+		os.Exit(2)
+	}
+	return int64(f.throttle * 1024 * 1024)
+}
+
+// the options the flags ask for, config.yaml's backup: false turns backups off too
+func (f copyFlags) options(config Config) copyplan.CopyOptions {
+	copyOptions := copyplan.SafeCopyOptions()
+	if f.force {
+		copyOptions = copyplan.ForceCopyOptions()
+	}
+	if f.noBackup || config.Backup != nil && !*config.Backup {
+		copyOptions.Backup = false
+	}
+	if f.noVerify {
+		copyOptions.Verify = false
+	}
+	if f.copyRisky {
+		copyOptions.SkipRisky = false
+	}
+	copyOptions.NormalizeText = f.normalizeText
+	copyOptions.BytesPerSecond = f.bytesPerSecond()
+	return copyOptions
+}
+
+// the flags about what a copy takes from the source, for the commands that resolve a plan
+type planFlags struct {
+	characterOnly        bool
+	noAccountMerge       bool
+	extractCharacterData bool
+	includeCombatLogs    bool
+	bindingsTo           string
+	only                 string
+	macros               string
+	include              stringListFlag
+	exclude              stringListFlag
+}
+
+func (f *planFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.characterOnly, "character-only", false, "copy only the source's character files (its settings, keybindings, and SavedVariables), leaving the destination's account-wide files alone, e.g. with --dst \"Retail/MYACCOUNT/*/*\" to pass one character's changes to every other")
+	fs.BoolVar(&f.noAccountMerge, "no-account-merge", false, "on the same account, leave its SavedVariables alone instead of merging the source character's entries in them under the destination's name")
+	fs.BoolVar(&f.extractCharacterData, "extract-character-data", false, "between different accounts, merge only the source character's own data from account-wide aggregate SavedVariables into the destination's instead of skipping them")
+	fs.BoolVar(&f.includeCombatLogs, "include-combat-logs", false, "also copy combat log and parser history (Details, Recount, Skada, Warcraft Logs)")
+	fs.StringVar(&f.bindingsTo, "bindings-to", "", "copy the source's keybindings into the destination's account or character bindings, instead of keeping the scope the source uses")
+	fs.Var(&f.include, "include", "copy only the SavedVariables files matching this glob, e.g. \"WeakAuras*\" (repeatable)")
+	fs.Var(&f.exclude, "exclude", "don't copy the SavedVariables files matching this glob, e.g. \"TradeSkillMaster*\" (repeatable)")
+	fs.StringVar(&f.only, "only", "", "copy only these, comma separated: keybindings, macros, settings, addons, layout, account-savedvariables, character-savedvariables (at a terminal you're asked otherwise)")
+	fs.StringVar(&f.macros, "macros", "", "which of the source's macros to copy: both, account (general macros only), or character (its own macros only)")
+}
+
+// checks a --bindings-to value
+func validateBindingsScope(scope string) error {
+	switch scope {
+	case "", copyplan.BindingsScopeAccount, copyplan.BindingsScopeCharacter:
+		return nil
+	}
+	return fmt.Errorf("--bindings-to must be %s or %s, not %q", copyplan.BindingsScopeAccount, copyplan.BindingsScopeCharacter, scope)
+}
+
+// the plan options the flags ask for, with config.yaml's rewrite rules and exclusions, exiting with 2 on flags
+// that don't go together; also returns config.yaml's only, which the contents prompt starts from
+func (f planFlags) options(config Config) (copyplan.Options, map[string]bool) {
+	if err := validateBindingsScope(f.bindingsTo); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateMacrosScope(f.macros); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if f.characterOnly && f.bindingsTo == copyplan.BindingsScopeAccount {
+		fmt.Fprintln(os.Stderr, "--character-only leaves the account's keybindings alone, it can't be used with --bindings-to account")
+		os.Exit(2)
+	}
+	contents, err := parseCopyContents(f.only)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, pattern := range append(append([]string{}, f.include...), f.exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "%q isn't a valid glob: %s\n", pattern, err)
+			os.Exit(2)
+		}
+	}
+	planOpts := copyplan.Options{
+		Contents:              contents,
+		IncludeSavedVariables: f.include,
+		ExcludeSavedVariables: append(append([]string{}, f.exclude...), config.Exclude...),
+		IncludeCombatLogs:     f.includeCombatLogs,
+		ExtractCharacterData:  f.extractCharacterData,
+		CharacterOnly:         f.characterOnly,
+		SkipAccountMerge:      f.noAccountMerge,
+		BindingsScope:         f.bindingsTo,
+		MacrosScope:           f.macros,
+	}
+	if planOpts.Rewrites, err = config.rewriteRules(); err != nil {
+		fatal(err)
+	}
+
+	var preselectedContents map[string]bool
+	if len(config.Only) > 0 {
+		if preselectedContents, err = parseCopyContents(strings.Join(config.Only, ",")); err != nil {
+			fatal(err)
+		}
+		if planOpts.Contents == nil {
+			planOpts.Contents = preselectedContents
+		}
+	}
+	return planOpts, preselectedContents
+}
+
+// list: every account, realm, and character
+func listCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(string) {
+		s := common.start("list", true)
+		wow := s.resolveInstall()
+		table := wow.listCharacters()
+		if len(table) == 1 {
+			fatalf("%s has no characters yet, log into one first", wow.Dir)
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		exit(0)
+	}
+}
+
+// diff: what copying --src onto --dst would change
+func diffCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	srcFlag := fs.String("src", "", "compare this version/account/server/character instead of prompting")
+	dstFlag := fs.String("dst", "", "with this version/account/server/character instead of prompting")
+	return func(string) {
+		s := common.start("diff", *srcFlag != "" && *dstFlag != "")
+		wow := s.resolveInstall()
+		src := resolveSource(wow, s.expandAlias(*srcFlag))
+		dst := resolveDestination(wow, s.expandAlias(*dstFlag), false)
+		table, same, err := wow.diffTargets(src, dst)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if len(table) == 1 {
+			pterm.Info.Printfln("%s already matches %s, copying would change nothing", dst.CharacterName(), src.CharacterName())
+			exit(0)
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		pterm.Info.Printfln("%d files would change, %d are already the same", len(table)-1, same)
+		exit(0)
+	}
+}
+
+// backup: a snapshot of a character, the same a copy takes of its destination
+func backupCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	srcFlag := fs.String("src", "", "back up this version/account/server/character instead of prompting")
+	var copying copyFlags
+	copying.register(fs, "throttle")
+	return func(string) {
+		s := common.start("backup", *srcFlag != "")
+		wow := s.resolveInstall()
+		src := resolveSource(wow, s.expandAlias(*srcFlag))
+		dir, count, err := wow.backupTarget(src, copying.options(s.config))
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Backed up %d of %s's files to %s", count, src.CharacterName(), dir)
+		exit(0)
+	}
+}
+
+// restore [backup]: puts a backup's files back, the newest of --dst's unless one is named
+func restoreCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	dstFlag := fs.String("dst", "", "restore a backup of this version/account/server/character, the newest one unless the backup's folder name is passed too")
+	yes := fs.Bool("yes", false, "don't ask which backup or for confirmation before restoring")
+	var copying copyFlags
+	copying.register(fs, "no-verify", "throttle")
+	return func(id string) {
+		s := common.start("restore", *dstFlag != "" && *yes)
+		wow := s.resolveInstall()
+		backups, err := wow.listBackups()
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		var target *wtf.Target
+		if *dstFlag != "" {
+			dst := resolveDestination(wow, s.expandAlias(*dstFlag), false)
+			target = &dst
+		}
+		backups = filterBackups(backups, target, id)
+		if len(backups) == 0 {
+			fatalf("No backups to restore, they're made before every copy unless --no-backup or --force is passed")
+		}
+		backup := backups[0]
+		if len(backups) > 1 && s.interactive && !*yes {
+			backup = selectBackup(backups)
+		}
+		if !*yes && !askConfirm("confirm", pterm.DefaultInteractiveConfirm.
+			WithDefaultText(fmt.Sprintf("Put back the %d files of %s?\nThe files they replace will be backed up first", len(backup.files), backup))) {
+			exit(1)
+		}
+		lock, err := acquireInstallLock(wow.Dir)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		onExit(func(int) { lock.release() })
+		restored, copier, err := wow.restoreBackup(backup, copying.options(s.config), func(path string) {
+			pterm.Info.Printfln("Removed %s", path)
+		})
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Restored %d files from %s", restored, backup.directory)
+		if _, err := os.Stat(copier.BackupDirectory); err == nil {
+			pterm.Info.Printfln("The files they replaced were backed up to %s, restore %s puts them back", copier.BackupDirectory, filepath.Base(copier.BackupDirectory))
+		}
+		exit(0)
+	}
+}
+
+// share: uploads a character's profile for import
+func shareCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	srcFlag := fs.String("src", "", "share this version/account/server/character instead of prompting")
+	encrypt := fs.Bool("encrypt", false, "encrypt the upload, the key is only part of the printed code")
+	sign := fs.Bool("sign", false, "sign the upload so importers with your public key in trusted_keys can trust it")
+	return func(string) {
+		s := common.start("share", *srcFlag != "")
+		if s.config.ShareURL == "" {
+			path, _ := configFilePath()
+			fatalf("Set share_url in %s to the paste or storage endpoint to upload shares to", path)
+		}
+		wow := s.resolveInstall()
+		src := resolveSource(wow, s.expandAlias(*srcFlag))
+		var key ed25519.PrivateKey
+		if *sign {
+			var err error
+			if key, err = loadSigningKey(); err != nil {
+				fatal(explainFileError(err))
+			}
+		}
+		code, err := shareProfile(wow, src, s.config.ShareURL, *encrypt, key)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Shared %s-%s's profile, import it with:\nwow-profile-copy import %s", src.Name, src.Server, code)
+		if key != nil {
+			pterm.Info.Printfln("The share is signed, whoever imports it can trust it by adding your public key to trusted_keys in config.yaml:\n%s", encodePublicKey(key))
+		}
+		exit(0)
+	}
+}
+
+// import-string: queues an export string for the companion addon to import at the next login
+func importStringCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	dstFlag := fs.String("dst", "", "queue it for this version/account/server/character instead of prompting")
+	create := fs.Bool("create", false, "with --dst, create the destination's realm and character folders if they don't exist yet")
+	exportString := fs.String("string", "", "the export string to import (read from stdin when there's no terminal)")
+	return func(string) {
+		s := common.start("import-string", *dstFlag != "")
+		wow := s.resolveInstall()
+		dst := resolveDestination(wow, s.expandAlias(*dstFlag), *create)
+
+		data := *exportString
+		if data == "" && s.interactive {
+			data = askText("export-string", pterm.DefaultInteractiveTextInput.
+				WithDefaultText("Paste the export string"))
+		} else if data == "" {
+			stdin, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fatal(err)
+			}
+			data = string(stdin)
+		}
+
+		// the game rewrites SavedVariables on logout, so a running client would throw the queue away
+		lock, err := acquireInstallLock(wow.Dir)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		onExit(func(int) { lock.release() })
+
+		addon, err := wow.queueExportString(dst, data)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Queued the %s string for %s-%s, it's imported the next time they log in (make sure the %s addon is enabled)", addon, dst.Name, dst.Server, companionAddonName)
+		exit(0)
+	}
+}
+
+// status: which synced pairs have changed since
+func statusCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(string) {
+		common.start("status", true)
+		table, err := syncStatusTable()
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if len(table) == 1 {
+			pterm.Info.Println("Nothing has been synced yet, status lists every source and destination pair after its first copy")
+			exit(0)
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		exit(0)
+	}
+}
+
+// check: whether the last copy survived the destination's first login
+func checkCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	dstFlag := fs.String("dst", "", "check the last copy to this version/account/server/character instead of the last copy to anyone")
+	return func(string) {
+		s := common.start("check", true)
+		dst := s.expandAlias(*dstFlag)
+		manifest, ok, err := latestSyncManifest(dst)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if !ok && dst != "" {
+			fatalf("Nothing has been copied to %s yet", dst)
+		}
+		if !ok {
+			fatalf("Nothing has been copied yet, check looks at the last copy once you've logged into its destination")
+		}
+		took, err := checkSync(manifest)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if !took {
+			exit(1)
+		}
+		exit(0)
+	}
+}
+
+// du: where the space goes
+func duCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(string) {
+		s := common.start("du", true)
+		wow := s.resolveInstall()
+		if err := printDiskUsage(wow); err != nil {
+			fatal(explainFileError(err))
+		}
+		if _, err := recordStats(wow); err != nil {
+			pterm.Debug.Printfln("couldn't record the sizes for stats: %s", err)
+		}
+		exit(0)
+	}
+}
+
+// stats: how the sizes du records have grown
+func statsCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(string) {
+		s := common.start("stats", true)
+		if err := printStats(s.resolveInstall()); err != nil {
+			fatal(explainFileError(err))
+		}
+		exit(0)
+	}
+}
+
+// relocate [version]: moves a version's WTF folder elsewhere and links it back, or with --undo back again
+func relocateCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	to := fs.String("to", "", "the folder to move WTF into, e.g. on a bigger drive")
+	undo := fs.Bool("undo", false, "move WTF back into the install and remove the link")
+	yes := fs.Bool("yes", false, "don't ask for confirmation before moving")
+	return func(versionArg string) {
+		if (*to == "") == !*undo {
+			fmt.Fprintln(os.Stderr, "relocate needs either --to <folder> to move WTF there, or --undo to move it back")
+			os.Exit(2)
+		}
+		s := common.start("relocate", versionArg != "" && *yes)
+		wow := s.resolveInstall()
+		version := resolveVersionName(versionArg)
+		if version == "" {
+			version = askSelect("relocate.version", pterm.DefaultInteractiveSelect.
+				WithOptions(wow.Versions).
+				WithDefaultText("WoW Version whose WTF folder to move"))
+		}
+		if _, err := os.Stat(wow.wtfPath(version)); err != nil {
+			fatalf("%s has no WTF folder in %s", version, wow.Dir)
+		}
+
+		confirmText := fmt.Sprintf("Move %s to %s and leave a link in its place?", wow.wtfPath(version), filepath.Join(*to, version, "WTF"))
+		if *undo {
+			confirmText = fmt.Sprintf("Move %s's WTF folder back into %s?", version, wow.Dir)
+		}
+		pterm.Warning.Println("Close the game and the launcher first, they keep WTF files open")
+		if !*yes && !askConfirm("confirm", pterm.DefaultInteractiveConfirm.WithDefaultText(confirmText)) {
+			exit(1)
+		}
+
+		lock, err := acquireInstallLock(wow.Dir)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		onExit(func(int) { lock.release() })
+
+		if *undo {
+			from, err := wow.restoreWtf(version)
+			if err != nil {
+				fatal(explainFileError(err))
+			}
+			pterm.Success.Printfln("Moved %s back into %s", from, wow.wtfPath(version))
+			exit(0)
+		}
+		absolute, err := filepath.Abs(*to)
+		if err != nil {
+			fatal(err)
+		}
+		target, err := wow.relocateWtf(version, absolute)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Moved %s to %s, the game and wow-profile-copy find it through the link", wow.wtfPath(version), target)
+		exit(0)
+	}
+}
+
+// batch <job file>: runs the jobs in the file, each as a copy of its own, backups first and rewrites last
+func batchCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	yes := fs.Bool("yes", false, "don't ask for confirmation before running the jobs")
+	ifChanged := fs.Bool("if-changed", false, "skip destinations whose last copy was from the same, unchanged source files")
+	return func(path string) {
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "batch needs the job file: wow-profile-copy batch <jobs.yaml>")
+			os.Exit(2)
+		}
+		s := common.start("batch", *yes)
+		file, err := readJobFile(path)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(file.table()).Render()
+		if !*yes && !askConfirm("confirm", pterm.DefaultInteractiveConfirm.
+			WithTextStyle(&pterm.ThemeDefault.WarningMessageStyle).
+			WithDefaultText(fmt.Sprintf("Run these %d jobs without asking about each one?", len(file.Jobs)))) {
+			exit(1)
+		}
+		results, err := runJobs(file, s.installDir, *ifChanged)
+		if err != nil {
+			fatal(err)
+		}
+		table, failed := jobResultsTable(results)
+		pterm.DefaultSection.Println("Summary")
+		pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		if failed > 0 {
+			pterm.Error.Printfln("%d of %d jobs failed or were skipped, their output is above", failed, len(results))
+			exit(1)
+		}
+		pterm.Success.Printfln("All %d jobs finished", len(results))
+		exit(0)
+	}
+}
+
+// serve: runs config.yaml's syncs when they're triggered over HTTP
+func serveCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	listen := fs.String("listen", defaultServeAddress, "the localhost address to wait for triggers on")
+	return func(string) {
+		s := common.start("serve", true)
+		if err := serveSyncTriggers(*listen, s.config, s.installDir); err != nil {
+			fatal(err)
+		}
+		exit(0)
+	}
+}
+
+// explore: a read-only browser of the WTF folders
+func exploreCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(string) {
+		s := common.start("explore", false)
+		if err := s.resolveInstall().explore(); err != nil {
+			fatal(explainFileError(err))
+		}
+		exit(0)
+	}
+}
+
+// rewrite: the renaming a copy does, applied to SavedVariables already in place
+func rewriteCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	dir := fs.String("dir", "", "the folder whose SavedVariables to rewrite, e.g. a character's SavedVariables folder")
+	from := fs.String("from", "", "the Name-Realm the SavedVariables still refer to")
+	to := fs.String("to", "", "the Name-Realm to rewrite it to")
+	fromRealm := fs.String("from-realm", "", "instead of --from, the realm to rename for every character on it")
+	toRealm := fs.String("to-realm", "", "the realm to rename --from-realm to")
+	yes := fs.Bool("yes", false, "rewrite every file that refers to --from without asking about each")
+	var copying copyFlags
+	copying.register(fs, "no-backup")
+	return func(string) {
+		characterRename := *from != "" || *to != ""
+		realmRename := *fromRealm != "" || *toRealm != ""
+		if *dir == "" || characterRename == realmRename {
+			fmt.Fprintln(os.Stderr, "rewrite needs the folder and either the character or the realm to rename:")
+			fmt.Fprintln(os.Stderr, "  wow-profile-copy rewrite --dir <path> --from Name-Old-Realm --to Name-New-Realm")
+			fmt.Fprintln(os.Stderr, "  wow-profile-copy rewrite --dir <path> --from-realm Old-Realm --to-realm New-Realm")
+			os.Exit(2)
+		}
+		s := common.start("rewrite", *yes)
+		rules, err := rewriteCommandRules(*from, *to)
+		if realmRename {
+			rules, err = realmRewriteRules(*fromRealm, *toRealm)
+			*from, *to = *fromRealm, *toRealm
+		}
+		if err != nil {
+			fatal(err)
+		}
+		paths, err := filesToRewrite(*dir, rules)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if len(paths) == 0 {
+			pterm.Info.Printfln("Nothing in %s refers to %s, there's nothing to rewrite", *dir, *from)
+			exit(0)
+		}
+		previews, err := previewRewrites(paths, rules)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Info.Printfln("These files refer to %s:", *from)
+		pterm.DefaultTable.WithHasHeader().WithData(rewritePreviewTable(previews, *dir)).Render()
+		pterm.Warning.Println("Close the game first, it overwrites SavedVariables on logout")
+		// each file is asked about on its own, with its changes a pick away
+		if paths = reviewRewrites(previews, *yes); len(paths) == 0 {
+			pterm.Info.Println("Nothing was rewritten")
+			exit(1)
+		}
+
+		// in an install, a copy, serve, or the game itself may be writing to the same files
+		var watch *copyplan.Watch
+		backupRoot := *dir
+		if install := containingInstall(*dir); install != "" {
+			lock, err := acquireInstallLock(install)
+			if err != nil {
+				fatal(explainFileError(err))
+			}
+			onExit(func(int) { lock.release() })
+			if watch, err = copyplan.NewWatch(paths...); err != nil {
+				fatal(explainFileError(err))
+			}
+			backupRoot = install
+		}
+		copyOptions := copying.options(s.config)
+		copier, err := newCopier(copyOptions, backupRoot)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if copyOptions.Backup {
+			for _, path := range paths {
+				if err := copier.BackupFile(path); err != nil {
+					fatal(explainFileError(fmt.Errorf("backing up %s: %w", path, err)))
+				}
+			}
+		}
+		if err := copyplan.RewriteSavedVariables(paths, rules, watch, "", _runLog.copyLog()); err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Rewrote %s to %s in %d files", *from, *to, len(paths))
+		if copyOptions.Backup {
+			pterm.Info.Printfln("The files as they were are backed up in %s", copier.BackupDirectory)
+		}
+		exit(0)
+	}
+}
+
+// spread-addon <addon>: one addon's settings from --from to the other characters
+func spreadAddonCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	from := fs.String("from", "", "the version/account/server/character to copy the addon's settings from, instead of prompting")
+	var dstFlags stringListFlag
+	fs.Var(&dstFlags, "dst", "copy to this version/account/server/character, repeatable and with * for every realm or character (every other character on the source's account by default)")
+	create := fs.Bool("create", false, "with --dst, create the destination's realm and character folders if they don't exist yet")
+	assignProfile := fs.Bool("assign-profile", false, "also switch the destinations to the source's profile (for addons that keep AceDB profiles)")
+	yes := fs.Bool("yes", false, "don't ask for confirmation before overwriting")
+	notifyFlag := fs.Bool("notify", false, "raise a desktop notification when the copy finishes or fails")
+	var copying copyFlags
+	copying.register(fs, "force", "no-backup", "no-verify", "throttle")
+	return func(addonArg string) {
+		if addonArg == "" {
+			fmt.Fprintln(os.Stderr, "spread-addon needs the addon: wow-profile-copy spread-addon <AddonName> --from <version>/<account>/<server>/<character>")
+			os.Exit(2)
+		}
+		s := common.start("spread-addon", *from != "" && *yes)
+		copyOptions := copying.options(s.config)
+		wow := s.resolveInstall()
+		pterm.DefaultHeader.Printfln("WoW Install Directory: %s", wow.Dir)
+		src := resolveSource(wow, s.expandAlias(*from))
+		addon := strings.TrimSuffix(addonArg, ".lua")
+
+		// every other character on the account unless told otherwise, reviewed like any bulk copy
+		dstSpecs := []string(dstFlags)
+		if len(dstSpecs) == 0 {
+			dstSpecs = []string{fmt.Sprintf("%s/%s/%s/%s", src.Version, src.Account, bulkWildcard, bulkWildcard)}
+		}
+		var plans []copyplan.Plan
+		for _, dstSpec := range dstSpecs {
+			for _, dst := range resolveDestinations(wow, s.expandAlias(dstSpec), *create, wow, src, s.config, s.interactive) {
+				plan, err := copyplan.BuildAddon(wow.Install, src, dst, addon, *assignProfile, currentDefaults())
+				if err != nil {
+					fatal(explainFileError(err))
+				}
+				plans = append(plans, plan)
+			}
+		}
+		wow.placeTaggedFiles(plans, s.config)
+		pterm.Info.Printfln("Copying %s's %s settings to %d characters", src.CharacterName(), addon, len(plans))
+		executePlans(wow, plans, copyOptions, s.config, *yes, s.config.Notify || *notifyFlag, false, s.interactive, nil)
+	}
+}
+
+// summary: a readable overview of a character's profile
+func summaryCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	srcFlag := fs.String("src", "", "summarize this version/account/server/character instead of prompting")
+	out := fs.String("out", "", "write the summary to this file instead of stdout")
+	format := fs.String("format", summaryMarkdown, "write it as markdown or html")
+	return func(string) {
+		if err := validateSummaryFormat(*format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		// a summary printed to stdout has to stay readable
+		if *out == "" {
+			pterm.SetDefaultOutput(os.Stderr)
+		}
+		s := common.start("summary", *srcFlag != "")
+		wow := s.resolveInstall()
+		src := resolveSource(wow, s.expandAlias(*srcFlag))
+		summary, err := wow.summarizeProfile(src)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		text := summary.markdown()
+		if *format == summaryHTML {
+			if text, err = summary.html(); err != nil {
+				fatal(err)
+			}
+		}
+		if *out == "" {
+			fmt.Print(text)
+			exit(0)
+		}
+		if err := os.WriteFile(*out, []byte(text), 0644); err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Wrote %s's summary (%d addons, %d macros, %d keybindings, %d changed settings) to %s", src.CharacterName(), len(summary.Addons), len(summary.Macros), len(summary.Bindings), len(summary.CVars), *out)
+		exit(0)
+	}
+}
+
+// lint: anything in a character's settings files the game would reset
+func lintCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	srcFlag := fs.String("src", "", "check this version/account/server/character instead of prompting")
+	return func(string) {
+		s := common.start("lint", *srcFlag != "")
+		wow := s.resolveInstall()
+		src := resolveSource(wow, s.expandAlias(*srcFlag))
+		problems, err := wow.lintTarget(src)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if len(problems) == 0 {
+			pterm.Success.Printfln("%s's settings files look fine", src.CharacterName())
+			exit(0)
+		}
+		for _, problem := range problems {
+			pterm.Warning.Println(problem)
+		}
+		pterm.Error.Printfln("Found %d problems that may make the game reset %s's settings to defaults", len(problems), src.CharacterName())
+		exit(1)
+	}
+}
+
+// convert <file>: a SavedVariables file to JSON and back
+func convertCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	out := fs.String("out", "", "the file to write, instead of the one next to the input")
+	yes := fs.Bool("yes", false, "overwrite the file to write if it exists, without asking")
+	return func(path string) {
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "convert needs the file: wow-profile-copy convert <file.lua|file.json>")
+			os.Exit(2)
+		}
+		s := common.start("convert", true)
+		outPath, converted, err := convertSavedVariables(path, *out)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if _, err := os.Stat(outPath); err == nil && !*yes && !(s.interactive && askConfirm("overwrite", pterm.DefaultInteractiveConfirm.
+			WithDefaultText(fmt.Sprintf("%s already exists, overwrite it?", outPath)))) {
+			fatalf("%s already exists, pass --yes to overwrite it", outPath)
+		}
+		if err := os.WriteFile(outPath, converted, 0644); err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Wrote %s", outPath)
+		exit(0)
+	}
+}
+
+// sign <file>: a detached signature for trusted_keys
+func signCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(path string) {
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "sign needs the file: wow-profile-copy sign <presets.json>")
+			os.Exit(2)
+		}
+		common.start("sign", true)
+		signature, publicKey, err := signFile(path)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		pterm.Success.Printfln("Wrote the signature to %s, hand it out next to %s", signature, filepath.Base(path))
+		pterm.Info.Printfln("To trust it, add your public key to trusted_keys in config.yaml:\n%s", publicKey)
+		exit(0)
+	}
+}
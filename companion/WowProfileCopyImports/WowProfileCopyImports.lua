@@ -0,0 +1,49 @@
+-- hands the strings wow-profile-copy queued in WowProfileCopyImportsPending to each addon's own importer
+-- entries are removed once handed over, anything whose addon isn't loaded stays queued for the next login
+
+local importers = {
+	WeakAuras = function(data)
+		if not WeakAuras or not WeakAuras.Import then
+			return false
+		end
+		WeakAuras.Import(data)
+		return true
+	end,
+	ElvUI = function(data)
+		if not ElvUI then
+			return false
+		end
+		local E = unpack(ElvUI)
+		local distributor = E:GetModule("Distributor")
+		return distributor:ImportProfile(data)
+	end,
+	Plater = function(data)
+		if not Plater or not Plater.ImportScriptString then
+			return false
+		end
+		Plater.ImportScriptString(data, true)
+		return true
+	end,
+}
+
+local frame = CreateFrame("Frame")
+frame:RegisterEvent("PLAYER_LOGIN")
+frame:SetScript("OnEvent", function()
+	if type(WowProfileCopyImportsPending) ~= "table" then
+		WowProfileCopyImportsPending = {}
+		return
+	end
+
+	local remaining = {}
+	for _, entry in ipairs(WowProfileCopyImportsPending) do
+		local importer = importers[entry.addon]
+		local ok, imported = pcall(importer or function() return false end, entry.data)
+		if ok and imported then
+			print("|cff33ff99wow-profile-copy|r: imported a " .. entry.addon .. " string")
+		else
+			print("|cff33ff99wow-profile-copy|r: couldn't import a " .. entry.addon .. " string yet, is the addon enabled?")
+			table.insert(remaining, entry)
+		end
+	end
+	WowProfileCopyImportsPending = remaining
+end)
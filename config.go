@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wow-profile-copy/wtf"
+)
+
+// Config holds user settings read from config.yaml
+// every field is optional, a missing file is the same as an empty one
+type Config struct {
+	// extra places to look for a WoW install, tried before the built-in locations
+	InstallSearchPaths []string `yaml:"install_search_paths"`
+
+	// defaults for every run, the matching flags override them
+	// the install to use without looking for one, like --install-dir
+	InstallDir string `yaml:"install_dir"`
+	// the version (folder or display name) the version prompts start on, until another one is picked there
+	Version string `yaml:"version"`
+	// globs of SavedVariables file names that are never copied, on top of any --exclude
+	Exclude []string `yaml:"exclude"`
+	// what's picked to start with at "What should be copied?", and what's copied without a terminal, like --only
+	Only []string `yaml:"only"`
+	// false copies without backing up the destination first, like --no-backup
+	Backup *bool `yaml:"backup"`
+
+	// output theme: default, high-contrast, colorblind, or monochrome
+	Theme string `yaml:"theme"`
+
+	// raise a desktop notification when a copy finishes or fails
+	Notify bool `yaml:"notify"`
+
+	// where to fetch community presets from, defaults to presets.json in the project repository
+	PresetsURL string `yaml:"presets_url"`
+
+	// never download presets, only use the cached copy (if any) and the built-in lists
+	OfflinePresets bool `yaml:"offline_presets"`
+
+	// paste or storage endpoint that share uploads to with a POST, answering with a code or URL
+	ShareURL string `yaml:"share_url"`
+
+	// characters that are only overwritten after typing their name, as version/account/server/character
+	ProtectedCharacters []string `yaml:"protected_characters"`
+
+	// the character new alts are set up from, --src @template (or --dst @template) refers to it
+	Template string `yaml:"template"`
+
+	// short names for characters, e.g. --dst @alt, each a version/account/server/character
+	Aliases map[string]string `yaml:"aliases"`
+
+	// copies that serve runs when they're triggered, by name
+	Syncs map[string]SyncConfig `yaml:"syncs"`
+
+	// syncs serve runs whenever WowUp or CurseForge finishes updating addons, so alts pick up new ones
+	SyncAfterAddonUpdates []string `yaml:"sync_after_addon_updates"`
+
+	// characters bulk copies leave alone, by the source (template) they're copied from
+	// both are version/account/server/character, and are saved here when deselected at the bulk review
+	BulkExclusions map[string][]string `yaml:"bulk_exclusions"`
+
+	// tags for characters, e.g. their role, by version/account/server/character
+	// characters are also tagged with their class (e.g. priest) when the account's SavedVariables have it
+	CharacterTags map[string][]string `yaml:"character_tags"`
+
+	// files only copied to characters with one of the tags, by file name (globs like WeakAuras*.lua work)
+	TaggedFiles map[string][]string `yaml:"tagged_files"`
+
+	// extra search and replace rules for the strings in copied SavedVariables, after the character and realm names
+	RewriteRules []RewriteRuleConfig `yaml:"rewrite_rules"`
+
+	// public keys (as share --sign and sign print them) whose signatures are trusted
+	// once there's one, presets and shares are only used when one of these signed them
+	TrustedKeys []string `yaml:"trusted_keys"`
+}
+
+// a copy set up ahead of time, run without prompts as if its values were passed as flags
+type SyncConfig struct {
+	InstallDir string   `yaml:"install_dir"`
+	Src        string   `yaml:"src"`
+	Dst        []string `yaml:"dst"`
+}
+
+// one of rewrite_rules, e.g. an old guild name to the new one
+type RewriteRuleConfig struct {
+	From  string `yaml:"from"`
+	To    string `yaml:"to"`
+	Regex bool   `yaml:"regex"` // from is a regular expression, and to can use its groups as $1
+}
+
+// whether target is one of the protected characters
+func (c Config) isProtected(target wtf.Target) bool {
+	for _, spec := range c.ProtectedCharacters {
+		if targetMatchesSpec(spec, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// whether bulk copies from src should leave target alone
+func (c Config) isBulkExcluded(src wtf.Target, target wtf.Target) bool {
+	for source, excluded := range c.BulkExclusions {
+		if !targetMatchesSpec(source, src) {
+			continue
+		}
+		for _, spec := range excluded {
+			if targetMatchesSpec(spec, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whether a version/account/server/character spec names target, versions can be folder or display names
+func targetMatchesSpec(spec string, target wtf.Target) bool {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 4 {
+		return false
+	}
+	if resolveVersionName(parts[0]) != target.Version {
+		return false
+	}
+	// names match regardless of case, the same as --src and --dst
+	character, _ := matchWtfCase(wtf.Character{Account: parts[1], Server: parts[2], Name: parts[3]}, []wtf.Character{target.Character})
+	return character != nil
+}
+
+// where config.yaml lives, e.g. ~/.config/wow-profile-copy/config.yaml
+func configFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// reads the user's config file, if there is one
+func loadConfig() (Config, error) {
+	var config Config
+
+	path, err := configFilePath()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := validateDocument(data, _configSchema); err != nil {
+		return config, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if _, err := config.rewriteRules(); err != nil {
+		return config, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, pattern := range config.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return config, fmt.Errorf("reading %s: %q in exclude isn't a valid glob: %w", path, pattern, err)
+		}
+	}
+	return config, nil
+}
+
+// sets key in config.yaml to value, keeping the rest of the file (and its comments) as it is
+func saveConfigValue(key string, value any) error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s isn't a mapping of settings", path)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1] = &node
+			replaced = true
+		}
+	}
+	if !replaced {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
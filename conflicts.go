@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+)
+
+// files bigger than this are usually an addon's history, not its settings
+const conflictSizeThreshold = 10 << 20
+
+// what to do about a conflicting step
+type conflictResolution string
+
+const (
+	resolveBackupAndOverwrite conflictResolution = "Back up and overwrite"
+	resolveOverwrite          conflictResolution = "Overwrite"
+	resolveSkip               conflictResolution = "Skip"
+)
+
+// a step that probably shouldn't be copied blindly
+type copyConflict struct {
+	step    copyplan.Step
+	reasons []string
+}
+
+// finds steps whose destination is newer than the source, whose addon isn't installed on the destination,
+// or that are large enough to be worth a second look
+func (wow WowInstall) findConflicts(plan copyplan.Plan) []copyConflict {
+	dstAddOns := filepath.Join(wow.Dir, plan.Destination.Version, "Interface", "AddOns")
+	_, err := os.Stat(dstAddOns)
+	checkAddOns := err == nil
+
+	var conflicts []copyConflict
+	for _, step := range plan.Steps {
+		var reasons []string
+
+		srcInfo, srcErr := plan.SourceFiles().Stat(step.Src)
+		dstInfo, dstErr := os.Stat(step.Dst)
+		if srcErr == nil && dstErr == nil && dstInfo.ModTime().After(srcInfo.ModTime()) {
+			reasons = append(reasons, fmt.Sprintf("the destination is newer (%s)", dstInfo.ModTime().Format(time.RFC822)))
+		}
+
+		// Blizzard_ SavedVariables belong to the game's own addons, which never show up in Interface/AddOns
+		isSavedVariables := step.Category == copyplan.CategoryAccountSavedVariables || step.Category == copyplan.CategoryCharacterSavedVariables
+		addon := strings.TrimSuffix(filepath.Base(step.Dst), ".lua")
+		if checkAddOns && isSavedVariables && !plan.Reviewed[step.Dst] && !strings.HasPrefix(addon, "Blizzard_") && !wow.AddonInstalled(plan.Destination.Version, addon) {
+			reasons = append(reasons, fmt.Sprintf("%s isn't installed on the destination", addon))
+		}
+
+		if step.Size > conflictSizeThreshold {
+			reasons = append(reasons, fmt.Sprintf("it's %s", formatBytes(step.Size)))
+		}
+
+		if len(reasons) > 0 {
+			conflicts = append(conflicts, copyConflict{step, reasons})
+		}
+	}
+	return conflicts
+}
+
+// asks how to handle every conflict, either all at once or file by file
+func promptForConflictResolutions(conflicts []copyConflict) map[string]conflictResolution {
+	table := [][]string{{"File", "Why"}}
+	for _, conflict := range conflicts {
+		table = append(table, []string{conflict.step.Dst, strings.Join(conflict.reasons, ", ")})
+	}
+	pterm.Warning.Printfln("%d files need a decision before copying:", len(conflicts))
+	pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+
+	const decideEach = "Decide for each file"
+	choices := []string{string(resolveBackupAndOverwrite), string(resolveOverwrite), string(resolveSkip), decideEach}
+	choice := askSelect("conflicts", pterm.DefaultInteractiveSelect.
+		WithOptions(choices).
+		WithDefaultText("What should happen to these files?"))
+
+	resolutions := make(map[string]conflictResolution)
+	for _, conflict := range conflicts {
+		resolution := conflictResolution(choice)
+		if choice == decideEach {
+			picked := askSelect("conflict", pterm.DefaultInteractiveSelect.
+				WithOptions(choices[:3]).
+				WithDefaultText(fmt.Sprintf("%s (%s)", conflict.step.Dst, strings.Join(conflict.reasons, ", "))))
+			resolution = conflictResolution(picked)
+		}
+		resolutions[conflict.step.Dst] = resolution
+	}
+	return resolutions
+}
+
+// applies resolutions to plan: skipped steps move to plan.skipped, backed up ones are added to the copier's forced backups
+func applyConflictResolutions(p *copyplan.Plan, resolutions map[string]conflictResolution, copier *copyplan.Copier) {
+	var steps []copyplan.Step
+	for _, step := range p.Steps {
+		switch resolutions[step.Dst] {
+		case resolveSkip:
+			p.Skipped = append(p.Skipped, copyplan.SkippedFile{Path: step.Src, Reason: "you chose to skip it"})
+			continue
+		case resolveBackupAndOverwrite:
+			copier.ForceBackup[step.Dst] = true
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
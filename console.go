@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pterm/pterm"
+)
+
+// whether to wait for Enter before the process exits, so a double-clicked console doesn't vanish
+var pauseBeforeExit bool
+
+// cleanup that has to happen however we exit, e.g. releasing the install lock
+// hooks get the exit code, and run in reverse order of registration
+var exitHooks []func(code int)
+
+func onExit(hook func(code int)) {
+	exitHooks = append(exitHooks, hook)
+}
+
+// runs the exit hooks, pauses if needed, then exits with code
+// use this instead of os.Exit or log.Fatal so error paths also keep the console open
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i](code)
+	}
+	if pauseBeforeExit {
+		fmt.Println("Press Enter to continue...")
+		fmt.Scanln()
+	}
+	os.Exit(code)
+}
+
+func fatal(err error) {
+	pterm.Error.Println(err)
+	exit(1)
+}
+
+func fatalf(format string, args ...any) {
+	pterm.Error.Printfln(format, args...)
+	exit(1)
+}
+
+// how tall interactive selects can be, recalculated for every prompt so a resized terminal is picked up
+func selectMaxHeight() int {
+	const maxHeight = 15
+	const minHeight = 3
+	// leave room for the prompt text, the hidden options hint, and what's already on screen
+	height := pterm.GetTerminalHeight() - 6
+	if height > maxHeight {
+		return maxHeight
+	}
+	if height < minHeight {
+		return minHeight
+	}
+	return height
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// there's no portable way to open a terminal window outside of windows
+func relaunchInConsole() bool {
+	return false
+}
+
+// there's no file manager double-click to worry about, the terminal outlives us
+func ownsConsole() bool {
+	return false
+}
+
+// the xterm title escape works in just about every terminal emulator
+func setConsoleTitle(title string) {
+	fmt.Printf("\033]0;%s\007", title)
+}
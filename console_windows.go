@@ -0,0 +1,51 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleProcessList = kernel32.NewProc("GetConsoleProcessList")
+	procSetConsoleTitleW      = kernel32.NewProc("SetConsoleTitleW")
+)
+
+const relaunchedEnv = "WOW_PROFILE_COPY_RELAUNCHED"
+
+// re-runs this executable in a new console window, for when we were started without one
+// returns false if that isn't possible, or we've already tried once
+func relaunchInConsole() bool {
+	if os.Getenv(relaunchedEnv) != "" {
+		return false
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return false
+	}
+
+	args := append([]string{"/C", "start", "wow-profile-copy", exe}, os.Args[1:]...)
+	cmd := exec.Command("cmd.exe", args...)
+	cmd.Env = append(os.Environ(), relaunchedEnv+"=1")
+	return cmd.Start() == nil
+}
+
+// when explorer starts us, we're the only process attached to the console
+// from cmd or powershell, the shell is attached too
+func ownsConsole() bool {
+	var pids [2]uint32
+	count, _, _ := procGetConsoleProcessList.Call(uintptr(unsafe.Pointer(&pids[0])), uintptr(len(pids)))
+	return count == 1
+}
+
+func setConsoleTitle(title string) {
+	titlePtr, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		return
+	}
+	procSetConsoleTitleW.Call(uintptr(unsafe.Pointer(titlePtr)))
+}
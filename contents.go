@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+)
+
+// turns --only's comma separated names into the set of content to copy, nil (everything) when it's empty
+func parseCopyContents(only string) (map[string]bool, error) {
+	if only == "" {
+		return nil, nil
+	}
+	var names []string
+	for _, content := range copyplan.Contents {
+		names = append(names, content.Name)
+	}
+	picked := make(map[string]bool)
+	for _, name := range strings.Split(only, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, content := range copyplan.Contents {
+			known = known || content.Name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("--only doesn't know %q, expected any of %s", name, strings.Join(names, ", "))
+		}
+		picked[name] = true
+	}
+	return picked, nil
+}
+
+// asks what to copy, preselected (or everything, when it's nil) is picked to begin with
+func promptForCopyContents(preselected map[string]bool) map[string]bool {
+	var labels, selected []string
+	for _, content := range copyplan.Contents {
+		labels = append(labels, content.Label)
+		if preselected == nil || preselected[content.Name] {
+			selected = append(selected, content.Label)
+		}
+	}
+	chosen := askMultiselect("contents", pterm.DefaultInteractiveMultiselect.
+		WithOptions(labels).
+		WithDefaultOptions(selected).
+		WithDefaultText("What should be copied?").
+		WithMaxHeight(selectMaxHeight()))
+
+	picked := make(map[string]bool)
+	for _, label := range chosen {
+		for _, content := range copyplan.Contents {
+			if content.Label == label {
+				picked[content.Name] = true
+			}
+		}
+	}
+	return picked
+}
+
+// what plans copy, for the confirmation, like "Keybindings, Macros, and Character SavedVariables"
+func describeCopyContents(plans []copyplan.Plan) string {
+	copied := make(map[string]bool)
+	for _, plan := range plans {
+		for _, step := range plan.Steps {
+			copied[copyplan.StepContent(step).Name] = true
+		}
+	}
+	var names []string
+	for _, content := range copyplan.Contents {
+		if copied[content.Name] {
+			names = append(names, content.Short)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "nothing"
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " and " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
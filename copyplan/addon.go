@@ -0,0 +1,127 @@
+package copyplan
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// an entry of an AceDB profileKeys table, ["Name - Realm"] = "Profile",
+var _profileKeyPattern = regexp.MustCompile(`^(\s*)\["(.*)"\] = "(.*)",\s*$`)
+
+// BuildAddon works out copying only addon's character SavedVariables from src to dst in install
+// with assignProfile, the destination is also given the source's AceDB profile, which only works on the same
+// account since the profiles themselves are in the account's SavedVariables
+func BuildAddon(install wowinstall.Install, src wtf.Target, dst wtf.Target, addon string, assignProfile bool, defaults wtf.Defaults) (Plan, error) {
+	plan := Plan{SourceInstallDirectory: install.Dir, SourceStore: install.Files(), Source: src, Destination: dst, Flavors: defaults.Flavors}
+	if src == dst {
+		return plan, ErrSameSourceAndDestination
+	}
+
+	file := addon + ".lua"
+	srcDir := filepath.Join(install.CharacterPath(src), "SavedVariables")
+	dstDir := filepath.Join(install.CharacterPath(dst), "SavedVariables")
+	translated := TranslateSavedVariablesName(install, file, src.Version, dst.Version, defaults)
+	if err := plan.addFiles(CategoryCharacterSavedVariables, srcDir, dstDir, []string{file}); err != nil {
+		return plan, err
+	}
+	if len(plan.Steps) == 0 {
+		return plan, fmt.Errorf("%s has no character SavedVariables for %s (%s)", src.CharacterName(), addon, filepath.Join(srcDir, file))
+	}
+	plan.Steps[0].Dst = filepath.Join(dstDir, translated)
+	plan.Rewrites = IdentityRewriteRules(src, dst)
+
+	if assignProfile {
+		if src.Version != dst.Version || src.Account != dst.Account {
+			plan.Skipped = append(plan.Skipped, SkippedFile{filepath.Join(install.AccountPath(dst), "SavedVariables", translated), "profiles can only be assigned on the source's account, the destination's account doesn't have them"})
+		} else {
+			plan.ProfileKeys = filepath.Join(install.AccountPath(dst), "SavedVariables", translated)
+		}
+	}
+	return plan, nil
+}
+
+// gives dst the profile src uses in every AceDB profileKeys table in data
+func assignProfile(data []byte, src wtf.Target, dst wtf.Target) ([]byte, error) {
+	srcKey := src.Name + " - " + src.Server
+	dstKey := dst.Name + " - " + dst.Server
+
+	lines := strings.Split(string(data), "\n")
+	var out []string
+	found := false
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if !strings.Contains(lines[i], `["profileKeys"] = {`) {
+			continue
+		}
+
+		// the table's entries run up to its closing brace
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "}") {
+			end++
+		}
+		entries := lines[i+1 : end]
+		var profile, indent string
+		haveProfile := false
+		for _, entry := range entries {
+			if match := _profileKeyPattern.FindStringSubmatch(entry); match != nil && match[2] == srcKey {
+				indent, profile, haveProfile = match[1], match[3], true
+			}
+		}
+		if !haveProfile {
+			out = append(out, entries...)
+			i = end - 1
+			continue
+		}
+		found = true
+
+		assigned := fmt.Sprintf(`%s["%s"] = "%s",`, indent, dstKey, profile)
+		replaced := false
+		for _, entry := range entries {
+			if match := _profileKeyPattern.FindStringSubmatch(entry); match != nil && match[2] == dstKey {
+				entry, replaced = assigned, true
+			}
+			out = append(out, entry)
+		}
+		if !replaced {
+			out = append(out, assigned)
+		}
+		i = end - 1
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no AceDB profile to assign", src.CharacterName())
+	}
+	return []byte(strings.Join(out, "\n")), nil
+}
+
+// assigns plan's source profile to its destination in plan.ProfileKeys, logging it under operation
+// watch makes sure nothing else touches the file between our read and write
+func assignPlanProfile(plan Plan, watch *Watch, operation string, log Log) error {
+	path := plan.ProfileKeys
+	if err := watch.CheckUnchanged(path); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	assigned, err := assignProfile(data, plan.Source, plan.Destination)
+	if err != nil {
+		log.printf(LevelWarning, operation, "Couldn't assign a profile in %s: %s", path, err)
+		return nil
+	}
+	if bytes.Equal(assigned, data) {
+		return nil
+	}
+	if err := os.WriteFile(path, assigned, 0666); err != nil {
+		return err
+	}
+	log.printf(LevelInfo, operation, "Gave %s %s's profile in %s", plan.Destination.CharacterName(), plan.Source.CharacterName(), path)
+	return watch.RecordWrite(path)
+}
@@ -0,0 +1,64 @@
+package copyplan
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// BindingsFileName is where the game keeps keybindings, in the account folder, or in the character folder when
+// "Character Specific Key Bindings" is ticked
+const BindingsFileName = "bindings-cache.wtf"
+
+// where Options.BindingsScope puts the copied keybindings, "" keeps the scope the source uses
+const (
+	BindingsScopeAccount   = "account"
+	BindingsScopeCharacter = "character"
+)
+
+// makes the plan copy the keybindings the source actually plays with (its character bindings if it has any,
+// its account bindings otherwise) into the scope the destination should use them from
+// scope "" keeps the source's scope, account promotes character bindings to the destination's account (and
+// overwrites any character bindings it has, so they can't shadow the copied ones), and character demotes them
+// to the destination character, leaving the destination account's bindings for its other characters alone
+func (p *Plan) placeBindings(scope string, srcAccountPath string, dstAccountPath string, srcCharacterPath string, dstCharacterPath string) error {
+	// the account file comes along with the other account files, take it back out to decide afresh
+	var steps []Step
+	for _, step := range p.Steps {
+		if step.Src != filepath.Join(srcAccountPath, BindingsFileName) {
+			steps = append(steps, step)
+		}
+	}
+	p.Steps = steps
+
+	srcDir := srcAccountPath
+	sourceScope := BindingsScopeAccount
+	if _, err := p.SourceFiles().Stat(filepath.Join(srcCharacterPath, BindingsFileName)); err == nil {
+		srcDir = srcCharacterPath
+		sourceScope = BindingsScopeCharacter
+	}
+	if scope == "" {
+		if sourceScope == BindingsScopeCharacter {
+			return p.addFiles(CategoryCharacterConfig, srcDir, dstCharacterPath, []string{BindingsFileName})
+		}
+		return p.addAccountBindings(srcDir, dstAccountPath)
+	}
+
+	if scope == BindingsScopeCharacter {
+		return p.addFiles(CategoryCharacterConfig, srcDir, dstCharacterPath, []string{BindingsFileName})
+	}
+	if err := p.addAccountBindings(srcDir, dstAccountPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dstCharacterPath, BindingsFileName)); err == nil {
+		return p.addFiles(CategoryCharacterConfig, srcDir, dstCharacterPath, []string{BindingsFileName})
+	}
+	return nil
+}
+
+// copies the bindings in srcDir to the destination account, unless they already are the destination account's
+func (p *Plan) addAccountBindings(srcDir string, dstAccountPath string) error {
+	if filepath.Join(srcDir, BindingsFileName) == filepath.Join(dstAccountPath, BindingsFileName) {
+		return nil
+	}
+	return p.addFiles(CategoryAccountConfig, srcDir, dstAccountPath, []string{BindingsFileName})
+}
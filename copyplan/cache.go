@@ -0,0 +1,91 @@
+package copyplan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// which WTF directory a post-copy step operates in
+type wtfScope int
+
+const (
+	accountScope wtfScope = iota
+	characterScope
+)
+
+// a file (or glob) removed from the destination once copying finishes
+// the client trusts these over the freshly copied files, so stale ones undo the copy
+type cacheInvalidation struct {
+	scope   wtfScope
+	pattern string
+}
+
+// cache.md5 holds checksums of the previous files, so the client thinks ours are corrupt and resets them
+// SavedVariables *.bak files are what the client falls back to if it decides a .lua file is broken
+var _modernClientCacheInvalidations = []cacheInvalidation{
+	{accountScope, "cache.md5"},
+	{characterScope, "cache.md5"},
+	{accountScope, filepath.Join("SavedVariables", "*.lua.bak")},
+	{characterScope, filepath.Join("SavedVariables", "*.lua.bak")},
+}
+
+// post-copy steps for each WoW version
+// versions missing from this map only get cache.md5 removed
+var _cacheInvalidationsByVersion = map[string][]cacheInvalidation{
+	"_classic_":        _modernClientCacheInvalidations,
+	"_classic_ptr_":    _modernClientCacheInvalidations,
+	"_classic_beta_":   _modernClientCacheInvalidations,
+	"_classic_era_":    _modernClientCacheInvalidations,
+	"_classic_era_ptr": _modernClientCacheInvalidations,
+	"_retail_":         _modernClientCacheInvalidations,
+	"_ptr_":            _modernClientCacheInvalidations,
+}
+
+var _fallbackCacheInvalidations = []cacheInvalidation{
+	{accountScope, "cache.md5"},
+	{characterScope, "cache.md5"},
+}
+
+func cacheInvalidationsFor(version string) []cacheInvalidation {
+	if steps, ok := _cacheInvalidationsByVersion[version]; ok {
+		return steps
+	}
+	return _fallbackCacheInvalidations
+}
+
+// CacheInvalidationPatterns are the files (globs) the client trusts over freshly copied ones, for version, under the
+// destination's account and character directories
+func CacheInvalidationPatterns(version string, accountPath string, characterPath string) []string {
+	var patterns []string
+	for _, step := range cacheInvalidationsFor(version) {
+		dir := accountPath
+		if step.scope == characterScope {
+			dir = characterPath
+		}
+		patterns = append(patterns, filepath.Join(dir, step.pattern))
+	}
+	return patterns
+}
+
+// InvalidateCaches removes every file matching patterns
+// calls removed for every file actually deleted; files that are already gone are not an error
+func InvalidateCaches(patterns []string, removed func(path string)) error {
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, path := range matches {
+			err := os.Remove(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			removed(path)
+		}
+	}
+	return nil
+}
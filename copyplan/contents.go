@@ -0,0 +1,62 @@
+package copyplan
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Content is something that can be picked to copy, or left out, on its own
+type Content struct {
+	Name  string // for --only
+	Label string // for the prompt
+	Short string // for the confirmation
+}
+
+// Contents are everything that can be picked, in the order they're offered
+var Contents = []Content{
+	{"keybindings", "Keybindings (bindings-cache.wtf)", "Keybindings"},
+	{"macros", "Macros (macros-cache.txt)", "Macros"},
+	{"settings", "Game settings (config-cache.wtf)", "Settings"},
+	{"addons", "Enabled addons (AddOns.txt)", "AddOns.txt"},
+	{"layout", "Window layout (layout-local.txt)", "Layout"},
+	{"account-savedvariables", "Account SavedVariables", "Account SavedVariables"},
+	{"character-savedvariables", "Character SavedVariables", "Character SavedVariables"},
+}
+
+// StepContent is the content a step copies
+func StepContent(step Step) Content {
+	switch step.Category {
+	case CategoryAccountSavedVariables:
+		return Contents[5]
+	case CategoryCharacterSavedVariables:
+		return Contents[6]
+	}
+	switch strings.ToLower(filepath.Base(step.Dst)) {
+	case BindingsFileName:
+		return Contents[0]
+	case MacrosFileName:
+		return Contents[1]
+	case ConfigCacheFileName:
+		return Contents[2]
+	case "addons.txt":
+		return Contents[3]
+	}
+	return Contents[4]
+}
+
+// moves the steps copying anything that wasn't picked to p.Skipped, picked nil keeps everything
+func (p *Plan) keepContents(picked map[string]bool) {
+	if picked == nil {
+		return
+	}
+	var steps []Step
+	for _, step := range p.Steps {
+		if content := StepContent(step); !picked[content.Name] {
+			p.Skipped = append(p.Skipped, SkippedFile{step.Src, fmt.Sprintf("%s wasn't picked to copy", content.Short)})
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
@@ -0,0 +1,252 @@
+package copyplan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// CopyOptions controls how destructive a sync is allowed to be
+type CopyOptions struct {
+	Backup    bool // snapshot destination files before they are overwritten
+	SkipRisky bool // skip the defaults' CrossCharacterAccountSavedVariables unless the user opts back in
+	Verify    bool // re-read every written file and compare it against the source
+
+	NormalizeText bool // strip BOMs and unify line endings of copied .wtf and .txt files, see normalizeTextFiles
+
+	BytesPerSecond int64 // limits copy and backup speed, 0 for no limit
+}
+
+// SafeCopyOptions is the default profile - everything that keeps a bad copy recoverable is turned on
+func SafeCopyOptions() CopyOptions {
+	return CopyOptions{
+		Backup:    true,
+		SkipRisky: true,
+		Verify:    true,
+	}
+}
+
+// ForceCopyOptions is raw full-overwrite behavior
+func ForceCopyOptions() CopyOptions {
+	return CopyOptions{}
+}
+
+// Copier copies files into a WoW install, honouring a set of CopyOptions
+type Copier struct {
+	Options          CopyOptions
+	InstallDirectory string
+	BackupDirectory  string
+	Watch            *Watch          // optional, aborts the copy if dst changes underneath us
+	LockedFiles      []string        // destinations that stayed locked by another process through every retry
+	ForceBackup      map[string]bool // destinations to back up even when Options.Backup is off
+	Source           wtf.Store       // where copied files are read from, nil for the local disk
+}
+
+// NewCopier creates a Copier whose backups land in a fresh timestamped directory under backupsRoot
+// the directory is only created once something is actually backed up
+func NewCopier(opts CopyOptions, installDirectory string, backupsRoot string) *Copier {
+	return &Copier{
+		Options:          opts,
+		InstallDirectory: installDirectory,
+		BackupDirectory:  filepath.Join(backupsRoot, time.Now().Format("20060102-150405")),
+		ForceBackup:      make(map[string]bool),
+	}
+}
+
+// Copy copies src to dst, backing up and verifying dst as configured
+// files that stay locked are remembered in LockedFiles, callers can check for that with wtf.IsSharingViolation
+func (c *Copier) Copy(src string, dst string) error {
+	if err := c.prepare(dst); err != nil {
+		return err
+	}
+
+	err := RetryLocked(func() error {
+		_, err := CopyStoreFile(c.SourceFiles(), src, dst, c.Options.BytesPerSecond)
+		return err
+	})
+	if err != nil {
+		if wtf.IsSharingViolation(err) {
+			c.LockedFiles = append(c.LockedFiles, dst)
+		}
+		return err
+	}
+	return c.finish(src, dst)
+}
+
+// everything that has to happen before dst is overwritten: checking nobody else touched it, and backing it up
+func (c *Copier) prepare(dst string) error {
+	if c.Watch != nil {
+		if err := c.Watch.CheckUnchanged(dst); err != nil {
+			return err
+		}
+	}
+
+	if c.Options.Backup || c.ForceBackup[dst] {
+		if err := RetryLocked(func() error { return c.BackupFile(dst) }); err != nil {
+			if wtf.IsSharingViolation(err) {
+				c.LockedFiles = append(c.LockedFiles, dst)
+			}
+			return fmt.Errorf("backing up %s: %w", dst, err)
+		}
+	}
+
+	// destinations can be characters whose folders don't exist yet
+	return os.MkdirAll(filepath.Dir(dst), 0755)
+}
+
+// everything that has to happen once src has been written to dst
+func (c *Copier) finish(src string, dst string) error {
+	if c.Watch != nil {
+		if err := c.Watch.RecordWrite(dst); err != nil {
+			return err
+		}
+	}
+
+	if c.Options.Verify {
+		if err := VerifyCopy(c.SourceFiles(), src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackupFile saves the current contents of path under the backup directory, mirroring its location in the install
+// files that don't exist yet have nothing to lose, and files already backed up this run keep their first,
+// untouched copy, so they are skipped
+func (c *Copier) BackupFile(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	relativePath, err := filepath.Rel(c.InstallDirectory, path)
+	if err != nil {
+		return err
+	}
+	backupPath := filepath.Join(c.BackupDirectory, relativePath)
+	if _, err := os.Stat(backupPath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return err
+	}
+	_, err = CopyFile(path, backupPath, c.Options.BytesPerSecond)
+	return err
+}
+
+// SnapshotTarget backs up every file in target's account and character folders and their SavedVariables into c's
+// backup directory, returning how many files it backed up
+func (c *Copier) SnapshotTarget(install wowinstall.Install, target wtf.Target) (int, error) {
+	count := 0
+	for _, dir := range []string{install.AccountPath(target), install.CharacterPath(target)} {
+		for _, folder := range []string{dir, filepath.Join(dir, "SavedVariables")} {
+			entries, err := os.ReadDir(folder)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				if err := RetryLocked(func() error { return c.BackupFile(filepath.Join(folder, entry.Name())) }); err != nil {
+					return count, err
+				}
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// SourceFiles is the store copied files are read from
+func (c *Copier) SourceFiles() wtf.Store {
+	if c.Source == nil {
+		return wtf.LocalStore{}
+	}
+	return c.Source
+}
+
+// VerifyCopy compares the sha256 of src in store and dst on the local disk, returning an error if they differ
+func VerifyCopy(store wtf.Store, src string, dst string) error {
+	srcHash, err := HashFile(store, src)
+	if err != nil {
+		return err
+	}
+	dstHash, err := HashFile(wtf.LocalStore{}, dst)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(srcHash, dstHash) {
+		return fmt.Errorf("verification failed: %s does not match %s after copying", dst, src)
+	}
+	return nil
+}
+
+// HashFile is the sha256 of path in store
+func HashFile(store wtf.Store, path string) ([]byte, error) {
+	fileHandle, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandle.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fileHandle); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// CopyFile copies src to dest on the local disk
+// bytesPerSecond limits the copy speed, 0 means as fast as possible
+func CopyFile(src string, dest string, bytesPerSecond int64) (bytes int64, err error) {
+	return CopyStoreFile(wtf.LocalStore{}, src, dest, bytesPerSecond)
+}
+
+// CopyStoreFile copies src from store to dest on the local disk
+func CopyStoreFile(store wtf.Store, src string, dest string, bytesPerSecond int64) (bytes int64, err error) {
+	srcFileHandle, err := store.Open(src)
+	if err != nil {
+		return -1, err
+	}
+	defer srcFileHandle.Close()
+	before, err := srcFileHandle.Stat()
+	if err != nil {
+		return -1, err
+	}
+
+	dstFileHandle, err := os.Create(dest)
+	if err != nil {
+		return -1, err
+	}
+	defer dstFileHandle.Close()
+
+	var reader io.Reader = srcFileHandle
+	if bytesPerSecond > 0 {
+		reader = newThrottledReader(srcFileHandle, bytesPerSecond)
+	}
+	bytes, err = io.Copy(dstFileHandle, reader)
+	if err != nil {
+		return bytes, err
+	}
+
+	// not every writer honours our lock, so double check nothing wrote to src while we read it
+	after, err := store.Stat(src)
+	if err != nil {
+		return bytes, err
+	}
+	if wtf.SourceChanged(before, after) {
+		return bytes, &os.PathError{Op: "copy", Path: src, Err: wtf.ErrSourceChanged}
+	}
+	return bytes, nil
+}
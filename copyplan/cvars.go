@@ -0,0 +1,91 @@
+package copyplan
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wow-profile-copy/wtf"
+)
+
+// ConfigCacheFileName is where the client keeps its console variables (CVars), as lines of SET name "value"
+const ConfigCacheFileName = "config-cache.wtf"
+
+// CVarAllowlist is the CVars a copy from srcVersion to dstVersion keeps, from the defaults' CVarsByFlavor, nil
+// keeps every one
+func CVarAllowlist(srcVersion string, dstVersion string, defaults wtf.Defaults) []string {
+	if FlavorFamily(srcVersion) == FlavorFamily(dstVersion) {
+		return nil
+	}
+	return defaults.CVarsByFlavor[FlavorFamily(dstVersion)]
+}
+
+// FilterCVars drops the SET lines of data whose CVar isn't in allowlist, returning what's left and how many were
+// dropped
+// CVar names aren't case sensitive, and anything that isn't a SET line is kept as it is
+func FilterCVars(data []byte, allowlist []string) ([]byte, int) {
+	var patterns []string
+	for _, pattern := range allowlist {
+		patterns = append(patterns, strings.ToLower(pattern))
+	}
+
+	var kept [][]byte
+	dropped := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "SET") && !MatchesAnyPattern(strings.ToLower(fields[1]), patterns) {
+			dropped++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return bytes.Join(kept, nil), dropped
+}
+
+// CVarFilteredFiles are the copied config-cache.wtf files the CVar allowlist applies to
+func (p Plan) CVarFilteredFiles() []string {
+	var paths []string
+	for _, step := range p.Steps {
+		if filepath.Base(step.Dst) == ConfigCacheFileName && step.Src != step.Dst {
+			paths = append(paths, step.Dst)
+		}
+	}
+	return paths
+}
+
+// removes the CVars allowlist doesn't keep from every file in paths, logging them under operation
+// watch (if set) makes sure nothing else touches a file between our read and write
+func filterConfigCaches(paths []string, allowlist []string, watch *Watch, operation string, log Log) error {
+	for _, path := range paths {
+		if watch != nil {
+			if err := watch.CheckUnchanged(path); err != nil {
+				return err
+			}
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// locked files that were skipped
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		filtered, dropped := FilterCVars(data, allowlist)
+		if dropped == 0 {
+			continue
+		}
+		if err := os.WriteFile(path, filtered, 0666); err != nil {
+			return err
+		}
+		if watch != nil {
+			if err := watch.RecordWrite(path); err != nil {
+				return err
+			}
+		}
+		log.printf(LevelInfo, operation, "Removed %d CVars the destination's client doesn't know from %s", dropped, path)
+	}
+	return nil
+}
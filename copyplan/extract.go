@@ -0,0 +1,140 @@
+package copyplan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+// the keys addons file a character's data under in account SavedVariables, the forms IdentityRewriteRules knows
+// and DataStore's "Default.Realm.Name"
+func characterKeys(target wtf.Target) []string {
+	return []string{
+		target.Name + "-" + target.Server,
+		target.Name + " - " + target.Server,
+		target.Server + " - " + target.Name,
+		"Default." + target.Server + "." + target.Name,
+	}
+}
+
+// one character's data found in a SavedVariables file, and the keys leading to it from the global's value
+type characterSubtree struct {
+	global string
+	path   []savedvars.Value
+	value  savedvars.Value
+}
+
+// every subtree of globals keyed by src, as "Name-Realm" (and the other characterKeys) or nested as [Realm][Name],
+// with the keys leading to it turned into dst's
+// AceDB profileKeys entries bring along the profile they name, when dstGlobals doesn't have one by that name yet
+func findCharacterSubtrees(globals []savedvars.Global, dstGlobals []savedvars.Global, src wtf.Target, dst wtf.Target) []characterSubtree {
+	srcKeys, dstKeys := characterKeys(src), characterKeys(dst)
+	var found []characterSubtree
+	var walk func(global string, path []savedvars.Value, table *savedvars.Table)
+	walk = func(global string, path []savedvars.Value, table *savedvars.Table) {
+		for _, entry := range table.Entries {
+			key, _ := entry.Key.(string)
+			matched := -1
+			for i, srcKey := range srcKeys {
+				if key == srcKey {
+					matched = i
+				}
+			}
+			switch nested, _ := entry.Value.(*savedvars.Table); {
+			case matched >= 0:
+				found = append(found, characterSubtree{global, append(append([]savedvars.Value{}, path...), dstKeys[matched]), entry.Value})
+				if profile, ok := entry.Value.(string); ok && len(path) > 0 && path[len(path)-1] == "profileKeys" {
+					profilesPath := append(append([]savedvars.Value{}, path[:len(path)-1]...), "profiles", profile)
+					value := savedvars.Lookup(globals, global, profilesPath)
+					if value != nil && savedvars.Lookup(dstGlobals, global, profilesPath) == nil {
+						found = append(found, characterSubtree{global, profilesPath, value})
+					}
+				}
+			case key == src.Server && nested != nil && nested.Get(src.Name) != nil:
+				found = append(found, characterSubtree{global, append(append([]savedvars.Value{}, path...), dst.Server, dst.Name), nested.Get(src.Name)})
+			case nested != nil:
+				walk(global, append(path, entry.Key), nested)
+			}
+		}
+	}
+	for _, global := range globals {
+		if table, ok := global.Value.(*savedvars.Table); ok {
+			walk(global.Name, nil, table)
+		}
+	}
+	return found
+}
+
+// ExtractCharacterData merges src's data in srcData, an account SavedVariables file, into dstData under dst's keys,
+// leaving every other character's data in dstData alone, rules are applied to what's merged in
+// returns the merged file and the keys it merged, dstData is unchanged when src has no data of its own in srcData
+func ExtractCharacterData(srcData []byte, dstData []byte, src wtf.Target, dst wtf.Target, rules []savedvars.Rule) ([]byte, []string, error) {
+	srcGlobals, err := savedvars.Parse(srcData)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstGlobals, err := savedvars.Parse(dstData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var merged []string
+	for _, subtree := range findCharacterSubtrees(srcGlobals, dstGlobals, src, dst) {
+		value, err := savedvars.RewriteValue(subtree.value, rules)
+		if err != nil {
+			return nil, nil, err
+		}
+		dstGlobals = savedvars.SetPath(dstGlobals, subtree.global, subtree.path, value)
+		merged = append(merged, savedvars.FormatPath(subtree.global, subtree.path))
+	}
+	if len(merged) == 0 {
+		return dstData, nil, nil
+	}
+	return savedvars.Format(dstGlobals), merged, nil
+}
+
+// merges the source character's data of each of plan's extractions into its destination, logging it under
+// operation, watch makes sure nothing else touches the files between our read and write
+func extractPlanCharacterData(plan Plan, watch *Watch, operation string, log Log) error {
+	for _, step := range plan.Extractions {
+		if watch != nil {
+			if err := watch.CheckUnchanged(step.Dst); err != nil {
+				return err
+			}
+		}
+		srcData, err := wtf.ReadFile(plan.SourceFiles(), step.Src)
+		if err != nil {
+			return err
+		}
+		dstData, err := os.ReadFile(step.Dst)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		extracted, merged, err := ExtractCharacterData(srcData, dstData, plan.Source, plan.Destination, plan.Rewrites)
+		if err != nil {
+			log.printf(LevelWarning, operation, "Couldn't merge %s's data into %s, it was left alone: %s", plan.Source.CharacterName(), step.Dst, err)
+			continue
+		}
+		if len(merged) == 0 {
+			log.printf(LevelDebug, operation, "Skipped %s, it has no data of %s's own", step.Src, plan.Source.CharacterName())
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(step.Dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(step.Dst, extracted, 0666); err != nil {
+			return err
+		}
+		log.printf(LevelInfo, operation, "Merged %s's data into %s: %s", plan.Source.CharacterName(), step.Dst, strings.Join(merged, ", "))
+		if watch != nil {
+			if err := watch.RecordWrite(step.Dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package copyplan
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"wow-profile-copy/wtf"
+)
+
+// each chunk read from the source is written to every destination at once
+const fanOutChunkSize = 256 << 10
+
+// copies src from store into every dst while reading it only once
+// returns an error per destination, one destination failing never stops the others
+func fanOutCopy(store wtf.Store, src string, dsts []string, bytesPerSecond int64) []error {
+	errs := make([]error, len(dsts))
+	setAll := func(err error) []error {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+
+	srcFileHandle, err := store.Open(src)
+	if err != nil {
+		return setAll(err)
+	}
+	defer srcFileHandle.Close()
+	before, err := srcFileHandle.Stat()
+	if err != nil {
+		return setAll(err)
+	}
+
+	files := make([]*os.File, len(dsts))
+	for i, dst := range dsts {
+		files[i], errs[i] = os.Create(dst)
+	}
+	defer func() {
+		for i, file := range files {
+			if file == nil {
+				continue
+			}
+			if err := file.Close(); err != nil && errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}()
+
+	var reader io.Reader = srcFileHandle
+	if bytesPerSecond > 0 {
+		reader = newThrottledReader(srcFileHandle, bytesPerSecond)
+	}
+	chunk := make([]byte, fanOutChunkSize)
+	for {
+		n, readErr := reader.Read(chunk)
+		if n > 0 {
+			var wg sync.WaitGroup
+			for i, file := range files {
+				if errs[i] != nil {
+					continue
+				}
+				wg.Add(1)
+				go func(i int, file *os.File) {
+					defer wg.Done()
+					_, errs[i] = file.Write(chunk[:n])
+				}(i, file)
+			}
+			wg.Wait()
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return setAll(readErr)
+		}
+	}
+
+	after, err := store.Stat(src)
+	if err != nil {
+		return setAll(err)
+	}
+	if wtf.SourceChanged(before, after) {
+		return setAll(&os.PathError{Op: "copy", Path: src, Err: wtf.ErrSourceChanged})
+	}
+	return errs
+}
+
+// a step of one of several plans being copied together
+type fanOutStep struct {
+	plan int
+	step Step
+}
+
+// CopyAll copies every plan's steps with the copier of the same index, reading each source file once for all of
+// the destinations that need it
+// a destination that hits an error skips its remaining steps and is returned in failed, the others carry on
+func CopyAll(plans []Plan, copiers []*Copier, log Log) map[int]error {
+	failed := make(map[int]error)
+
+	// group by source, keeping the order the first plan copies them in
+	var sources []string
+	bySource := make(map[string][]fanOutStep)
+	for i, plan := range plans {
+		for _, step := range plan.Steps {
+			if _, ok := bySource[step.Src]; !ok {
+				sources = append(sources, step.Src)
+			}
+			bySource[step.Src] = append(bySource[step.Src], fanOutStep{i, step})
+		}
+	}
+
+	for _, src := range sources {
+		var ready []fanOutStep
+		var dsts []string
+		// destinations on the same account share their account files, which are only written once
+		seen := make(map[string]bool)
+		for _, target := range bySource[src] {
+			if failed[target.plan] != nil {
+				continue
+			}
+			// never copy a file onto itself, opening it for writing would truncate the source
+			if target.step.Dst == src {
+				continue
+			}
+			if seen[target.step.Dst] {
+				continue
+			}
+			seen[target.step.Dst] = true
+			err := copiers[target.plan].prepare(target.step.Dst)
+			if wtf.IsSharingViolation(err) {
+				log.printf(LevelWarning, plans[target.plan].Destination.CharacterName(), "%s is locked by another program, skipping it", target.step.Dst)
+				continue
+			}
+			if err != nil {
+				failed[target.plan] = err
+				log.printf(LevelError, plans[target.plan].Destination.CharacterName(), "Copying to %s failed, skipping the rest of its files: %s", target.step.Dst, err)
+				continue
+			}
+			ready = append(ready, target)
+			dsts = append(dsts, target.step.Dst)
+		}
+		if len(ready) == 0 {
+			continue
+		}
+
+		// every destination gets the same options, so the first copier's throttle applies to all
+		errs := fanOutCopy(copiers[ready[0].plan].SourceFiles(), src, dsts, copiers[ready[0].plan].Options.BytesPerSecond)
+		copied := 0
+		for i, target := range ready {
+			copier := copiers[target.plan]
+			operation := plans[target.plan].Destination.CharacterName()
+			err := errs[i]
+			if wtf.IsSharingViolation(err) {
+				// locked destinations get the usual patient retries on their own
+				err = RetryLocked(func() error {
+					_, err := CopyStoreFile(copier.SourceFiles(), src, target.step.Dst, copier.Options.BytesPerSecond)
+					return err
+				})
+				if wtf.IsSharingViolation(err) {
+					copier.LockedFiles = append(copier.LockedFiles, target.step.Dst)
+					log.printf(LevelWarning, operation, "%s is locked by another program, skipping it", target.step.Dst)
+					continue
+				}
+			}
+			if err == nil {
+				err = copier.finish(src, target.step.Dst)
+			}
+			if err != nil {
+				failed[target.plan] = err
+				log.printf(LevelError, operation, "Copying to %s failed, skipping the rest of its files: %s", target.step.Dst, err)
+				continue
+			}
+			copied++
+			if filepath.Base(src) != filepath.Base(target.step.Dst) {
+				log.printf(LevelInfo, operation, "Copied %s as %s", src, filepath.Base(target.step.Dst))
+			} else {
+				log.printf(LevelInfo, operation, "Copied %s", src)
+			}
+		}
+	}
+	return failed
+}
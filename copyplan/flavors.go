@@ -0,0 +1,52 @@
+package copyplan
+
+import (
+	"strings"
+
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// broad client families, addons and settings are only compatible within one
+const (
+	FlavorRetail  = "retail"
+	FlavorClassic = "classic"
+)
+
+// FlavorFamily is which family a version folder belongs to
+func FlavorFamily(version string) string {
+	switch version {
+	case "_retail_", "_ptr_":
+		return FlavorRetail
+	}
+	return FlavorClassic
+}
+
+// TranslateSavedVariablesName is the SavedVariables file name the addon on dstVersion of install actually reads,
+// for a file copied from srcVersion
+// known renames come from the defaults' SavedVariablesNamesByFlavor, otherwise a "Classic" suffix is added or
+// dropped when only the suffixed (or unsuffixed) addon is installed on the destination
+func TranslateSavedVariablesName(install wowinstall.Install, name string, srcVersion string, dstVersion string, defaults wtf.Defaults) string {
+	srcFlavor, dstFlavor := FlavorFamily(srcVersion), FlavorFamily(dstVersion)
+	if srcFlavor == dstFlavor {
+		return name
+	}
+
+	for _, names := range defaults.SavedVariablesNamesByFlavor {
+		if names[srcFlavor] == name {
+			return names[dstFlavor]
+		}
+	}
+
+	addon := strings.TrimSuffix(name, ".lua")
+	var candidate string
+	if dstFlavor == FlavorClassic {
+		candidate = addon + "Classic"
+	} else {
+		candidate = strings.TrimSuffix(addon, "Classic")
+	}
+	if candidate != addon && !install.AddonInstalled(dstVersion, addon) && install.AddonInstalled(dstVersion, candidate) {
+		return candidate + ".lua"
+	}
+	return name
+}
@@ -0,0 +1,24 @@
+package copyplan
+
+// how much a logged line matters
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+// Log receives what a copy does as it goes, as a fmt format and its args (errors among them as they are, so they
+// can be explained), tagged with the operation (usually a destination) each line is about
+// copies to several destinations can log from several goroutines, so it has to be safe to call concurrently
+type Log func(level Level, operation string, format string, args ...any)
+
+// hands a line to log, a nil log drops it
+func (log Log) printf(level Level, operation string, format string, args ...any) {
+	if log == nil {
+		return
+	}
+	log(level, operation, format, args...)
+}
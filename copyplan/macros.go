@@ -0,0 +1,185 @@
+package copyplan
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wow-profile-copy/wtf"
+)
+
+// MacrosFileName is where general macros live in the account folder, and character-specific ones in the character's
+const MacrosFileName = "macros-cache.txt"
+
+// which of the source's macro files Options.MacrosScope copies, "" copies both
+const (
+	MacrosBoth      = "both"
+	MacrosAccount   = "account"
+	MacrosCharacter = "character"
+)
+
+// how many general (account) and character-specific macros each flavor's client has slots for, by the category
+// of the macros-cache.txt holding them
+// the game drops whatever doesn't fit on login, so copies into a flavor with fewer slots are cut short up front
+var _macroSlots = map[string]map[string]int{
+	FlavorRetail:  {CategoryAccountConfig: 120, CategoryCharacterConfig: 30},
+	FlavorClassic: {CategoryAccountConfig: 120, CategoryCharacterConfig: 18},
+}
+
+// MacroSlots are the macro slots of dstVersion when copying from srcVersion, nil when the flavors are the same
+func MacroSlots(srcVersion string, dstVersion string) map[string]int {
+	if FlavorFamily(srcVersion) == FlavorFamily(dstVersion) {
+		return nil
+	}
+	return _macroSlots[FlavorFamily(dstVersion)]
+}
+
+// the names of the macros in data, in the order the file has them
+// each macro is a MACRO <id> "<name>" <icon> line, its body, and END
+func macroNames(data []byte) []string {
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "MACRO ") {
+			continue
+		}
+		name := ""
+		if start, end := strings.Index(line, `"`), strings.LastIndex(line, `"`); start >= 0 && end > start {
+			name = line[start+1 : end]
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// keeps the first limit macros in data, returning what's left and the names of the ones cut
+// icons are kept as they are, both flavors store them as file IDs and show a question mark for unknown ones
+func truncateMacros(data []byte, limit int) ([]byte, []string) {
+	var kept []string
+	var cut []string
+	count := 0
+	dropping := false
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.HasPrefix(line, "MACRO ") {
+			count++
+			dropping = count > limit
+			if dropping {
+				cut = append(cut, macroNames([]byte(line))...)
+			}
+		}
+		if !dropping {
+			kept = append(kept, line)
+		}
+		if strings.TrimSpace(line) == "END" {
+			dropping = false
+		}
+	}
+	return []byte(strings.Join(kept, "")), cut
+}
+
+// OverflowingMacros are the macros each copied macros-cache.txt has beyond the destination's slots, by destination
+func (p Plan) OverflowingMacros() map[string][]string {
+	overflowing := make(map[string][]string)
+	for _, step := range p.Steps {
+		limit, ok := p.MacroSlots[step.Category]
+		if !ok || filepath.Base(step.Src) != MacrosFileName {
+			continue
+		}
+		file, err := p.SourceFiles().Open(step.Src)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		if names := macroNames(data); len(names) > limit {
+			overflowing[step.Dst] = names[limit:]
+		}
+	}
+	return overflowing
+}
+
+// cuts the copied macros-cache.txt files of plan down to the destination's slots, logging them under operation
+// watch (if set) makes sure nothing else touches a file between our read and write
+func truncateMacroFiles(plan Plan, watch *Watch, operation string, log Log) error {
+	for _, step := range plan.Steps {
+		limit, ok := plan.MacroSlots[step.Category]
+		if !ok || filepath.Base(step.Dst) != MacrosFileName {
+			continue
+		}
+		if watch != nil {
+			if err := watch.CheckUnchanged(step.Dst); err != nil {
+				return err
+			}
+		}
+		data, err := os.ReadFile(step.Dst)
+		if errors.Is(err, os.ErrNotExist) {
+			// locked files that were skipped
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		truncated, cut := truncateMacros(data, limit)
+		if len(cut) == 0 {
+			continue
+		}
+		if err := os.WriteFile(step.Dst, truncated, 0666); err != nil {
+			return err
+		}
+		if watch != nil {
+			if err := watch.RecordWrite(step.Dst); err != nil {
+				return err
+			}
+		}
+		log.printf(LevelWarning, operation, "%s only has room for %d macros in %s, left out %s", plan.Flavors[plan.Destination.Version], limit, step.Dst, strings.Join(cut, ", "))
+	}
+	return nil
+}
+
+// CountMacros is how many macros a macros-cache.txt in store holds, 0 if there isn't one
+func CountMacros(store wtf.Store, path string) int {
+	file, err := store.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "MACRO ") {
+			count++
+		}
+	}
+	return count
+}
+
+// leaves out the macro file scope doesn't copy, noting it as skipped
+func (p *Plan) placeMacros(scope string) {
+	var dropped string
+	switch scope {
+	case MacrosAccount:
+		dropped = CategoryCharacterConfig
+	case MacrosCharacter:
+		dropped = CategoryAccountConfig
+	default:
+		return
+	}
+
+	var steps []Step
+	for _, step := range p.Steps {
+		if step.Category == dropped && filepath.Base(step.Src) == MacrosFileName {
+			p.Skipped = append(p.Skipped, SkippedFile{step.Src, fmt.Sprintf("only %s macros are copied", scope)})
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
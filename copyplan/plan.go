@@ -0,0 +1,380 @@
+// Package copyplan works out everything copying one character's profile onto another would copy, and copies it:
+// the settings, keybindings, macros and SavedVariables, backed up and verified, with the names in them rewritten
+// to the destination's
+package copyplan
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// groups of files that are copied together
+const (
+	CategoryAccountConfig           = "Account settings"
+	CategoryCharacterConfig         = "Character settings"
+	CategoryAccountSavedVariables   = "Account SavedVariables"
+	CategoryCharacterSavedVariables = "Character SavedVariables"
+)
+
+// Categories are the categories in the order they're copied
+var Categories = []string{
+	CategoryAccountConfig,
+	CategoryCharacterConfig,
+	CategoryAccountSavedVariables,
+	CategoryCharacterSavedVariables,
+}
+
+// Step is a single file to copy
+type Step struct {
+	Category string
+	Src      string
+	Dst      string
+	Size     int64
+}
+
+// SkippedFile is a source file that won't be copied, and why
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// Plan is everything a sync will copy, resolved up front so it can be summarized before anything is written
+type Plan struct {
+	SourceInstallDirectory string
+	SourceStore            wtf.Store // where step sources are read from, nil for the local disk
+	Source                 wtf.Target
+	Destination            wtf.Target
+	Steps                  []Step
+	Skipped                []SkippedFile
+	Rewrites               []savedvars.Rule  // in order, applied to every copied .lua file
+	CacheInvalidations     []string          // globs of files to remove once everything is copied
+	CVarAllowlist          []string          // CVars copied config-cache.wtf files keep, nil keeps all
+	Reviewed               map[string]bool   // destinations already accepted at the flavor review, not conflicts anymore
+	ProfileKeys            string            // account SavedVariables whose AceDB profileKeys give the destination the source's profile, see BuildAddon
+	MacroSlots             map[string]int    // macros the destination has room for, by the category of the copied macros-cache.txt, nil keeps all
+	Extractions            []Step            // account SavedVariables only the source character's own data is merged from, see ExtractCharacterData
+	Flavors                map[string]string // the defaults' client names by version folder, for messages
+}
+
+// Options tweaks which files Build picks up
+type Options struct {
+	SkippedAccountSavedVariables map[string]bool  // account SavedVariables file names to leave alone
+	IncludeCombatLogs            bool             // copy the defaults' CombatLogSavedVariables too
+	BindingsScope                string           // where keybindings go, see placeBindings
+	MacrosScope                  string           // which macro files are copied, see placeMacros
+	Contents                     map[string]bool  // the Contents to copy by name, nil copies everything
+	IncludeSavedVariables        []string         // globs of the SavedVariables file names to copy, empty copies all
+	ExcludeSavedVariables        []string         // globs of SavedVariables file names to leave alone
+	Rewrites                     []savedvars.Rule // applied after the identity rewrites, see IdentityRewriteRules
+	ExtractCharacterData         bool             // merge the source character's own data from SkippedAccountSavedVariables instead of leaving them alone
+	CharacterOnly                bool             // copy only into the destination's character folder, see keepCharacterFiles
+	SkipAccountMerge             bool             // on the same account, leave its SavedVariables alone instead of merging the source's entries
+}
+
+// ErrSameSourceAndDestination is returned for a copy of a character onto itself, which would only truncate and
+// rewrite its own files
+var ErrSameSourceAndDestination = errors.New("source and destination are the same character, pick a different one to copy to")
+
+// Build works out every file to copy from src in srcInstall to dst in dstInstall, which files there are to copy
+// and which to leave alone coming from defaults
+// the two installs are usually the same, except when copying from an extracted share
+func Build(srcInstall wowinstall.Install, dstInstall wowinstall.Install, src wtf.Target, dst wtf.Target, defaults wtf.Defaults, opts Options) (Plan, error) {
+	plan := Plan{SourceInstallDirectory: srcInstall.Dir, SourceStore: srcInstall.Files(), Source: src, Destination: dst, Flavors: defaults.Flavors}
+	if srcInstall.Dir == dstInstall.Dir && src == dst {
+		return plan, ErrSameSourceAndDestination
+	}
+
+	srcAccountPath, dstAccountPath := srcInstall.AccountPath(src), dstInstall.AccountPath(dst)
+	srcCharacterPath, dstCharacterPath := srcInstall.CharacterPath(src), dstInstall.CharacterPath(dst)
+
+	// on the same account the account files are already the ones the destination uses, copying would only rewrite them in place
+	sameAccount := srcAccountPath == dstAccountPath
+	if sameAccount {
+		plan.Skipped = append(plan.Skipped, SkippedFile{srcAccountPath, "source and destination are on the same account, so its account-wide files are already shared"})
+	} else if err := plan.addFiles(CategoryAccountConfig, srcAccountPath, dstAccountPath, defaults.AccountFiles); err != nil {
+		return plan, err
+	}
+	if err := plan.addFiles(CategoryCharacterConfig, srcCharacterPath, dstCharacterPath, defaults.CharacterFiles); err != nil {
+		return plan, err
+	}
+
+	// the account's SavedVariables are the destination's too, what the source has in them of its own is merged in
+	// under the destination's name instead, see ExtractCharacterData
+	// aggregates (DataStore, Altoholic, TSM) are left out, their entries are each character's own game data, the
+	// source's gold and inventory merged over the destination's would replace the destination's real records
+	mergeSameAccount := sameAccount && !opts.SkipAccountMerge
+	if mergeSameAccount {
+		accountSavedVariables, err := plan.listSavedVariables(srcAccountPath, nil, defaults, opts)
+		if err != nil {
+			return plan, err
+		}
+		var merged []string
+		for _, name := range accountSavedVariables {
+			if MatchesAnyPattern(name, defaults.CrossCharacterAccountSavedVariables) {
+				path := filepath.Join(srcAccountPath, "SavedVariables", name)
+				plan.Skipped = append(plan.Skipped, SkippedFile{path, "it holds every character's own game data, the destination keeps its own"})
+				continue
+			}
+			merged = append(merged, name)
+		}
+		if err := plan.addFiles(CategoryAccountSavedVariables, filepath.Join(srcAccountPath, "SavedVariables"), filepath.Join(dstAccountPath, "SavedVariables"), merged); err != nil {
+			return plan, err
+		}
+	}
+
+	if !sameAccount {
+		// extracted files are listed like any other and split off once their destination names are worked out
+		skip := opts.SkippedAccountSavedVariables
+		if opts.ExtractCharacterData {
+			skip = nil
+		}
+		accountSavedVariables, err := plan.listSavedVariables(srcAccountPath, skip, defaults, opts)
+		if err != nil {
+			return plan, err
+		}
+		err = plan.addFiles(CategoryAccountSavedVariables, filepath.Join(srcAccountPath, "SavedVariables"), filepath.Join(dstAccountPath, "SavedVariables"), accountSavedVariables)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	// keybindings are the likeliest thing to be passed around this way, so they land in the character's own file
+	bindingsScope := opts.BindingsScope
+	if opts.CharacterOnly {
+		bindingsScope = BindingsScopeCharacter
+	}
+	plan.placeMacros(opts.MacrosScope)
+	if err := plan.placeBindings(bindingsScope, srcAccountPath, dstAccountPath, srcCharacterPath, dstCharacterPath); err != nil {
+		return plan, err
+	}
+
+	characterSavedVariables, err := plan.listSavedVariables(srcCharacterPath, nil, defaults, opts)
+	if err != nil {
+		return plan, err
+	}
+	err = plan.addFiles(CategoryCharacterSavedVariables, filepath.Join(srcCharacterPath, "SavedVariables"), filepath.Join(dstCharacterPath, "SavedVariables"), characterSavedVariables)
+	if err != nil {
+		return plan, err
+	}
+
+	// cross-flavor copies land SavedVariables in whatever file the destination's version of the addon reads
+	for i, step := range plan.Steps {
+		if step.Category == CategoryAccountSavedVariables || step.Category == CategoryCharacterSavedVariables {
+			translated := TranslateSavedVariablesName(dstInstall, filepath.Base(step.Dst), src.Version, dst.Version, defaults)
+			plan.Steps[i].Dst = filepath.Join(filepath.Dir(step.Dst), translated)
+		}
+	}
+
+	if opts.CharacterOnly {
+		plan.keepCharacterFiles()
+	}
+	plan.keepContents(opts.Contents)
+	switch {
+	case mergeSameAccount:
+		plan.splitExtractions(nil)
+	case opts.ExtractCharacterData:
+		plan.splitExtractions(opts.SkippedAccountSavedVariables)
+	}
+
+	plan.Rewrites = append(IdentityRewriteRules(src, dst), opts.Rewrites...)
+	plan.CVarAllowlist = CVarAllowlist(src.Version, dst.Version, defaults)
+	plan.MacroSlots = MacroSlots(src.Version, dst.Version)
+	plan.CacheInvalidations = CacheInvalidationPatterns(dst.Version, dstAccountPath, dstCharacterPath)
+	return plan, nil
+}
+
+// moves the steps writing to the destination's account folder to p.Skipped, so copying to every character on an
+// account only changes the characters themselves
+func (p *Plan) keepCharacterFiles() {
+	var steps []Step
+	for _, step := range p.Steps {
+		if step.Category == CategoryAccountConfig || step.Category == CategoryAccountSavedVariables {
+			p.Skipped = append(p.Skipped, SkippedFile{step.Src, "it's account-wide and only character files are copied (--character-only)"})
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
+
+// moves the account SavedVariables steps of the files in names to p.Extractions, names nil moves them all
+func (p *Plan) splitExtractions(names map[string]bool) {
+	var steps []Step
+	for _, step := range p.Steps {
+		if step.Category == CategoryAccountSavedVariables && (names == nil || names[filepath.Base(step.Src)]) {
+			p.Extractions = append(p.Extractions, step)
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
+
+// lists the .lua files in dir/SavedVariables, recording any that opts (or skip) leaves out
+func (p *Plan) listSavedVariables(dir string, skip map[string]bool, defaults wtf.Defaults, opts Options) ([]string, error) {
+	files, err := p.SourceFiles().ReadDir(filepath.Join(dir, "SavedVariables"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".lua") {
+			continue
+		}
+		path := filepath.Join(dir, "SavedVariables", file.Name())
+		if skip[file.Name()] {
+			p.Skipped = append(p.Skipped, SkippedFile{path, "it holds data for other characters on the account"})
+			continue
+		}
+		if !opts.IncludeCombatLogs && MatchesAnyPattern(file.Name(), defaults.CombatLogSavedVariables) {
+			p.Skipped = append(p.Skipped, SkippedFile{path, "it's combat log history (use --include-combat-logs to copy it)"})
+			continue
+		}
+		if reason := opts.savedVariablesFilterReason(file.Name()); reason != "" {
+			p.Skipped = append(p.Skipped, SkippedFile{path, reason})
+			continue
+		}
+		names = append(names, file.Name())
+	}
+	return names, nil
+}
+
+// why --include and --exclude leave the SavedVariables file name out, "" when they don't
+// patterns match regardless of case, addons aren't consistent about how they capitalize their files
+func (opts Options) savedVariablesFilterReason(name string) string {
+	lower := strings.ToLower(name)
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(strings.ToLower(pattern), lower); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(opts.IncludeSavedVariables) > 0 && !matches(opts.IncludeSavedVariables) {
+		return "it doesn't match --include"
+	}
+	if matches(opts.ExcludeSavedVariables) {
+		return "it matches --exclude (or exclude in config.yaml)"
+	}
+	return ""
+}
+
+// adds a step per file that exists in srcDir
+func (p *Plan) addFiles(category string, srcDir string, dstDir string, files []string) error {
+	for _, file := range files {
+		src := filepath.Join(srcDir, file)
+		info, err := p.SourceFiles().Stat(src)
+		if errors.Is(err, os.ErrNotExist) {
+			p.Skipped = append(p.Skipped, SkippedFile{src, "it doesn't exist in the source"})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		p.Steps = append(p.Steps, Step{
+			Category: category,
+			Src:      src,
+			Dst:      filepath.Join(dstDir, file),
+			Size:     info.Size(),
+		})
+	}
+	return nil
+}
+
+// SourceFiles is the store step sources are read from
+func (p Plan) SourceFiles() wtf.Store {
+	if p.SourceStore == nil {
+		return wtf.LocalStore{}
+	}
+	return p.SourceStore
+}
+
+// RewrittenFiles are the copied files rewrites apply to
+// only files we wrote are touched, other characters' SavedVariables on the same account are left alone
+func (p Plan) RewrittenFiles() []string {
+	var paths []string
+	for _, step := range p.Steps {
+		if strings.HasSuffix(step.Dst, ".lua") && step.Src != step.Dst {
+			paths = append(paths, step.Dst)
+		}
+	}
+	return paths
+}
+
+// AllSteps are the steps and the extractions, every file the plan writes to
+func (p Plan) AllSteps() []Step {
+	return append(append([]Step{}, p.Steps...), p.Extractions...)
+}
+
+// TotalBytes is how much the plan's steps copy
+func (p Plan) TotalBytes() int64 {
+	var total int64
+	for _, step := range p.Steps {
+		total += step.Size
+	}
+	return total
+}
+
+// ChangedSteps are the steps (and extractions) that would actually change something, comparing each destination
+// to what copying (and rewriting) its source would leave there
+func (p Plan) ChangedSteps() ([]Step, error) {
+	var changed []Step
+	for _, step := range p.Steps {
+		want, err := wtf.ReadFile(p.SourceFiles(), step.Src)
+		if err != nil {
+			return nil, err
+		}
+		have, err := os.ReadFile(step.Dst)
+		if errors.Is(err, os.ErrNotExist) {
+			changed = append(changed, step)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(step.Dst, ".lua") {
+			want = savedvars.Rewrite(want, p.Rewrites)
+		}
+		if filepath.Base(step.Dst) == ConfigCacheFileName && p.CVarAllowlist != nil {
+			want, _ = FilterCVars(want, p.CVarAllowlist)
+		}
+		if !bytes.Equal(want, have) {
+			changed = append(changed, step)
+		}
+	}
+	// an extraction changes its destination when merging the source's entries in would, merges that fail are left alone
+	for _, step := range p.Extractions {
+		srcData, err := wtf.ReadFile(p.SourceFiles(), step.Src)
+		if err != nil {
+			return nil, err
+		}
+		have, err := os.ReadFile(step.Dst)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		want, merged, err := ExtractCharacterData(srcData, have, p.Source, p.Destination, p.Rewrites)
+		if err == nil && len(merged) > 0 && !bytes.Equal(want, have) {
+			changed = append(changed, step)
+		}
+	}
+	return changed, nil
+}
+
+// MatchesAnyPattern reports whether name matches any of the filepath.Match globs in patterns
+func MatchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
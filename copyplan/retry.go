@@ -0,0 +1,26 @@
+package copyplan
+
+import (
+	"time"
+
+	"wow-profile-copy/wtf"
+)
+
+// how many times, and how patiently, a locked file is retried before giving up on it
+const (
+	lockedFileRetries      = 5
+	lockedFileInitialDelay = 200 * time.Millisecond
+)
+
+// RetryLocked runs op, retrying with exponential backoff while it fails because another process has the file open
+// antivirus scanners and the Battle.net agent like to briefly hold files in WTF
+func RetryLocked(op func() error) error {
+	delay := lockedFileInitialDelay
+	err := op()
+	for attempt := 1; attempt < lockedFileRetries && wtf.IsSharingViolation(err); attempt++ {
+		time.Sleep(delay)
+		delay *= 2
+		err = op()
+	}
+	return err
+}
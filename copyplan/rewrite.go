@@ -0,0 +1,58 @@
+package copyplan
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+// IdentityRewriteRules are the rewrites that make copied SavedVariables refer to the destination character
+// addons key per-character data as "Name-Realm", "Name - Realm", or "Realm - Name"
+func IdentityRewriteRules(src wtf.Target, dst wtf.Target) []savedvars.Rule {
+	rules := []savedvars.Rule{
+		{From: src.Name + "-" + src.Server, To: dst.Name + "-" + dst.Server},
+		{From: src.Name + " - " + src.Server, To: dst.Name + " - " + dst.Server},
+		{From: src.Server + " - " + src.Name, To: dst.Server + " - " + dst.Name},
+	}
+	if src.Account != dst.Account {
+		// some addons key their data by the account folder name too, as the whole string
+		account, _ := savedvars.NewRule("^"+regexp.QuoteMeta(src.Account)+"$", strings.ReplaceAll(dst.Account, "$", "$$"), true)
+		rules = append(rules, account)
+	}
+	return rules
+}
+
+// RewriteSavedVariables applies rules to every file in paths, logging them under operation
+// watch (if set) makes sure nothing else touches a file between our read and write
+func RewriteSavedVariables(paths []string, rules []savedvars.Rule, watch *Watch, operation string, log Log) error {
+	for _, path := range paths {
+		log.printf(LevelInfo, operation, "Processing lua file: %s", path)
+		if watch != nil {
+			if err := watch.CheckUnchanged(path); err != nil {
+				return err
+			}
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// locked files that were skipped
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(path, savedvars.Rewrite(data, rules), 0666); err != nil {
+			return err
+		}
+		if watch != nil {
+			if err := watch.RecordWrite(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package copyplan
+
+// Finish does everything that comes after copying plan's files: rewriting names in the copied SavedVariables
+// (only the ones in rewritten, files several plans share only carry the first one's names), filtering CVars,
+// normalizing text, cutting macros down, assigning profiles and merging extracted character data
+// watch (if set) makes sure nothing else touches a file between our read and write
+func Finish(plan Plan, rewritten []string, opts CopyOptions, watch *Watch, log Log) error {
+	operation := plan.Destination.CharacterName()
+	if err := RewriteSavedVariables(rewritten, plan.Rewrites, watch, operation, log); err != nil {
+		return err
+	}
+	if plan.CVarAllowlist != nil {
+		if err := filterConfigCaches(plan.CVarFilteredFiles(), plan.CVarAllowlist, watch, operation, log); err != nil {
+			return err
+		}
+	}
+	if opts.NormalizeText {
+		if err := normalizeTextFiles(plan, watch, operation, log); err != nil {
+			return err
+		}
+	}
+	if plan.MacroSlots != nil {
+		if err := truncateMacroFiles(plan, watch, operation, log); err != nil {
+			return err
+		}
+	}
+	if plan.ProfileKeys != "" {
+		if err := assignPlanProfile(plan, watch, operation, log); err != nil {
+			return err
+		}
+	}
+	return extractPlanCharacterData(plan, watch, operation, log)
+}
+
+// CleanUp removes the caches plan's copy made stale, logging each one
+func CleanUp(plan Plan, watch *Watch, log Log) error {
+	return InvalidateCaches(plan.CacheInvalidations, func(path string) {
+		if watch != nil {
+			watch.RecordWrite(path)
+		}
+		log.printf(LevelInfo, plan.Destination.CharacterName(), "Removed %s", path)
+	})
+}
+
+// Run copies every plan with the copier of the same index and finishes the ones that copied fine, one watch over
+// every destination catching anything else writing to them meanwhile
+// returns the plans that failed to copy by index, and the first error finishing one, which stops the run
+func Run(plans []Plan, copiers []*Copier, watch *Watch, log Log) (map[int]error, error) {
+	for _, copier := range copiers {
+		copier.Watch = watch
+	}
+	failed := CopyAll(plans, copiers, log)
+
+	rewritten := make(map[string]bool)
+	for i, plan := range plans {
+		if failed[i] != nil {
+			continue
+		}
+		if err := Finish(plan, FirstRewrites(plan, rewritten), copiers[i].Options, watch, log); err != nil {
+			return failed, err
+		}
+		if err := CleanUp(plan, watch, log); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// FirstRewrites are plan's RewrittenFiles no earlier plan has claimed in seen, claiming them
+// shared account files can only carry one destination's names, the first one gets them
+func FirstRewrites(plan Plan, seen map[string]bool) []string {
+	var paths []string
+	for _, path := range plan.RewrittenFiles() {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,72 @@
+package copyplan
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// the line ending the game client on this OS writes, the Windows client uses CRLF and the Mac client LF
+func nativeLineEnding() string {
+	if runtime.GOOS == "windows" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// whether a file is one of the text settings files --normalize-text rewrites
+func normalizableText(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".wtf" || ext == ".txt"
+}
+
+// strips a leading BOM from data and gives every line the ending lineEnding
+func normalizeText(data []byte, lineEnding string) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if lineEnding != "\n" {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte(lineEnding))
+	}
+	return data
+}
+
+// normalizes the text settings files plan copied to this OS's line ending without a BOM, logging them under operation
+// watch (if set) makes sure nothing else touches a file between our read and write
+func normalizeTextFiles(plan Plan, watch *Watch, operation string, log Log) error {
+	for _, step := range plan.Steps {
+		if !normalizableText(step.Dst) {
+			continue
+		}
+		if watch != nil {
+			if err := watch.CheckUnchanged(step.Dst); err != nil {
+				return err
+			}
+		}
+		data, err := os.ReadFile(step.Dst)
+		if errors.Is(err, os.ErrNotExist) {
+			// locked files that were skipped
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		normalized := normalizeText(data, nativeLineEnding())
+		if bytes.Equal(normalized, data) {
+			continue
+		}
+		if err := os.WriteFile(step.Dst, normalized, 0666); err != nil {
+			return err
+		}
+		if watch != nil {
+			if err := watch.RecordWrite(step.Dst); err != nil {
+				return err
+			}
+		}
+		log.printf(LevelInfo, operation, "Normalized the line endings of %s", step.Dst)
+	}
+	return nil
+}
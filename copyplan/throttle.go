@@ -0,0 +1,34 @@
+package copyplan
+
+import (
+	"io"
+	"time"
+)
+
+// an io.Reader that never goes faster than bytesPerSecond on average
+// keeps a big sync from starving the game or a stream of disk bandwidth on the same drive
+type throttledReader struct {
+	reader         io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+func newThrottledReader(reader io.Reader, bytesPerSecond int64) *throttledReader {
+	return &throttledReader{reader: reader, bytesPerSecond: bytesPerSecond, start: time.Now()}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// small reads keep the sleeps short and the rate smooth
+	if int64(len(p)) > t.bytesPerSecond/10+1 {
+		p = p[:t.bytesPerSecond/10+1]
+	}
+	n, err := t.reader.Read(p)
+	t.read += int64(n)
+
+	due := t.start.Add(time.Duration(float64(t.read) / float64(t.bytesPerSecond) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
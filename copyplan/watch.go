@@ -0,0 +1,99 @@
+package copyplan
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// the parts of a file's metadata that change whenever something writes to it
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// Watch remembers what every file under a destination tree should look like
+// if anything other than us changes one of them during the run, WoW (or something else) is writing to the WTF tree
+type Watch struct {
+	expected map[string]fileState
+}
+
+// NewWatch snapshots every file under the given roots
+func NewWatch(roots ...string) (*Watch, error) {
+	watch := &Watch{expected: make(map[string]fileState)}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			state, err := statFile(path)
+			if err != nil {
+				return err
+			}
+			watch.expected[path] = state
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return watch, nil
+}
+
+// CheckUnchanged returns an error if path no longer matches what we last saw or wrote
+// paths we've never seen are only expected to still be missing
+func (w *Watch) CheckUnchanged(path string) error {
+	current, err := statFile(path)
+	if err != nil {
+		return err
+	}
+	if current != w.expected[path] {
+		return fmt.Errorf("%s was modified by another program while copying. Is WoW running? Close the game and try again", path)
+	}
+	return nil
+}
+
+// RecordWrite records the state of a file we just wrote or removed
+func (w *Watch) RecordWrite(path string) error {
+	state, err := statFile(path)
+	if err != nil {
+		return err
+	}
+	w.expected[path] = state
+	return nil
+}
+
+// ChangedFiles lists every watched file that doesn't match its expected state
+func (w *Watch) ChangedFiles() ([]string, error) {
+	var changed []string
+	for path, expected := range w.expected {
+		current, err := statFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if current != expected {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
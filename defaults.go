@@ -0,0 +1,89 @@
+package main
+
+//go:generate go run gen_presets.go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wow-profile-copy/wtf"
+)
+
+// the built-in flavor map, file lists, and exclusion lists, until loadDefaultsOverride replaces them
+var _defaults = wtf.BuiltinDefaults()
+
+var _accountFilesToCopy = _defaults.AccountFiles
+var _characterFilesToCopy = _defaults.CharacterFiles
+
+// addons that ship under a different name (and so a different SavedVariables file) per family
+var _savedVariablesNamesByFlavor = _defaults.SavedVariablesNamesByFlavor
+
+// globs of the CVars each family's client knows about, config-cache.wtf copied to another family only keeps
+// these, since every one the client doesn't know is reported as "Unknown console variable" on login
+// a family without a list keeps everything
+var _cvarsByFlavor = _defaults.CVarsByFlavor
+
+func defaultsOverridePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "defaults.json"), nil
+}
+
+// replaces built-in defaults with any fields set in the user's defaults.json
+// fields left out of the override keep their built-in values
+func loadDefaultsOverride() error {
+	path, err := defaultsOverridePath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	override := _defaults
+	if err := json.Unmarshal(data, &override); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	_defaults = override
+
+	_wowInstanceFolderNames = _defaults.Flavors
+	_accountFilesToCopy = _defaults.AccountFiles
+	_characterFilesToCopy = _defaults.CharacterFiles
+	_crossCharacterAccountSavedVariables = _defaults.CrossCharacterAccountSavedVariables
+	_combatLogSavedVariables = _defaults.CombatLogSavedVariables
+	_savedVariablesNamesByFlavor = _defaults.SavedVariablesNamesByFlavor
+	_cvarsByFlavor = _defaults.CVarsByFlavor
+	return nil
+}
+
+// the defaults currently in effect, with the presets applied
+func currentDefaults() wtf.Defaults {
+	return wtf.Defaults{
+		Flavors:                             _wowInstanceFolderNames,
+		AccountFiles:                        _accountFilesToCopy,
+		CharacterFiles:                      _characterFilesToCopy,
+		CrossCharacterAccountSavedVariables: _crossCharacterAccountSavedVariables,
+		CombatLogSavedVariables:             _combatLogSavedVariables,
+		SavedVariablesNamesByFlavor:         _savedVariablesNamesByFlavor,
+		CVarsByFlavor:                       _cvarsByFlavor,
+	}
+}
+
+// the defaults currently in effect, as indented JSON
+func printDefaults() error {
+	data, err := json.MarshalIndent(currentDefaults(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// what copying src onto dst would do to each file, as rows of a table, and how many files are already the same
+func (wow WowInstall) diffTargets(src wtf.Target, dst wtf.Target) ([][]string, int, error) {
+	plan, err := copyplan.Build(wow.Install, wow.Install, src, dst, currentDefaults(), copyplan.Options{})
+	if err != nil {
+		return nil, 0, err
+	}
+	changed, err := plan.ChangedSteps()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extracted := make(map[string]bool)
+	for _, step := range plan.Extractions {
+		extracted[step.Dst] = true
+	}
+
+	table := [][]string{{"Category", "File", "Change"}}
+	for _, step := range changed {
+		change := "would change"
+		if extracted[step.Dst] {
+			change = fmt.Sprintf("would merge in %s's entries", src.CharacterName())
+		} else if info, err := os.Stat(step.Dst); err != nil {
+			change = fmt.Sprintf("new, %s", formatBytes(step.Size))
+		} else if info.Size() != step.Size {
+			change = fmt.Sprintf("would change, %s -> %s", formatBytes(info.Size()), formatBytes(step.Size))
+		}
+		table = append(table, []string{step.Category, filepath.Base(step.Dst), change})
+	}
+	return table, len(plan.AllSteps()) - len(changed), nil
+}
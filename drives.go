@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+// a mounted volume the user could have installed WoW on
+type driveInfo struct {
+	root      string // e.g. C:\
+	label     string
+	freeBytes uint64
+}
+
+func (d driveInfo) String() string {
+	if d.label == "" {
+		return fmt.Sprintf("%s - %s free", d.root, formatBytes(int64(d.freeBytes)))
+	}
+	return fmt.Sprintf("%s (%s) - %s free", d.root, d.label, formatBytes(int64(d.freeBytes)))
+}
+
+// lets the user pick which drive to start browsing from
+func promptForDrive(drives []driveInfo) string {
+	var options []string
+	roots := make(map[string]string)
+	for _, drive := range drives {
+		options = append(options, drive.String())
+		roots[drive.String()] = drive.root
+	}
+
+	selected := askSelect("drive", pterm.DefaultInteractiveSelect.
+		WithOptions(options).
+		WithDefaultText("Which drive is WoW located on?").
+		WithMaxHeight(15))
+	return roots[selected]
+}
+
+// human readable byte counts, e.g. 1.5 GB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
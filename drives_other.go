@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// everything hangs off / outside of windows, there are no drive letters to pick from
+func listDrives() []driveInfo {
+	return nil
+}
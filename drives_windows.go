@@ -0,0 +1,48 @@
+//go:build windows
+
+package main
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// lists fixed and removable drives with their volume labels and free space
+// network shares and optical drives are left out, nobody runs WoW from a DVD
+func listDrives() []driveInfo {
+	mask, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil
+	}
+
+	var drives []driveInfo
+	for i := 0; i < 26; i++ {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		root := string(rune('A'+i)) + ":\\"
+		rootPtr, err := windows.UTF16PtrFromString(root)
+		if err != nil {
+			continue
+		}
+
+		driveType := windows.GetDriveType(rootPtr)
+		if driveType != windows.DRIVE_FIXED && driveType != windows.DRIVE_REMOVABLE {
+			continue
+		}
+
+		drive := driveInfo{root: root}
+
+		label := make([]uint16, windows.MAX_PATH+1)
+		if windows.GetVolumeInformation(rootPtr, &label[0], uint32(len(label)), nil, nil, nil, nil, 0) == nil {
+			drive.label = windows.UTF16ToString(label)
+		}
+
+		var freeBytes, totalBytes, totalFreeBytes uint64
+		if windows.GetDiskFreeSpaceEx(rootPtr, &freeBytes, &totalBytes, &totalFreeBytes) == nil {
+			drive.freeBytes = freeBytes
+		}
+
+		drives = append(drives, drive)
+	}
+	return drives
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+// what a dry run found for one destination
+type dryRunDestination struct {
+	name     string
+	files    int
+	changing int
+	bytes    int64
+	warnings []string
+	pairs    [][]string // every source and destination, with a header row
+	removing []string   // cache files that would be removed after copying
+}
+
+// works out what copying plan would do to its destination without writing anything
+func (wow WowInstall) dryRunDestination(plan copyplan.Plan, config Config) (dryRunDestination, error) {
+	result := dryRunDestination{
+		name:  plan.Destination.CharacterName(),
+		files: len(plan.Steps) + len(plan.Extractions),
+		bytes: plan.TotalBytes(),
+	}
+
+	changed, err := plan.ChangedSteps()
+	if err != nil {
+		return result, err
+	}
+	result.changing = len(changed)
+	if result.pairs, err = dryRunPairs(plan, changed, wow.Dir); err != nil {
+		return result, err
+	}
+	for _, pattern := range plan.CacheInvalidations {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return result, err
+		}
+		result.removing = append(result.removing, matches...)
+	}
+
+	if _, err := os.Stat(wow.CharacterPath(plan.Destination)); err != nil {
+		result.warnings = append(result.warnings, "its folders don't exist yet and would be created")
+	}
+	if config.isProtected(plan.Destination) {
+		result.warnings = append(result.warnings, "it's protected, copying would ask for its name to be typed")
+	}
+	for _, conflict := range wow.findConflicts(plan) {
+		result.warnings = append(result.warnings, fmt.Sprintf("%s: %s", conflict.step.Dst, strings.Join(conflict.reasons, ", ")))
+	}
+	return result, nil
+}
+
+// a table of every file plan copies or merges: where from, where to, whether it changes, and how many character and realm
+// names are rewritten in it, paths relative to their install directories
+func dryRunPairs(p copyplan.Plan, changed []copyplan.Step, installDirectory string) ([][]string, error) {
+	changing := make(map[string]bool)
+	for _, step := range changed {
+		changing[step.Dst] = true
+	}
+	rewritten := make(map[string]bool)
+	for _, path := range p.RewrittenFiles() {
+		rewritten[path] = true
+	}
+
+	table := [][]string{{"Source", "Destination", "Changes", "Name rewrites"}}
+	for _, step := range p.Steps {
+		rewrites := "-"
+		if rewritten[step.Dst] {
+			data, err := wtf.ReadFile(p.SourceFiles(), step.Src)
+			if err != nil {
+				return nil, err
+			}
+			rewrites = fmt.Sprint(savedvars.CountRewrites(data, p.Rewrites))
+		}
+		changes := "no"
+		if changing[step.Dst] {
+			changes = "yes"
+		}
+		table = append(table, []string{relativeTo(p.SourceInstallDirectory, step.Src), relativeTo(installDirectory, step.Dst), changes, rewrites})
+	}
+	// only the source character's own entries are merged into these, see copyplan.ExtractCharacterData
+	for _, step := range p.Extractions {
+		changes := "no"
+		if changing[step.Dst] {
+			changes = "yes, merged"
+		}
+		table = append(table, []string{relativeTo(p.SourceInstallDirectory, step.Src), relativeTo(installDirectory, step.Dst), changes, "-"})
+	}
+	return table, nil
+}
+
+// path relative to dir, or path as is when it isn't under dir (shared profiles are read from an archive)
+func relativeTo(dir string, path string) string {
+	relativePath, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return path
+	}
+	return relativePath
+}
+
+// prints, per destination, how many files plans would copy and change and anything worth a second look,
+// followed by a table of every destination, so the blast radius of a bulk copy can be reviewed first
+// returns how many files would change across every destination
+func (wow WowInstall) printDryRun(plans []copyplan.Plan, config Config) (int, error) {
+	summary := [][]string{{"Destination", "Files", "Changing", "Size", "Warnings"}}
+	var totalFiles, totalChanging, totalWarnings int
+	var totalBytes int64
+	for _, plan := range plans {
+		result, err := wow.dryRunDestination(plan, config)
+		if err != nil {
+			return 0, err
+		}
+
+		pterm.DefaultSection.Println(result.name)
+		if err := pterm.DefaultTable.WithHasHeader().WithData(planSummaryTable(plan)).Render(); err != nil {
+			return 0, err
+		}
+		pterm.Info.Printfln("%d of %d files would change", result.changing, result.files)
+		if err := pterm.DefaultTable.WithHasHeader().WithData(result.pairs).Render(); err != nil {
+			return 0, err
+		}
+		for _, path := range result.removing {
+			pterm.Info.Printfln("Would remove %s so the game rebuilds it", path)
+		}
+		for _, skipped := range plan.Skipped {
+			pterm.Info.Printfln("Would skip %s, %s", skipped.Path, skipped.Reason)
+		}
+		for _, warning := range result.warnings {
+			pterm.Warning.Println(warning)
+		}
+
+		summary = append(summary, []string{result.name, fmt.Sprint(result.files), fmt.Sprint(result.changing), formatBytes(result.bytes), fmt.Sprint(len(result.warnings))})
+		totalFiles += result.files
+		totalChanging += result.changing
+		totalBytes += result.bytes
+		totalWarnings += len(result.warnings)
+	}
+
+	if len(plans) > 1 {
+		pterm.DefaultSection.Println("Every destination")
+		summary = append(summary, []string{"Total", fmt.Sprint(totalFiles), fmt.Sprint(totalChanging), formatBytes(totalBytes), fmt.Sprint(totalWarnings)})
+		if err := pterm.DefaultTable.WithHasHeader().WithData(summary).Render(); err != nil {
+			return 0, err
+		}
+	}
+	pterm.Info.Println("This was a dry run, nothing was written")
+	return totalChanging, nil
+}
+
+// a table of file counts and sizes per category, with a header row and a total row
+func planSummaryTable(p copyplan.Plan) [][]string {
+	counts := make(map[string]int)
+	sizes := make(map[string]int64)
+	for _, step := range p.Steps {
+		counts[step.Category]++
+		sizes[step.Category] += step.Size
+	}
+
+	table := [][]string{{"Category", "Files", "Size"}}
+	for _, category := range copyplan.Categories {
+		if counts[category] == 0 {
+			continue
+		}
+		table = append(table, []string{category, fmt.Sprint(counts[category]), formatBytes(sizes[category])})
+	}
+	table = append(table, []string{"Total", fmt.Sprint(len(p.Steps)), formatBytes(p.TotalBytes())})
+	return table
+}
+
+// what plan and copy --dry-run exit with under --detailed-exitcode when copying would change something, 1 and 2
+// are errors
+const exitCodeChangesPending = 3
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// only the biggest addons are listed, the long tail of tiny SavedVariables isn't worth cleaning up
+const duTopAddons = 10
+
+// the space used by one account, character, or addon
+type diskUsage struct {
+	name  string
+	files int
+	bytes int64
+}
+
+func (u *diskUsage) add(size int64) {
+	u.files++
+	u.bytes += size
+}
+
+// adds up every account (its own files, not its characters') and character in every version of wow,
+// and every addon's SavedVariables across all of them, each sorted biggest first
+func (wow WowInstall) diskUsage() ([]diskUsage, []diskUsage, error) {
+	scopes := make(map[string]*diskUsage)
+	addons := make(map[string]*diskUsage)
+	var scopeOrder, addonOrder []string
+	usage := func(m map[string]*diskUsage, order *[]string, name string) *diskUsage {
+		if _, ok := m[name]; !ok {
+			m[name] = &diskUsage{name: name}
+			*order = append(*order, name)
+		}
+		return m[name]
+	}
+
+	for _, version := range wow.Versions {
+		accountsPath := filepath.Join(wow.wtfPath(version), "Account")
+		// versions that were never logged into have no WTF to measure
+		if _, err := os.Stat(accountsPath); err != nil {
+			continue
+		}
+		for _, account := range wow.getAccounts(version) {
+			accountPath := filepath.Join(accountsPath, account)
+			accountScope := fmt.Sprintf("%s/%s", _wowInstanceFolderNames[version], account)
+			err := filepath.WalkDir(accountPath, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				relative, err := filepath.Rel(accountPath, path)
+				if err != nil {
+					return err
+				}
+
+				// <realm>/<character>/... belongs to the character, everything else to the account
+				scope := accountScope
+				parts := strings.Split(relative, string(filepath.Separator))
+				if len(parts) >= 3 && parts[0] != "SavedVariables" {
+					scope = fmt.Sprintf("%s/%s/%s", accountScope, parts[0], parts[1])
+				}
+				usage(scopes, &scopeOrder, scope).add(info.Size())
+
+				if filepath.Base(filepath.Dir(path)) == "SavedVariables" {
+					addon := strings.TrimSuffix(strings.TrimSuffix(d.Name(), ".bak"), ".lua")
+					usage(addons, &addonOrder, addon).add(info.Size())
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return sortedDiskUsage(scopes, scopeOrder), sortedDiskUsage(addons, addonOrder), nil
+}
+
+func sortedDiskUsage(m map[string]*diskUsage, order []string) []diskUsage {
+	var sorted []diskUsage
+	for _, name := range order {
+		sorted = append(sorted, *m[name])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].bytes > sorted[j].bytes
+	})
+	return sorted
+}
+
+// a table of usages with a total, anything taking more than a fifth of the total stands out
+func diskUsageTable(header string, usages []diskUsage, limit int) [][]string {
+	var total diskUsage
+	for _, usage := range usages {
+		total.files += usage.files
+		total.bytes += usage.bytes
+	}
+
+	table := [][]string{{header, "Files", "Size"}}
+	for i, usage := range usages {
+		if limit > 0 && i == limit {
+			break
+		}
+		row := []string{usage.name, fmt.Sprint(usage.files), formatBytes(usage.bytes)}
+		if usage.bytes*5 > total.bytes {
+			for i := range row {
+				row[i] = pterm.ThemeDefault.WarningMessageStyle.Sprint(row[i])
+			}
+		}
+		table = append(table, row)
+	}
+	return append(table, []string{"Total", fmt.Sprint(total.files), formatBytes(total.bytes)})
+}
+
+// prints where the space in every WTF folder of wow goes
+func printDiskUsage(wow WowInstall) error {
+	scopes, addons, err := wow.diskUsage()
+	if err != nil {
+		return err
+	}
+	if len(scopes) == 0 {
+		pterm.Info.Printfln("There's nothing in %s's WTF folders yet", wow.Dir)
+		return nil
+	}
+
+	pterm.DefaultSection.Println("Accounts and characters")
+	if err := pterm.DefaultTable.WithHasHeader().WithData(diskUsageTable("Account or character", scopes, 0)).Render(); err != nil {
+		return err
+	}
+	if len(addons) == 0 {
+		return nil
+	}
+	pterm.DefaultSection.Println("Biggest addons, counting their SavedVariables on every account and character")
+	return pterm.DefaultTable.WithHasHeader().WithData(diskUsageTable("Addon", addons, duTopAddons)).Render()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// lets the user browse every version's WTF folder, listing sizes and previewing small files
+// everything goes through wow.Files() and nothing is locked, copied, or written, so it's safe while the game runs
+func (wow WowInstall) explore() error {
+	const quitOption = "[Quit]"
+
+	for {
+		version := askSelect("explore.version", pterm.DefaultInteractiveSelect.
+			WithOptions(append(append([]string{}, wow.Versions...), quitOption)).
+			WithDefaultText("WoW Version to explore"))
+		if version == quitOption {
+			return nil
+		}
+		root := filepath.Join(wow.wtfPath(version), "Account")
+		if _, err := wow.Files().Stat(root); err != nil {
+			pterm.Warning.Printfln("%s has no accounts yet, log into it once first", _wowInstanceFolderNames[version])
+			continue
+		}
+		quit, err := wow.exploreDirectory(root, root)
+		if err != nil || quit {
+			return err
+		}
+	}
+}
+
+// lists dir and lets the user open its folders and files, until they go back above root (false) or quit (true)
+func (wow WowInstall) exploreDirectory(root string, dir string) (bool, error) {
+	const goBackOption = ".. (go back)"
+	const quitOption = "[Quit]"
+
+	for {
+		entries, err := wow.Files().ReadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].IsDir() != entries[j].IsDir() {
+				return entries[i].IsDir()
+			}
+			return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name())
+		})
+
+		relative, _ := filepath.Rel(filepath.Dir(filepath.Dir(root)), dir)
+		table := [][]string{{"Name", "Size", "Modified"}}
+		options := []string{goBackOption}
+		files := make(map[string]string)
+		folders := make(map[string]string)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				option := entry.Name() + string(filepath.Separator)
+				options = append(options, option)
+				folders[option] = entry.Name()
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return false, err
+			}
+			table = append(table, []string{entry.Name(), formatBytes(info.Size()), info.ModTime().Format("2006-01-02 15:04")})
+			option := fmt.Sprintf("%s (%s)", entry.Name(), formatBytes(info.Size()))
+			options = append(options, option)
+			files[option] = entry.Name()
+		}
+		options = append(options, quitOption)
+
+		pterm.DefaultSection.Println(relative)
+		if len(table) > 1 {
+			pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		}
+
+		choice := askSelect("explore.browse", pterm.DefaultInteractiveSelect.
+			WithOptions(options).
+			WithDefaultText(fmt.Sprintf("Open a folder or preview a file in %s", relative)).
+			WithMaxHeight(selectMaxHeight()))
+		switch {
+		case choice == quitOption:
+			return true, nil
+		case choice == goBackOption:
+			if dir == root {
+				return false, nil
+			}
+			dir = filepath.Dir(dir)
+		case folders[choice] != "":
+			dir = filepath.Join(dir, folders[choice])
+		default:
+			if err := previewFile(wow.Files(), filepath.Join(dir, files[choice])); err != nil {
+				pterm.Warning.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"wow-profile-copy/wtf"
+)
+
+// broad categories of filesystem failures, independent of the OS-specific error text
+type fileErrorKind int
+
+const (
+	fileErrorOther fileErrorKind = iota
+	fileErrorNotFound
+	fileErrorPermission
+	fileErrorLocked
+	fileErrorDiskFull
+)
+
+// sorts err into a fileErrorKind
+// never match on err.Error() - windows and unix word the same failures differently
+func classifyFileError(err error) fileErrorKind {
+	switch {
+	case err == nil:
+		return fileErrorOther
+	case errors.Is(err, os.ErrNotExist):
+		return fileErrorNotFound
+	case errors.Is(err, os.ErrPermission):
+		return fileErrorPermission
+	case wtf.IsSharingViolation(err):
+		return fileErrorLocked
+	case isDiskFull(err):
+		return fileErrorDiskFull
+	}
+	return fileErrorOther
+}
+
+// wraps err with a hint on how to fix it, based on its classification
+func explainFileError(err error) error {
+	switch classifyFileError(err) {
+	case fileErrorNotFound:
+		return fmt.Errorf("%w (the file or folder does not exist)", err)
+	case fileErrorPermission:
+		return fmt.Errorf("%w (permission denied - check the file isn't read-only, or try running as the user that owns the WoW install)", err)
+	case fileErrorLocked:
+		return fmt.Errorf("%w (another program has the file open - close WoW and Battle.net and try again)", err)
+	case fileErrorDiskFull:
+		return fmt.Errorf("%w (the disk is full - free up some space and try again)", err)
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+)
+
+// something a retail<->classic copy can't carry over as-is
+type flavorIncompatibility struct {
+	kind   string
+	name   string
+	reason string
+
+	cvar string // the CVar, for CVars the destination client doesn't know
+	dst  string // the step's destination, for SavedVariables
+}
+
+// the CVars in the copied config-cache.wtf files that plan's allowlist drops
+func droppedCVars(p copyplan.Plan) []string {
+	var names []string
+	if p.CVarAllowlist == nil {
+		return nil
+	}
+	var patterns []string
+	for _, pattern := range p.CVarAllowlist {
+		patterns = append(patterns, strings.ToLower(pattern))
+	}
+	for _, step := range p.Steps {
+		if filepath.Base(step.Src) != copyplan.ConfigCacheFileName {
+			continue
+		}
+		file, err := p.SourceFiles().Open(step.Src)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && strings.EqualFold(fields[0], "SET") && !copyplan.MatchesAnyPattern(strings.ToLower(fields[1]), patterns) {
+				names = append(names, fields[1])
+			}
+		}
+		file.Close()
+	}
+	return deduplicateStringSlice(names)
+}
+
+// everything in plan that crossing from the source's flavor to the destination's affects: CVars the
+// destination client doesn't know, SavedVariables copied under another addon's name, and SavedVariables of
+// addons that aren't installed on the destination
+func (wow WowInstall) flavorIncompatibilities(plan copyplan.Plan) []flavorIncompatibility {
+	if copyplan.FlavorFamily(plan.Source.Version) == copyplan.FlavorFamily(plan.Destination.Version) {
+		return nil
+	}
+
+	var found []flavorIncompatibility
+	for _, cvar := range droppedCVars(plan) {
+		found = append(found, flavorIncompatibility{kind: "CVar", name: cvar, reason: fmt.Sprintf("%s doesn't know it", _wowInstanceFolderNames[plan.Destination.Version]), cvar: cvar})
+	}
+
+	dstAddOns := filepath.Join(wow.Dir, plan.Destination.Version, "Interface", "AddOns")
+	_, err := os.Stat(dstAddOns)
+	checkAddOns := err == nil
+	for _, step := range plan.Steps {
+		if step.Category != copyplan.CategoryAccountSavedVariables && step.Category != copyplan.CategoryCharacterSavedVariables {
+			continue
+		}
+		addon := strings.TrimSuffix(filepath.Base(step.Dst), ".lua")
+		if filepath.Base(step.Src) != filepath.Base(step.Dst) {
+			found = append(found, flavorIncompatibility{kind: "SavedVariables", name: filepath.Base(step.Src), reason: fmt.Sprintf("copied as %s, the addon's %s name", filepath.Base(step.Dst), copyplan.FlavorFamily(plan.Destination.Version)), dst: step.Dst})
+		} else if checkAddOns && !strings.HasPrefix(addon, "Blizzard_") && !wow.AddonInstalled(plan.Destination.Version, addon) {
+			found = append(found, flavorIncompatibility{kind: "Addon", name: addon, reason: "not installed on the destination, its SavedVariables would be copied anyway", dst: step.Dst})
+		}
+	}
+	return found
+}
+
+// shows every flavor incompatibility of plan in one table, and lets the user accept or skip them all at once or
+// pick which to accept
+// accepted CVars are copied even though the destination doesn't know them, skipped SavedVariables aren't copied
+// the SavedVariables reviewed here aren't asked about again as conflicts
+func (wow WowInstall) reviewFlavorIncompatibilities(plan *copyplan.Plan) {
+	found := wow.flavorIncompatibilities(*plan)
+	if len(found) == 0 {
+		return
+	}
+
+	table := [][]string{{"Kind", "Name", "Why"}}
+	var labels []string
+	byLabel := make(map[string]flavorIncompatibility)
+	var defaults []string
+	for _, item := range found {
+		table = append(table, []string{item.kind, item.name, item.reason})
+		label := fmt.Sprintf("%s: %s", item.kind, item.name)
+		labels = append(labels, label)
+		byLabel[label] = item
+		// unknown CVars are left out unless accepted, SavedVariables are copied unless skipped
+		if item.cvar == "" {
+			defaults = append(defaults, label)
+		}
+	}
+	pterm.Warning.Printfln("Copying from %s to %s, %d things don't carry over as-is:", _wowInstanceFolderNames[plan.Source.Version], _wowInstanceFolderNames[plan.Destination.Version], len(found))
+	pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+
+	const acceptAll = "Accept all (copy everything listed)"
+	const skipAll = "Skip all (leave everything listed out)"
+	const suggested = "Copy the SavedVariables, leave the unknown CVars out"
+	const pick = "Pick which to copy"
+	var accepted []string
+	switch askSelect("flavor-review", pterm.DefaultInteractiveSelect.
+		WithOptions([]string{suggested, acceptAll, skipAll, pick}).
+		WithDefaultText("What should happen to these?")) {
+	case acceptAll:
+		accepted = labels
+	case skipAll:
+	case pick:
+		accepted = askMultiselect("flavor-review.pick", pterm.DefaultInteractiveMultiselect.
+			WithOptions(labels).
+			WithDefaultOptions(defaults).
+			WithDefaultText("Select what to copy anyway, anything unselected is left out").
+			WithMaxHeight(selectMaxHeight()))
+	default:
+		accepted = defaults
+	}
+
+	keep := make(map[string]bool)
+	for _, label := range accepted {
+		keep[label] = true
+	}
+	plan.Reviewed = make(map[string]bool)
+	// the allowlist is shared with the defaults, accepting CVars mustn't add them to every other plan's
+	plan.CVarAllowlist = append([]string{}, plan.CVarAllowlist...)
+	skippedSteps := make(map[string]bool)
+	for _, label := range labels {
+		item := byLabel[label]
+		switch {
+		case item.cvar != "" && keep[label]:
+			plan.CVarAllowlist = append(plan.CVarAllowlist, item.cvar)
+		case item.dst != "" && keep[label]:
+			plan.Reviewed[item.dst] = true
+		case item.dst != "":
+			skippedSteps[item.dst] = true
+		}
+	}
+
+	var steps []copyplan.Step
+	for _, step := range plan.Steps {
+		if skippedSteps[step.Dst] {
+			plan.Skipped = append(plan.Skipped, copyplan.SkippedFile{Path: step.Src, Reason: "you left it out when reviewing what doesn't carry over between flavors"})
+			continue
+		}
+		steps = append(steps, step)
+	}
+	plan.Steps = steps
+}
@@ -0,0 +1,38 @@
+//go:build ignore
+
+// generates presets.json from wtf/defaults.json, run via go generate
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("wtf/defaults.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var defaults map[string]json.RawMessage
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		log.Fatal(err)
+	}
+
+	// only the lists the community keeps up to date, not the client layout
+	presets := map[string]json.RawMessage{
+		"version": json.RawMessage("1"),
+		"cross_character_account_saved_variables": defaults["cross_character_account_saved_variables"],
+		"combat_log_saved_variables":              defaults["combat_log_saved_variables"],
+		"saved_variables_names_by_flavor":         defaults["saved_variables_names_by_flavor"],
+		"cvars_by_flavor":                         defaults["cvars_by_flavor"],
+	}
+	out, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("presets.json", append(out, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
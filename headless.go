@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+
+	"wow-profile-copy/wtf"
+)
+
+// the interactive prompts need a real terminal on both ends
+// double-clicking from some file managers, or ssh without -t, gives us pipes instead
+func isInteractiveTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// explains how to run without a terminal, for when we can't prompt
+const headlessUsage = `wow-profile-copy needs an interactive terminal to prompt for what to copy.
+Run it from a terminal (cmd, PowerShell, Terminal.app, or ssh -t), or pass everything as flags:
+
+  wow-profile-copy --install-dir <path> --src <version>/<account>/<server>/<character> --dst <version>/<account>/<server>/<character> --yes
+
+<version> is either the folder name (_retail_) or its display name (Retail).`
+
+// a flag that can be given more than once, collecting every value
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// parses a version/account/server/character tuple from the command line, and checks it exists in wow
+// with allowNew, only the account has to exist - the realm and character folders are created by the copy
+func parseCopyTarget(spec string, wow WowInstall, allowNew bool) (wtf.Target, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 4 {
+		return wtf.Target{}, fmt.Errorf("%q should look like <version>/<account>/<server>/<character>", spec)
+	}
+
+	version := resolveVersionName(parts[0])
+	target := wtf.Target{
+		Character: wtf.Character{
+			Account: parts[1],
+			Server:  parts[2],
+			Name:    parts[3],
+		},
+		Version: version,
+	}
+
+	available := false
+	for _, v := range wow.Versions {
+		if v == version {
+			available = true
+			break
+		}
+	}
+	if !available {
+		return target, fmt.Errorf("%s is not installed in %s", parts[0], wow.Dir)
+	}
+
+	configurations := wow.getWtfConfigurations(version)
+	for _, character := range configurations {
+		if character == target.Character {
+			return target, nil
+		}
+	}
+	// a name typed in another case finds its folder, and takes the folder's casing so rewrites use the real name
+	if character, err := matchWtfCase(target.Character, configurations); err != nil {
+		return target, err
+	} else if character != nil {
+		pterm.Info.Printfln("Using %s/%s/%s, the folders' casing of %s", character.Account, character.Server, character.Name, spec)
+		target.Character = *character
+		return target, nil
+	}
+	if allowNew {
+		for _, account := range wow.getAccounts(version) {
+			if strings.EqualFold(account, target.Account) {
+				target.Account = account
+				// new folders on a realm or character that's already there in another case join the existing ones,
+				// case-sensitive file systems would otherwise end up with both
+				target.Character = unifyNewWtfCase(target.Character, configurations)
+				return target, nil
+			}
+		}
+		return target, fmt.Errorf("account %s doesn't exist in %s. Log into it on this version once, first", target.Account, parts[0])
+	}
+	return target, fmt.Errorf("no WTF configuration found for %s. Has that character logged in on this version?", spec)
+}
+
+// the configuration matching target ignoring case, nil if there's none
+// case-sensitive file systems can have several, which can't be told apart from a name typed in another case
+func matchWtfCase(target wtf.Character, configurations []wtf.Character) (*wtf.Character, error) {
+	var matches []wtf.Character
+	for _, character := range configurations {
+		if strings.EqualFold(character.Account, target.Account) && strings.EqualFold(character.Server, target.Server) && strings.EqualFold(character.Name, target.Name) {
+			matches = append(matches, character)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+	var names []string
+	for _, character := range matches {
+		names = append(names, fmt.Sprintf("%s/%s/%s", character.Account, character.Server, character.Name))
+	}
+	return nil, fmt.Errorf("%s/%s/%s matches folders that only differ in case (%s), pass the exact one", target.Account, target.Server, target.Name, strings.Join(names, ", "))
+}
+
+// gives a new target the casing of a realm (and character) the account already has in another case
+func unifyNewWtfCase(target wtf.Character, configurations []wtf.Character) wtf.Character {
+	for _, character := range configurations {
+		if character.Account != target.Account || !strings.EqualFold(character.Server, target.Server) {
+			continue
+		}
+		if character.Server != target.Server {
+			pterm.Info.Printfln("Creating %s in the existing %s folder instead of a new %s", target.Name, character.Server, target.Server)
+			target.Server = character.Server
+		}
+		if strings.EqualFold(character.Name, target.Name) {
+			target.Name = character.Name
+		}
+	}
+	return target
+}
+
+// accepts either a version folder name or its display name, returning the folder name
+func resolveVersionName(name string) string {
+	if _, ok := _wowInstanceFolderNames[name]; ok {
+		return name
+	}
+	for folder, displayName := range _wowInstanceFolderNames {
+		if strings.EqualFold(displayName, name) {
+			return folder
+		}
+	}
+	return name
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+// the companion addon that feeds queued strings to each addon's importer on the next login
+//
+//go:embed companion
+var _companionAddon embed.FS
+
+const (
+	companionAddonName         = "WowProfileCopyImports"
+	companionSavedVariable     = "WowProfileCopyImportsPending"
+	companionSavedVariablesLua = companionAddonName + ".lua"
+)
+
+// a string waiting for its addon to import it in game
+type pendingImport struct {
+	addon string
+	data  string
+}
+
+// works out which addon an export string belongs to from its prefix
+func detectExportStringAddon(data string) (string, error) {
+	for _, known := range savedvars.ExportStringPrefixes {
+		if strings.HasPrefix(data, known.Prefix) {
+			return known.Addon, nil
+		}
+	}
+	return "", errors.New("that doesn't look like a WeakAuras, ElvUI, or Plater export string")
+}
+
+// queues an export string for dst, installing the companion addon if it's missing
+func (wow WowInstall) queueExportString(dst wtf.Target, data string) (string, error) {
+	data = strings.TrimSpace(data)
+	addon, err := detectExportStringAddon(data)
+	if err != nil {
+		return "", err
+	}
+	// dst can be typed in with --dst and --create, it has to stay a character folder of its account
+	path := filepath.Join(wow.CharacterPath(dst), "SavedVariables", companionSavedVariablesLua)
+	if problems := wow.escapingTargetPaths(dst, []string{path}); len(problems) > 0 {
+		return addon, fmt.Errorf("refusing to queue it for %s, it would write outside its account folder:\n%s", dst.CharacterName(), strings.Join(problems, "\n"))
+	}
+	if err := wow.installCompanionAddon(dst.Version); err != nil {
+		return addon, err
+	}
+
+	pending, err := readPendingImports(path)
+	if err != nil {
+		return addon, err
+	}
+	pending = append(pending, pendingImport{addon, data})
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return addon, err
+	}
+	return addon, os.WriteFile(path, []byte(formatPendingImports(pending)), 0644)
+}
+
+// copies the embedded companion addon into version's Interface/AddOns, replacing an older copy
+func (wow WowInstall) installCompanionAddon(version string) error {
+	addOns := filepath.Join(wow.Dir, version, "Interface", "AddOns")
+	return fs.WalkDir(_companionAddon, "companion", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(addOns, filepath.FromSlash(strings.TrimPrefix(path, "companion")))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := _companionAddon.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// matches the entries formatPendingImports writes; the game rewrites the file in the same shape on logout
+var _pendingImportPattern = regexp.MustCompile(`\{\s*\["?addon"?\]?\s*=\s*("(?:[^"\\]|\\.)*")\s*,\s*\["?data"?\]?\s*=\s*("(?:[^"\\]|\\.)*")`)
+
+// reads the queue from an existing SavedVariables file, a missing file is an empty queue
+func readPendingImports(path string) ([]pendingImport, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingImport
+	for _, match := range _pendingImportPattern.FindAllStringSubmatch(string(data), -1) {
+		pending = append(pending, pendingImport{luaUnquote(match[1]), luaUnquote(match[2])})
+	}
+	return pending, nil
+}
+
+func formatPendingImports(pending []pendingImport) string {
+	var builder strings.Builder
+	builder.WriteString("\n" + companionSavedVariable + " = {\n")
+	for _, entry := range pending {
+		fmt.Fprintf(&builder, "\t{\n\t\t[\"addon\"] = %s,\n\t\t[\"data\"] = %s,\n\t},\n", luaQuote(entry.addon), luaQuote(entry.data))
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// quotes s as a Lua string literal
+func luaQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// the inverse of luaQuote
+func luaUnquote(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+	replacer := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r")
+	return replacer.Replace(s)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var _updateGolden = flag.Bool("update", false, "rewrite the golden trees in testdata/integration with what the tests got")
+
+// set in the environment of the test binary's children, which then run main instead of the tests
+const runMainEnvironment = "WPC_INTEGRATION_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnvironment) == "1" {
+		main()
+		exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// an install with a main and an alt on the same realm, which share the account's SavedVariables: an addon's
+// profile choices, and an aggregate of every character's game data
+var _fixtureFiles = map[string]string{
+	"_retail_/WTF/Account/ACC/SavedVariables/Altoholic.lua": `AltoholicDB = {
+	["char"] = {
+		["Main - Area 52"] = {
+			["gold"] = 1000,
+		},
+		["Alt - Area 52"] = {
+			["gold"] = 5,
+		},
+	},
+	["profileKeys"] = {
+		["Main - Area 52"] = "Main - Area 52",
+		["Alt - Area 52"] = "Alt - Area 52",
+	},
+}
+`,
+	"_retail_/WTF/Account/ACC/SavedVariables/Foo.lua": `FooDB = {
+	["profileKeys"] = {
+		["Main - Area 52"] = "Main",
+		["Alt - Area 52"] = "Default",
+	},
+	["profiles"] = {
+		["Main"] = {
+			["scale"] = 0.8,
+		},
+		["Default"] = {
+		},
+	},
+}
+`,
+	"_retail_/WTF/Account/ACC/Area 52/Main/config-cache.wtf":   "SET uiScale \"0.8\"\nSET nameplateShowEnemies \"1\"\n",
+	"_retail_/WTF/Account/ACC/Area 52/Main/bindings-cache.wtf": "BINDINGMODE 0\nbind 1 ACTIONBUTTON1\nbind Q STRAFELEFT\n",
+	"_retail_/WTF/Account/ACC/Area 52/Main/macros-cache.txt":   "",
+	"_retail_/WTF/Account/ACC/Area 52/Main/AddOns.txt":         "Foo: enabled\n",
+	"_retail_/WTF/Account/ACC/Area 52/Main/SavedVariables/Foo.lua": `FooCharDB = {
+	["owner"] = "Main-Area 52",
+	["layout"] = "wide",
+}
+`,
+	"_retail_/WTF/Account/ACC/Area 52/Alt/config-cache.wtf":   "SET uiScale \"1\"\n",
+	"_retail_/WTF/Account/ACC/Area 52/Alt/bindings-cache.wtf": "BINDINGMODE 0\nbind 1 ACTIONBUTTON1\n",
+	"_retail_/WTF/Account/ACC/Area 52/Alt/macros-cache.txt":   "",
+	"_retail_/WTF/Account/ACC/Area 52/Alt/AddOns.txt":         "Foo: disabled\n",
+	"_retail_/WTF/Account/ACC/Area 52/Alt/SavedVariables/Foo.lua": `FooCharDB = {
+	["owner"] = "Alt-Area 52",
+}
+`,
+}
+
+// a fake install and a data directory of its own, so runs never touch the real config, backups, or presets
+type fixture struct {
+	installDir string
+	dataDir    string
+	home       string
+}
+
+func newFixture(t testing.TB) fixture {
+	root := t.TempDir()
+	f := fixture{
+		installDir: filepath.Join(root, "World of Warcraft"),
+		dataDir:    filepath.Join(root, "data"),
+		home:       filepath.Join(root, "home"),
+	}
+	for path, content := range _fixtureFiles {
+		writeFixtureFile(t, filepath.Join(f.installDir, filepath.FromSlash(path)), content)
+	}
+	writeFixtureFile(t, filepath.Join(f.dataDir, "config.yaml"), "offline_presets: true\n")
+	if err := os.MkdirAll(f.home, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func writeFixtureFile(t testing.TB, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runs wpc with args against f, without a terminal, failing the test when it doesn't exit with 0
+func (f fixture) run(t testing.TB, args ...string) {
+	t.Helper()
+	args = append(args, "--install-dir", f.installDir, "--data-dir", f.dataDir, "--no-pause")
+	command := exec.Command(os.Args[0], args...)
+	command.Env = append(os.Environ(), runMainEnvironment+"=1", "HOME="+f.home, "NO_COLOR=1")
+	output, err := command.CombinedOutput()
+	if err != nil {
+		t.Fatalf("wpc %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+// every file of the install with its content, in path order
+func (f fixture) tree(t *testing.T) string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(f.installDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(f.installDir, path)
+		paths = append(paths, filepath.ToSlash(relativePath))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+
+	var tree strings.Builder
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.Join(f.installDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.WriteString("=== " + path + "\n")
+		tree.Write(content)
+	}
+	return tree.String()
+}
+
+// compares the install's tree with testdata/integration/name.golden, or rewrites it with -update
+func (f fixture) checkGolden(t *testing.T, name string) {
+	t.Helper()
+	got := f.tree(t)
+	golden := filepath.Join("testdata", "integration", name+".golden")
+	if *_updateGolden {
+		writeFixtureFile(t, golden, got)
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run go test -run %s -update to create it", err, t.Name())
+	}
+	if got != string(want) {
+		t.Errorf("the install doesn't match %s (go test -update rewrites it)\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+const (
+	fixtureSource      = "retail/ACC/Area 52/Main"
+	fixtureDestination = "retail/ACC/Area 52/Alt"
+)
+
+func TestCopy(t *testing.T) {
+	f := newFixture(t)
+	f.run(t, "copy", "--src", fixtureSource, "--dst", fixtureDestination, "--yes")
+	f.checkGolden(t, "copy")
+
+	// the alt's own gold, inventory and so on stay the alt's
+	aggregate := "_retail_/WTF/Account/ACC/SavedVariables/Altoholic.lua"
+	if content, err := os.ReadFile(filepath.Join(f.installDir, filepath.FromSlash(aggregate))); err != nil || string(content) != _fixtureFiles[aggregate] {
+		t.Errorf("the copy changed %s, an aggregate of every character's own data", aggregate)
+	}
+}
+
+// a saved plan applied later has to do exactly what copying right away does
+func TestPlanApply(t *testing.T) {
+	f := newFixture(t)
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	f.run(t, "plan", "--src", fixtureSource, "--dst", fixtureDestination, "--out", planPath)
+	f.checkGolden(t, "original")
+	f.run(t, "apply", "--plan", planPath, "--yes")
+	f.checkGolden(t, "copy")
+}
+
+func TestRestore(t *testing.T) {
+	f := newFixture(t)
+	before := f.tree(t)
+	f.run(t, "copy", "--src", fixtureSource, "--dst", fixtureDestination, "--yes")
+	if f.tree(t) == before {
+		t.Fatal("the copy didn't change anything, there is nothing to restore")
+	}
+	f.run(t, "restore", "--dst", fixtureDestination, "--yes")
+	if after := f.tree(t); after != before {
+		t.Errorf("restoring didn't put the install back the way it was\ngot:\n%s\nwant:\n%s", after, before)
+	}
+}
+
+// whole copies of many small SavedVariables files, and of one as big as the biggest seen in the wild, backups,
+// verification, and rewriting included
+func BenchmarkCopy(b *testing.B) {
+	for _, size := range []struct {
+		name     string
+		files    int
+		fileSize int
+	}{
+		{"5000x4KB", 5000, 4 << 10},
+		{"1x256MB", 1, 256 << 20},
+	} {
+		b.Run(size.name, func(b *testing.B) {
+			f := newFixture(b)
+			line := `FooCharDB = { ["owner"] = "Main-Area 52", ["layout"] = "wide" }` + "\n"
+			content := strings.Repeat(line, size.fileSize/len(line)+1)
+			for i := 0; i < size.files; i++ {
+				name := fmt.Sprintf("Addon%d.lua", i)
+				writeFixtureFile(b, filepath.Join(f.installDir, "_retail_", "WTF", "Account", "ACC", "Area 52", "Main", "SavedVariables", name), content)
+			}
+			b.SetBytes(int64(size.files * len(content)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f.run(b, "copy", "--src", fixtureSource, "--dst", fixtureDestination, "--yes")
+			}
+		})
+	}
+}
+
+// the jobs run backups first and rewrites last whatever order the file lists them in, so the alt is backed up
+// before it's copied over, and its realm is renamed in what was copied
+func TestBatch(t *testing.T) {
+	f := newFixture(t)
+	altSavedVariables := filepath.Join(f.installDir, "_retail_", "WTF", "Account", "ACC", "Area 52", "Alt", "SavedVariables")
+	jobs := filepath.Join(t.TempDir(), "jobs.yaml")
+	writeFixtureFile(t, jobs, fmt.Sprintf(`concurrency: 2
+jobs:
+  - name: rename
+    operation: rewrite
+    dir: %q
+    from_realm: Area 52
+    to_realm: Stormrage
+  - name: copy
+    src: %s
+    dst: [%s]
+  - name: backup
+    operation: backup
+    src: %s
+`, altSavedVariables, fixtureSource, fixtureDestination, fixtureDestination))
+	f.run(t, "batch", jobs, "--yes")
+
+	if backups, err := os.ReadDir(filepath.Join(f.dataDir, "backups")); err != nil || len(backups) == 0 {
+		t.Errorf("the alt wasn't backed up: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(altSavedVariables, "Foo.lua"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["owner"] = "Alt-Stormrage"`; !strings.Contains(string(content), want) || !strings.Contains(string(content), `"wide"`) {
+		t.Errorf("the alt's SavedVariables weren't copied and then rewritten, want %s in\n%s", want, content)
+	}
+}
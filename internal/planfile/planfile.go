@@ -0,0 +1,79 @@
+// Package planfile is the on-disk form of a copy plan: written by plan, gone through by edit-plan, and executed
+// as-is by apply
+package planfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// bumped whenever a change to Document would make older plans execute differently
+const SupportedVersion = 1
+
+// everything is resolved to absolute paths so applying never has to guess again
+type Document struct {
+	Version            int            `json:"version"`
+	InstallDirectory   string         `json:"install_directory"`
+	SourceInstall      string         `json:"source_install_directory"`
+	Source             Target         `json:"source"`
+	Destination        Target         `json:"destination"`
+	Options            Options        `json:"options"`
+	Steps              []Step         `json:"steps"`
+	DisabledSteps      []Step         `json:"disabled_steps,omitempty"` // turned off in the plan editor, kept to turn back on
+	Extractions        []Step         `json:"extractions,omitempty"`    // account SavedVariables the source character's own data is merged from
+	Skipped            []Skip         `json:"skipped"`
+	Rewrites           []Rule         `json:"rewrites"`
+	CacheInvalidations []string       `json:"cache_invalidations"`
+	CVarAllowlist      []string       `json:"cvar_allowlist,omitempty"`
+	MacroSlots         map[string]int `json:"macro_slots,omitempty"`
+}
+
+type Target struct {
+	Version   string `json:"version"`
+	Account   string `json:"account"`
+	Server    string `json:"server"`
+	Character string `json:"character"`
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", t.Version, t.Account, t.Server, t.Character)
+}
+
+type Options struct {
+	Backup        bool `json:"backup"`
+	Verify        bool `json:"verify"`
+	NormalizeText bool `json:"normalize_text,omitempty"`
+}
+
+type Step struct {
+	Category string `json:"category"`
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Size     int64  `json:"size"`
+}
+
+type Skip struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type Rule struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Regex bool   `json:"regex,omitempty"`
+}
+
+// writes doc as indented JSON to path, or to stdout when path is empty
+func Write(path string, doc Document) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
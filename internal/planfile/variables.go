@@ -0,0 +1,123 @@
+package planfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// a ${NAME} placeholder in a plan, filled in when it's applied
+var _variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// --var NAME=value, repeatable
+type Variables map[string]string
+
+func (v Variables) String() string {
+	var pairs []string
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func (v Variables) Set(pair string) error {
+	name, value, ok := strings.Cut(pair, "=")
+	if !ok || !_variablePattern.MatchString("${"+name+"}") || value == "" {
+		return fmt.Errorf("%q should look like NAME=value", pair)
+	}
+	v[name] = value
+	return nil
+}
+
+// calls replace on every string in doc (not the field names), returning the document it makes
+func (doc Document) mapStrings(replace func(string) (string, error)) (Document, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return doc, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return doc, err
+	}
+
+	var walk func(value any) (any, error)
+	walk = func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			return replace(v)
+		case []any:
+			for i := range v {
+				var err error
+				if v[i], err = walk(v[i]); err != nil {
+					return nil, err
+				}
+			}
+		case map[string]any:
+			for key := range v {
+				var err error
+				if v[key], err = walk(v[key]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return value, nil
+	}
+	if tree, err = walk(tree); err != nil {
+		return doc, err
+	}
+
+	if data, err = json.Marshal(tree); err != nil {
+		return doc, err
+	}
+	var mapped Document
+	err = json.Unmarshal(data, &mapped)
+	return mapped, err
+}
+
+// the ${NAME} variables doc uses, sorted
+func (doc Document) VariableNames() []string {
+	used := make(map[string]bool)
+	doc.mapStrings(func(s string) (string, error) {
+		for _, match := range _variablePattern.FindAllStringSubmatch(s, -1) {
+			used[match[1]] = true
+		}
+		return s, nil
+	})
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replaces every ${NAME} in doc with its value from values, which has one for each of VariableNames
+func (doc Document) Fill(values map[string]string) (Document, error) {
+	return doc.mapStrings(func(s string) (string, error) {
+		return _variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+			return values[match[2:len(match)-1]]
+		}), nil
+	})
+}
+
+// replaces each of vars' values in doc with its ${NAME}, so a plan made for one character can be applied to any
+// other, longer values first so one that contains another keeps its own name
+func (doc Document) Template(vars Variables) (Document, error) {
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(vars[names[i]]) > len(vars[names[j]]) })
+	return doc.mapStrings(func(s string) (string, error) {
+		for _, name := range names {
+			s = strings.ReplaceAll(s, vars[name], "${"+name+"}")
+		}
+		return s, nil
+	})
+}
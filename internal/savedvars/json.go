@@ -0,0 +1,256 @@
+package savedvars
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSON has no room for everything a Lua table can hold, what doesn't fit is wrapped in an object with one of these
+// as its only key: tables with non-string keys as [key, value] pairs, strings that aren't UTF-8 as base64, and
+// numbers JSON can't write (hexadecimal, inf, nan) as their Lua text
+const (
+	jsonTable  = "$lua_table"
+	jsonBytes  = "$lua_bytes"
+	jsonNumber = "$lua_number"
+)
+
+// converts a SavedVariables file to JSON, an object of its globals in file order
+// tables with keys 1..n become arrays, tables with string keys objects, in the order the file has them
+func ToJSON(data []byte) ([]byte, error) {
+	globals, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, global := range globals {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n  ")
+		writeJSONString(&out, global.Name)
+		out.WriteString(": ")
+		writeJSONValue(&out, global.Value, 1)
+	}
+	out.WriteString("\n}\n")
+	return out.Bytes(), nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	encoded, _ := json.Marshal(s)
+	out.Write(encoded)
+}
+
+func writeJSONValue(out *bytes.Buffer, value Value, depth int) {
+	indent := "\n" + strings.Repeat("  ", depth+1)
+	closing := "\n" + strings.Repeat("  ", depth)
+	switch v := value.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			fmt.Fprintf(out, `{"%s": "%s"}`, jsonBytes, base64.StdEncoding.EncodeToString([]byte(v)))
+			return
+		}
+		writeJSONString(out, v)
+	case Number:
+		if json.Valid([]byte(v)) {
+			out.WriteString(string(v))
+			return
+		}
+		fmt.Fprintf(out, `{"%s": `, jsonNumber)
+		writeJSONString(out, string(v))
+		out.WriteString("}")
+	case bool:
+		out.WriteString(strconv.FormatBool(v))
+	case *Table:
+		switch tableShape(v) {
+		case "array":
+			out.WriteString("[")
+			for i, entry := range v.Entries {
+				if i > 0 {
+					out.WriteString(",")
+				}
+				out.WriteString(indent)
+				writeJSONValue(out, entry.Value, depth+1)
+			}
+			out.WriteString(closing + "]")
+		case "object":
+			out.WriteString("{")
+			for i, entry := range v.Entries {
+				if i > 0 {
+					out.WriteString(",")
+				}
+				out.WriteString(indent)
+				writeJSONString(out, entry.Key.(string))
+				out.WriteString(": ")
+				writeJSONValue(out, entry.Value, depth+1)
+			}
+			if len(v.Entries) > 0 {
+				out.WriteString(closing)
+			}
+			out.WriteString("}")
+		default:
+			fmt.Fprintf(out, `{"%s": [`, jsonTable)
+			for i, entry := range v.Entries {
+				if i > 0 {
+					out.WriteString(",")
+				}
+				out.WriteString(indent + "[")
+				writeJSONValue(out, entry.Key, depth+1)
+				out.WriteString(", ")
+				writeJSONValue(out, entry.Value, depth+1)
+				out.WriteString("]")
+			}
+			out.WriteString(closing + "]}")
+		}
+	}
+}
+
+// how a table is written as JSON: "array" for keys 1..n, "object" for string keys, "pairs" for anything else
+// a string key that looks like one of the wrapper keys would be mistaken for it, so it's written as pairs too
+func tableShape(table *Table) string {
+	array, object := len(table.Entries) > 0, true
+	for i, entry := range table.Entries {
+		if number, ok := entry.Key.(Number); !ok || string(number) != strconv.Itoa(i+1) {
+			array = false
+		}
+		if key, ok := entry.Key.(string); !ok || strings.HasPrefix(key, "$lua_") {
+			object = false
+		}
+	}
+	switch {
+	case array:
+		return "array"
+	case object:
+		return "object"
+	}
+	return "pairs"
+}
+
+// converts JSON written by ToJSON (and edited since) back to a SavedVariables file
+func FromJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object of SavedVariables globals")
+	}
+	var globals []Global
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := token.(string)
+		value, err := readJSONValue(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		globals = append(globals, Global{name, value})
+	}
+	return Format(globals), nil
+}
+
+func readJSONValue(decoder *json.Decoder) (Value, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := token.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return Number(v), nil
+	case bool:
+		return v, nil
+	case nil:
+		return nil, fmt.Errorf("null has no place in SavedVariables, leave the field out instead")
+	case json.Delim:
+		if v == '[' {
+			table := &Table{}
+			for decoder.More() {
+				value, err := readJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				table.Entries = append(table.Entries, Entry{Number(strconv.Itoa(len(table.Entries) + 1)), value})
+			}
+			_, err := decoder.Token()
+			return table, err
+		}
+		return readJSONObject(decoder)
+	}
+	return nil, fmt.Errorf("unexpected %v", token)
+}
+
+// reads the rest of an object, which is either a table with string keys or one of the wrappers
+func readJSONObject(decoder *json.Decoder) (Value, error) {
+	table := &Table{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		switch key {
+		case jsonTable:
+			return readJSONPairs(decoder)
+		case jsonBytes, jsonNumber:
+			var text string
+			if err := decoder.Decode(&text); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			if key == jsonNumber {
+				return Number(text), nil
+			}
+			decoded, err := base64.StdEncoding.DecodeString(text)
+			return string(decoded), err
+		}
+		value, err := readJSONValue(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		table.Entries = append(table.Entries, Entry{key, value})
+	}
+	_, err := decoder.Token()
+	return table, err
+}
+
+// reads the [[key, value], ...] of a $lua_table wrapper, and the end of the wrapper
+func readJSONPairs(decoder *json.Decoder) (Value, error) {
+	table := &Table{}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("%s should be a list of [key, value] pairs", jsonTable)
+	}
+	for decoder.More() {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, fmt.Errorf("%s should be a list of [key, value] pairs", jsonTable)
+		}
+		key, err := readJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		if token, err := decoder.Token(); err != nil || token != json.Delim(']') {
+			return nil, fmt.Errorf("%s should be a list of [key, value] pairs", jsonTable)
+		}
+		table.Entries = append(table.Entries, Entry{key, value})
+	}
+	// the list, then the wrapper
+	for i := 0; i < 2; i++ {
+		if _, err := decoder.Token(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return table, nil
+}
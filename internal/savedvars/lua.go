@@ -0,0 +1,524 @@
+// Package savedvars reads and writes the Lua files the game keeps addon data in (SavedVariables), converts them
+// to JSON and back, and rewrites the strings in them without touching anything else
+package savedvars
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the values a SavedVariables file can hold: string, Number, bool, or *Table
+// nil never appears, the game leaves nil fields out when it writes the file
+type Value any
+
+// a number kept as it was written, so converting it back and forth doesn't change it
+type Number string
+
+// a Lua table, with its entries in the order the file has them
+type Table struct {
+	Entries []Entry
+}
+
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+// a top-level assignment of a SavedVariables file, Name = value
+type Global struct {
+	Name  string
+	Value Value
+}
+
+// a parse error with the line it's on
+type SyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// reads the Name = value assignments the game writes to SavedVariables files
+func Parse(data []byte) ([]Global, error) {
+	p := &parser{data: string(data), line: 1}
+	var globals []Global
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return globals, nil
+		}
+		name, ok := p.name()
+		if !ok {
+			return nil, p.errorf("expected a variable name")
+		}
+		p.skipSpace()
+		if !p.consume("=") {
+			return nil, p.errorf("expected = after %s", name)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			globals = append(globals, Global{name, value})
+		}
+	}
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return SyntaxError{p.line, fmt.Sprintf(format, args...)}
+}
+
+// skips whitespace and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case strings.HasPrefix(p.data[p.pos:], "--"):
+			// --[[ and --[==[ comments run to their matching closing bracket, any other to the end of the line
+			level, long := longBracketLevel(p.data[p.pos+2:])
+			if !long {
+				for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+					p.pos++
+				}
+				continue
+			}
+			start := p.pos + 2 + level + 2
+			end := len(p.data)
+			if i := strings.Index(p.data[start:], "]"+strings.Repeat("=", level)+"]"); i >= 0 {
+				end = start + i + level + 2
+			}
+			p.line += strings.Count(p.data[p.pos:end], "\n")
+			p.pos = end
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.data[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+func (p *parser) name() (string, bool) {
+	start := p.pos
+	for p.pos < len(p.data) && isNameByte(p.data[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return p.data[start:p.pos], p.pos > start
+}
+
+func (p *parser) value() (Value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.table()
+	case c == '"' || c == '\'':
+		return p.quotedString()
+	case c == '[' && (strings.HasPrefix(p.data[p.pos:], "[[") || strings.HasPrefix(p.data[p.pos:], "[=")):
+		return p.longString()
+	case c == '-' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	}
+	word, _ := p.name()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	case "inf", "nan":
+		return Number(word), nil
+	}
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	return nil, p.errorf("unexpected %q", word+string(p.data[p.pos]))
+}
+
+func (p *parser) number() (Value, error) {
+	start := p.pos
+	if p.data[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		// an exponent's sign, after e in decimal and p in hexadecimal numbers
+		sign := false
+		if (c == '-' || c == '+') && p.pos > start {
+			hex := strings.HasPrefix(strings.ToLower(strings.TrimPrefix(p.data[start:p.pos], "-")), "0x")
+			previous := p.data[p.pos-1] | 0x20
+			sign = !hex && previous == 'e' || hex && previous == 'p'
+		}
+		if !(c == '.' || isNameByte(c, false) || sign) {
+			break
+		}
+		p.pos++
+	}
+	text := p.data[start:p.pos]
+	if text == "-" || text == "" {
+		return nil, p.errorf("malformed number")
+	}
+	return Number(text), nil
+}
+
+func (p *parser) quotedString() (Value, error) {
+	quote := p.data[p.pos]
+	p.pos++
+	var out strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return out.String(), nil
+		case c == '\n':
+			return nil, p.errorf("unfinished string")
+		case c == '\\' && p.pos+1 < len(p.data):
+			p.pos++
+			if err := p.escape(&out); err != nil {
+				return nil, err
+			}
+		default:
+			out.WriteByte(c)
+			p.pos++
+		}
+	}
+	return nil, p.errorf("unfinished string")
+}
+
+// reads the escape after a backslash
+func (p *parser) escape(out *strings.Builder) error {
+	c := p.data[p.pos]
+	simple := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', 'a': '\a', 'b': '\b', 'f': '\f', 'v': '\v', '\\': '\\', '"': '"', '\'': '\'', '\n': '\n'}
+	if replacement, ok := simple[c]; ok {
+		if c == '\n' {
+			p.line++
+		}
+		out.WriteByte(replacement)
+		p.pos++
+		return nil
+	}
+	if c >= '0' && c <= '9' {
+		end := p.pos
+		for end < len(p.data) && end < p.pos+3 && p.data[end] >= '0' && p.data[end] <= '9' {
+			end++
+		}
+		code, err := strconv.Atoi(p.data[p.pos:end])
+		if err != nil || code > 255 {
+			return p.errorf("bad escape \\%s", p.data[p.pos:end])
+		}
+		out.WriteByte(byte(code))
+		p.pos = end
+		return nil
+	}
+	return p.errorf("bad escape \\%c", c)
+}
+
+func (p *parser) longString() (Value, error) {
+	level := 0
+	p.pos++
+	for p.pos < len(p.data) && p.data[p.pos] == '=' {
+		level++
+		p.pos++
+	}
+	if !p.consume("[") {
+		return nil, p.errorf("malformed long string")
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(p.data[p.pos:], closing)
+	if end < 0 {
+		return nil, p.errorf("unfinished long string")
+	}
+	text := strings.TrimPrefix(p.data[p.pos:p.pos+end], "\n")
+	p.line += strings.Count(p.data[p.pos:p.pos+end], "\n")
+	p.pos += end + len(closing)
+	return text, nil
+}
+
+func (p *parser) table() (Value, error) {
+	p.pos++
+	table := &Table{}
+	index := 1
+	for {
+		p.skipSpace()
+		if p.consume("}") {
+			return table, nil
+		}
+
+		var key Value
+		start, startLine := p.pos, p.line
+		switch {
+		case strings.HasPrefix(p.data[p.pos:], "[") && !strings.HasPrefix(p.data[p.pos:], "[[") && !strings.HasPrefix(p.data[p.pos:], "[="):
+			p.pos++
+			var err error
+			if key, err = p.value(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if !p.consume("]") {
+				return nil, p.errorf("expected ] after a key")
+			}
+			p.skipSpace()
+			if !p.consume("=") {
+				return nil, p.errorf("expected = after a key")
+			}
+		default:
+			// Name = value, or a positional value
+			if name, ok := p.name(); ok && name != "true" && name != "false" && name != "nil" {
+				p.skipSpace()
+				if p.consume("=") {
+					key = name
+					break
+				}
+			}
+			p.pos, p.line = start, startLine
+		}
+
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			key = Number(strconv.Itoa(index))
+			index++
+		}
+		if value != nil {
+			table.Entries = append(table.Entries, Entry{key, value})
+		}
+
+		p.skipSpace()
+		if !p.consume(",") && !p.consume(";") {
+			p.skipSpace()
+			if !p.consume("}") {
+				return nil, p.errorf("expected , or } in a table")
+			}
+			return table, nil
+		}
+	}
+}
+
+// writes globals the way the game writes SavedVariables files, tab indented with array entries commented
+// the game writes keys in whatever order its hash tables hold them, we sort them (and the globals) instead, so
+// writing the same data twice gives the same file and diffs of merged or converted files only show real changes
+func Format(globals []Global) []byte {
+	sorted := append([]Global{}, globals...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	var out strings.Builder
+	for _, global := range sorted {
+		out.WriteString(global.Name + " = ")
+		writeValue(&out, global.Value, 0)
+		out.WriteString("\n")
+	}
+	return []byte(out.String())
+}
+
+func writeValue(out *strings.Builder, value Value, depth int) {
+	switch v := value.(type) {
+	case string:
+		out.WriteString(quoteString(v))
+	case Number:
+		out.WriteString(string(v))
+	case bool:
+		out.WriteString(strconv.FormatBool(v))
+	case *Table:
+		out.WriteString("{\n")
+		indent := strings.Repeat("\t", depth+1)
+		index := 1
+		for _, entry := range sortedEntries(v.Entries) {
+			out.WriteString(indent)
+			if number, ok := entry.Key.(Number); ok && string(number) == strconv.Itoa(index) {
+				writeValue(out, entry.Value, depth+1)
+				fmt.Fprintf(out, ", -- [%d]\n", index)
+				index++
+				continue
+			}
+			out.WriteString("[")
+			writeValue(out, entry.Key, depth+1)
+			out.WriteString("] = ")
+			writeValue(out, entry.Value, depth+1)
+			out.WriteString(",\n")
+		}
+		out.WriteString(strings.Repeat("\t", depth) + "}")
+	default:
+		out.WriteString("nil")
+	}
+}
+
+// entries in the order they're written: the array part (1, 2, 3, ... with no gaps) first, then numbers, strings,
+// and booleans, each in ascending order
+func sortedEntries(entries []Entry) []Entry {
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		if number, ok := entry.Key.(Number); ok {
+			present[string(number)] = true
+		}
+	}
+	arrayLength := 0
+	for present[strconv.Itoa(arrayLength+1)] {
+		arrayLength++
+	}
+
+	// the array part, then numbers, then strings, then booleans
+	rank := func(key Value) int {
+		switch k := key.(type) {
+		case Number:
+			if index, err := strconv.Atoi(string(k)); err == nil && index >= 1 && index <= arrayLength {
+				return 0
+			}
+			return 1
+		case string:
+			return 2
+		}
+		return 3
+	}
+	sorted := append([]Entry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Key, sorted[j].Key
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		switch a := a.(type) {
+		case Number:
+			x, _ := strconv.ParseFloat(string(a), 64)
+			y, _ := strconv.ParseFloat(string(b.(Number)), 64)
+			if x != y {
+				return x < y
+			}
+			return a < b.(Number)
+		case string:
+			return a < b.(string)
+		case bool:
+			return !a && b.(bool)
+		}
+		return false
+	})
+	return sorted
+}
+
+// quotes s the way the game does, escaping only what a Lua string literal needs
+func quoteString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case 0:
+			// all three digits, \0 followed by a digit of the string would read as a different byte
+			out.WriteString(`\000`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// the level of the long bracket data starts with, [[ is 0 and [==[ is 2
+func longBracketLevel[T string | []byte](data T) (int, bool) {
+	if len(data) == 0 || data[0] != '[' {
+		return 0, false
+	}
+	level := 1
+	for level < len(data) && data[level] == '=' {
+		level++
+	}
+	if level == len(data) || data[level] != '[' {
+		return 0, false
+	}
+	return level - 1, true
+}
+
+// where the long string or comment that starts data with a level bracket ends, the whole rest when it doesn't
+func longBracketEnd(data []byte, level int) int {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := bytes.Index(data[level+2:], []byte(closing))
+	if end < 0 {
+		return len(data)
+	}
+	return level + 2 + end + len(closing)
+}
+
+// calls replace on every string literal in data, quotes and brackets included, returning data with what it
+// gives back in their place, comments and everything else are kept byte for byte
+// an unfinished string runs to the end of its line, so a damaged file is still only changed inside strings
+func MapStrings(data []byte, replace func(literal []byte) []byte) []byte {
+	var out bytes.Buffer
+	copied := 0
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '-' && bytes.HasPrefix(data[i:], []byte("--")):
+			if level, ok := longBracketLevel(data[i+2:]); ok {
+				i += 2 + longBracketEnd(data[i+2:], level)
+				continue
+			}
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			start := i
+			for i++; i < len(data) && data[i] != c && data[i] != '\n'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(data) && data[i] == c {
+				i++
+			}
+			if i > len(data) {
+				i = len(data)
+			}
+			out.Write(data[copied:start])
+			out.Write(replace(data[start:i]))
+			copied = i
+		case c == '[':
+			level, ok := longBracketLevel(data[i:])
+			if !ok {
+				i++
+				continue
+			}
+			start := i
+			i += longBracketEnd(data[i:], level)
+			out.Write(data[copied:start])
+			out.Write(replace(data[start:i]))
+			copied = i
+		default:
+			i++
+		}
+	}
+	out.Write(data[copied:])
+	return out.Bytes()
+}
@@ -0,0 +1,258 @@
+package savedvars
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// a file the way the game writes them, with the things it only sometimes does: comments, long strings,
+// escapes, and array entries mixed with keyed ones
+const sampleSavedVariables = `
+DetailsDB = {
+	["profiles"] = {
+		["Thrall - Draenor"] = {
+			["bars"] = 12,
+			["scale"] = 0.85,
+			["enabled"] = true,
+			["font"] = "Fonts\\FRIZQT__.TTF",
+		},
+	},
+	["notes"] = [==[line one
+line "two"]==],
+	{
+		"first", -- [1]
+		"second", -- [2]
+	}, -- [1]
+	[-1.5e3] = 'single\'s',
+	[false] = "\0001\r\n",
+}
+DetailsCharacter = nil
+-- [[ a comment ]] "not a string"
+--[=[ a long
+comment ]=]
+DetailsVersion = 0x1F
+`
+
+func addLuaSeeds(f *testing.F) {
+	f.Add([]byte(sampleSavedVariables))
+	f.Add([]byte(`A = "WA:!abc" B = [[x]]`))
+	f.Add([]byte(`A = { [1] = 1, [3] = 3, [2] = 2, }`))
+	f.Add([]byte(`A = "unfinished`))
+	f.Add([]byte(""))
+}
+
+// globals in the order Format writes them, tables' entries included, so data read back from a formatted file
+// compares equal to the data it was formatted from
+func canonical(globals []Global) []Global {
+	sorted := make([]Global, len(globals))
+	for i, global := range globals {
+		sorted[i] = Global{global.Name, canonicalValue(global.Value)}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+func canonicalValue(value Value) Value {
+	table, ok := value.(*Table)
+	if !ok {
+		return value
+	}
+	sorted := &Table{}
+	for _, entry := range sortedEntries(table.Entries) {
+		sorted.Entries = append(sorted.Entries, Entry{canonicalValue(entry.Key), canonicalValue(entry.Value)})
+	}
+	return sorted
+}
+
+// every string of globals, keys included, in the order the file has them
+func collectStrings(globals []Global) []string {
+	var strs []string
+	var walk func(value Value)
+	walk = func(value Value) {
+		switch v := value.(type) {
+		case string:
+			strs = append(strs, v)
+		case *Table:
+			for _, entry := range v.Entries {
+				walk(entry.Key)
+				walk(entry.Value)
+			}
+		}
+	}
+	for _, global := range globals {
+		walk(global.Value)
+	}
+	return strs
+}
+
+// whatever Parse accepts, Format has to write back to something Parse reads as the same data
+func FuzzParseLua(f *testing.F) {
+	addLuaSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		globals, err := Parse(data)
+		if err != nil {
+			return
+		}
+		formatted := Format(globals)
+		reparsed, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("can't read back what Format wrote: %v\n%s", err, formatted)
+		}
+		if !reflect.DeepEqual(canonical(reparsed), canonical(globals)) {
+			t.Fatalf("the data changed going through Format and Parse\nread:\n%s\nformatted:\n%s", data, formatted)
+		}
+	})
+}
+
+// leaving every string as it is has to leave the whole file as it is, damaged or not, and the strings MapStrings
+// finds in a file Parse reads have to be the ones Parse reads
+func FuzzMapLuaStrings(f *testing.F) {
+	addLuaSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var literals [][]byte
+		mapped := MapStrings(data, func(literal []byte) []byte {
+			literals = append(literals, literal)
+			return literal
+		})
+		if !bytes.Equal(mapped, data) {
+			t.Fatalf("an identity replace changed the file\nbefore: %q\nafter:  %q", data, mapped)
+		}
+
+		globals, err := Parse(data)
+		if err != nil {
+			return
+		}
+		var found []string
+		for _, literal := range literals {
+			value, ok := literalValue(literal)
+			if !ok {
+				t.Fatalf("MapStrings found %q in a file Parse reads, which isn't a string", literal)
+			}
+			found = append(found, value)
+		}
+		if parsed := collectStrings(globals); !reflect.DeepEqual(found, parsed) && len(found)+len(parsed) > 0 {
+			t.Fatalf("MapStrings and Parse disagree on the strings of %q\nMapStrings: %q\nParse:      %q", data, found, parsed)
+		}
+	})
+}
+
+// rewriting a file has to give the data rewriting its parsed strings gives, written so Parse reads it, and a
+// rewrite that counts no replacements has to leave the file as it is
+func FuzzRewrite(f *testing.F) {
+	f.Add([]byte(sampleSavedVariables), "Thrall - Draenor", "Jaina - Proudmoore", false)
+	f.Add([]byte(sampleSavedVariables), `^(\w+)$`, `"${1}\`, true)
+	f.Add([]byte(`A = { ["Main-Area 52"] = [[Main-Area 52]], "!WA:Main-Area 52" }`), "Main-Area 52", "Alt", false)
+	f.Add([]byte(`A = "x" -- "x"`), `x*`, `]]`, true)
+	f.Fuzz(func(t *testing.T, data []byte, from string, to string, regex bool) {
+		rule, err := NewRule(from, to, regex)
+		if err != nil {
+			return
+		}
+		rules := []Rule{rule}
+		rewritten := Rewrite(data, rules)
+		if CountRewrites(data, rules) == 0 && !bytes.Equal(rewritten, data) {
+			t.Fatalf("no replacements were counted but the file changed\nbefore: %q\nafter:  %q", data, rewritten)
+		}
+
+		globals, err := Parse(data)
+		if err != nil {
+			return
+		}
+		got, err := Parse(rewritten)
+		if err != nil {
+			t.Fatalf("the rewritten file doesn't parse: %v\n%q", err, rewritten)
+		}
+		var rewriteStrings func(value Value) Value
+		rewriteStrings = func(value Value) Value {
+			switch v := value.(type) {
+			case string:
+				if isEncodedBlob(v) {
+					return v
+				}
+				rewrittenValue := []byte(v)
+				for _, rule := range rules {
+					rewrittenValue, _ = rule.Replace(rewrittenValue)
+				}
+				return string(rewrittenValue)
+			case *Table:
+				table := &Table{}
+				for _, entry := range v.Entries {
+					table.Entries = append(table.Entries, Entry{rewriteStrings(entry.Key), rewriteStrings(entry.Value)})
+				}
+				return table
+			}
+			return value
+		}
+		var want []Global
+		for _, global := range globals {
+			want = append(want, Global{global.Name, rewriteStrings(global.Value)})
+		}
+		if !reflect.DeepEqual(canonical(got), canonical(want)) {
+			t.Fatalf("rewriting the file and rewriting its data disagree\nfile:      %q\nrewritten: %q", data, rewritten)
+		}
+	})
+}
+
+// the sizes benchmarks run at, from an ordinary addon's file to the largest ones seen in the wild (TSM, Details
+// with combat history, Altoholic on a big account)
+var _benchmarkSizes = []struct {
+	name string
+	size int
+}{
+	{"1MB", 1 << 20},
+	{"32MB", 32 << 20},
+	{"256MB", 256 << 20},
+}
+
+// a SavedVariables file of about size bytes, an account-wide addon's per-character entries, with names to rewrite,
+// export strings to leave alone, and the nesting and array entries real files have
+func syntheticSavedVariables(size int) []byte {
+	var out bytes.Buffer
+	out.WriteString("BenchmarkDB = {\n\t[\"char\"] = {\n")
+	for i := 0; out.Len() < size; i++ {
+		fmt.Fprintf(&out, "\t\t[\"Char%d - Area 52\"] = {\n", i)
+		fmt.Fprintf(&out, "\t\t\t[\"gold\"] = %d,\n\t\t\t[\"scale\"] = 0.%d,\n", i*37, i%100)
+		out.WriteString("\t\t\t[\"owner\"] = \"Main-Area 52\",\n\t\t\t[\"profile\"] = \"Main - Area 52\",\n")
+		out.WriteString("\t\t\t[\"export\"] = \"!WA:2!TR1tVTTrv4OYbs0mSPLRPjzJ6XSZ3XttskOIhOJ9lIQ8JIsqbNUEVTZPjEbA\",\n")
+		out.WriteString("\t\t\t[\"history\"] = {\n\t\t\t\t\"Main-Area 52\", -- [1]\n\t\t\t\t\"Alt-Area 52\", -- [2]\n\t\t\t},\n")
+		out.WriteString("\t\t},\n")
+	}
+	out.WriteString("\t},\n}\n")
+	return out.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, size := range _benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			data := syntheticSavedVariables(size.size)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	for _, size := range _benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			globals, err := Parse(syntheticSavedVariables(size.size))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(Format(globals))))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Format(globals)
+			}
+		})
+	}
+}
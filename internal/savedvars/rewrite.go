@@ -0,0 +1,146 @@
+package savedvars
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// a search and replace applied to the strings in a SavedVariables file, literal unless made by NewRule with regex
+type Rule struct {
+	From    string
+	To      string
+	pattern *regexp.Regexp // From compiled, To can use $1 and ${name}
+}
+
+// a rule from config.yaml or a plan file, checking a regular expression compiles
+func NewRule(from string, to string, regex bool) (Rule, error) {
+	rule := Rule{From: from, To: to}
+	if from == "" {
+		return rule, errors.New("a rewrite rule needs something to replace in from")
+	}
+	if regex {
+		pattern, err := regexp.Compile(from)
+		if err != nil {
+			return rule, fmt.Errorf("rewrite rule %q isn't a valid regular expression: %w", from, err)
+		}
+		rule.pattern = pattern
+	}
+	return rule, nil
+}
+
+// whether From is a regular expression
+func (r Rule) Regex() bool {
+	return r.pattern != nil
+}
+
+// applies the rule to s, returning the result and how many replacements it made
+func (r Rule) Replace(s []byte) ([]byte, int) {
+	if r.pattern != nil {
+		return r.pattern.ReplaceAll(s, []byte(r.To)), len(r.pattern.FindAllIndex(s, -1))
+	}
+	return bytes.ReplaceAll(s, []byte(r.From), []byte(r.To)), bytes.Count(s, []byte(r.From))
+}
+
+// how each supported addon's export strings start
+var ExportStringPrefixes = []struct {
+	Addon  string
+	Prefix string
+}{
+	{"WeakAuras", "!WA:"},
+	{"Plater", "!PLATER:"},
+	{"ElvUI", "!E1!"},
+}
+
+// encoded strings shorter than this are still rewritten, a name can be nothing but letters too
+const encodedBlobMinLength = 64
+
+// the characters LibDeflate's EncodeForPrint and base64 write, encoded payloads have nothing else
+var _encodedBlobPattern = regexp.MustCompile(`^[A-Za-z0-9()+/=]+$`)
+
+// whether content, a string's value, is a compressed or encoded blob, like a WeakAuras export string or a
+// LibDeflate payload, a name turning up in one is a coincidence and replacing it would corrupt the blob
+func isEncodedBlob(content string) bool {
+	for _, known := range ExportStringPrefixes {
+		if strings.HasPrefix(content, known.Prefix) {
+			return true
+		}
+	}
+	return len(content) >= encodedBlobMinLength && _encodedBlobPattern.MatchString(content)
+}
+
+// the value of literal, a string literal as MapStrings passes them, without its quotes or brackets and with its
+// escapes undone, false for one that's damaged, e.g. unfinished
+func literalValue(literal []byte) (string, bool) {
+	p := &parser{data: string(literal), line: 1}
+	var value Value
+	var err error
+	if literal[0] == '[' {
+		value, err = p.longString()
+	} else {
+		value, err = p.quotedString()
+	}
+	if err != nil || p.pos != len(p.data) {
+		return "", false
+	}
+	return value.(string), true
+}
+
+// applies rules, in order, to the value of literal, returning how many replacements they made and the literal
+// written again (quoted and escaped the way Format does it) when they changed the value, as it was otherwise
+// damaged literals and encoded blobs are left as they are
+func rewriteLiteral(literal []byte, rules []Rule) ([]byte, int) {
+	value, ok := literalValue(literal)
+	if !ok || isEncodedBlob(value) {
+		return literal, 0
+	}
+	rewritten := []byte(value)
+	count := 0
+	for _, rule := range rules {
+		var replaced int
+		rewritten, replaced = rule.Replace(rewritten)
+		count += replaced
+	}
+	if string(rewritten) == value {
+		return literal, count
+	}
+	return []byte(quoteString(string(rewritten))), count
+}
+
+// applies rules, in order, to the strings in data, a SavedVariables file
+// names only ever appear in strings, so comments and code that happen to contain one are left alone, and so are
+// encoded blobs (the keys around them are still rewritten)
+// rules see each string's value, so they can't touch its quotes, ^ and $ anchor at its ends, and whatever they
+// replace it with is escaped
+func Rewrite(data []byte, rules []Rule) []byte {
+	return MapStrings(data, func(literal []byte) []byte {
+		rewritten, _ := rewriteLiteral(literal, rules)
+		return rewritten
+	})
+}
+
+// how many replacements applying rules, in order, to data would make
+func CountRewrites(data []byte, rules []Rule) int {
+	count := 0
+	MapStrings(data, func(literal []byte) []byte {
+		_, replaced := rewriteLiteral(literal, rules)
+		count += replaced
+		return literal
+	})
+	return count
+}
+
+// applies rules to the strings in value, by way of the file format they're written for
+func RewriteValue(value Value, rules []Rule) (Value, error) {
+	if len(rules) == 0 {
+		return value, nil
+	}
+	data := Rewrite(Format([]Global{{"value", value}}), rules)
+	globals, err := Parse(data)
+	if err != nil || len(globals) != 1 {
+		return nil, errors.New("rewriting made the data unreadable")
+	}
+	return globals[0].Value, nil
+}
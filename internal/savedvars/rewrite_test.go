@@ -0,0 +1,103 @@
+package savedvars
+
+import "testing"
+
+func TestRewrite(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		from, to  string
+		regex     bool
+		data      string
+		rewritten string
+	}{
+		{"a name in a key and a value", "Main-Area 52", "Alt-Area 52", false,
+			`A = { ["Main-Area 52"] = "Main-Area 52's", } -- Main-Area 52`,
+			`A = { ["Alt-Area 52"] = "Alt-Area 52's", } -- Main-Area 52`},
+		{"anchors match the value's ends", "^Main$", "Alt", true,
+			`A = { "Main", "Mainly", 'Main' }`,
+			`A = { "Alt", "Mainly", "Alt" }`},
+		{"a regex can't reach the quotes", `.*`, "x", true,
+			`A = "Main"`,
+			`A = "x"`},
+		{"quotes and backslashes in to are escaped", "Guild", `Say "hi" \o/`, false,
+			`A = "Guild"`,
+			`A = "Say \"hi\" \\o/"`},
+		{"escapes in the file are undone first", "Main\nAlt", "x", true,
+			`A = "Main\nAlt"`,
+			`A = "x"`},
+		{"long strings are rewritten as quoted ones", "Main", "Alt]]", false,
+			`A = [==[Main]==]`,
+			`A = "Alt]]"`},
+		{"unchanged strings keep their form", "Main", "Alt", false,
+			`A = [[other]] B = 'other'`,
+			`A = [[other]] B = 'other'`},
+		{"encoded blobs are left alone", "Main", "Alt", false,
+			`A = "!WA:2!Main"`,
+			`A = "!WA:2!Main"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := NewRule(test.from, test.to, test.regex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rewritten := string(Rewrite([]byte(test.data), []Rule{rule}))
+			if rewritten != test.rewritten {
+				t.Errorf("got  %s\nwant %s", rewritten, test.rewritten)
+			}
+			if _, err := Parse([]byte(rewritten)); err != nil {
+				t.Errorf("the rewritten file doesn't parse: %v", err)
+			}
+		})
+	}
+}
+
+// the rules copying Main-Area 52 to Alt-Area 52 makes, plus one regular expression like config.yaml can have
+func benchmarkRules(b *testing.B) []Rule {
+	var rules []Rule
+	for _, spec := range []struct {
+		from, to string
+		regex    bool
+	}{
+		{"Main - Area 52", "Alt - Area 52", false},
+		{"Main-Area 52", "Alt-Area 52", false},
+		{`Char(\d+) - Area 52`, "Char$1 - Stormrage", true},
+	} {
+		rule, err := NewRule(spec.from, spec.to, spec.regex)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func BenchmarkRewrite(b *testing.B) {
+	rules := benchmarkRules(b)
+	for _, size := range _benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			data := syntheticSavedVariables(size.size)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Rewrite(data, rules)
+			}
+		})
+	}
+}
+
+// the dry run's count, which reads the same files without keeping the result
+func BenchmarkCountRewrites(b *testing.B) {
+	rules := benchmarkRules(b)
+	for _, size := range _benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			data := syntheticSavedVariables(size.size)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				CountRewrites(data, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package savedvars
+
+import "strings"
+
+// the entry of t under key, nil if there is none
+func (t *Table) Get(key Value) Value {
+	for _, entry := range t.Entries {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+	return nil
+}
+
+// replaces the entry of t under key, adding it at the end if there is none
+func (t *Table) Set(key Value, value Value) {
+	for i, entry := range t.Entries {
+		if entry.Key == key {
+			t.Entries[i].Value = value
+			return
+		}
+	}
+	t.Entries = append(t.Entries, Entry{key, value})
+}
+
+// the value at path under global, nil if any of it is missing
+func Lookup(globals []Global, global string, path []Value) Value {
+	var value Value
+	for _, g := range globals {
+		if g.Name == global {
+			value = g.Value
+		}
+	}
+	for _, key := range path {
+		table, ok := value.(*Table)
+		if !ok {
+			return nil
+		}
+		value = table.Get(key)
+	}
+	return value
+}
+
+// puts value at path under global, making whatever tables on the way are missing
+func SetPath(globals []Global, global string, path []Value, value Value) []Global {
+	index := -1
+	for i, g := range globals {
+		if g.Name == global {
+			index = i
+		}
+	}
+	if index < 0 {
+		globals = append(globals, Global{Name: global})
+		index = len(globals) - 1
+	}
+	if len(path) == 0 {
+		globals[index].Value = value
+		return globals
+	}
+	table, ok := globals[index].Value.(*Table)
+	if !ok {
+		table = &Table{}
+		globals[index].Value = table
+	}
+	for _, key := range path[:len(path)-1] {
+		next, ok := table.Get(key).(*Table)
+		if !ok {
+			next = &Table{}
+			table.Set(key, next)
+		}
+		table = next
+	}
+	table.Set(path[len(path)-1], value)
+	return globals
+}
+
+// a key path the way it'd be written in Lua, like AddonDB["char"]["Name - Realm"]
+func FormatPath(global string, path []Value) string {
+	var out strings.Builder
+	out.WriteString(global)
+	for _, key := range path {
+		out.WriteString("[")
+		writeValue(&out, key, 0)
+		out.WriteString("]")
+	}
+	return out.String()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// the game client of version, or "" if there's none this platform can start
+// Wow.exe, WowClassic.exe, WowT.exe... on windows, World of Warcraft.app (and its classic siblings) on macOS
+func (wow WowInstall) gameClient(version string) string {
+	var pattern string
+	switch runtime.GOOS {
+	case "windows":
+		pattern = "Wow*.exe"
+	case "darwin":
+		pattern = "World of Warcraft*.app"
+	default:
+		// wine and lutris setups each have their own way of starting the game
+		return ""
+	}
+	matches, _ := filepath.Glob(filepath.Join(wow.Dir, version, pattern))
+	var clients []string
+	for _, match := range matches {
+		// crash reporters and helpers live next to the client, e.g. WowError.exe
+		if !strings.Contains(strings.ToLower(filepath.Base(match)), "error") {
+			clients = append(clients, match)
+		}
+	}
+	if len(clients) == 0 {
+		return ""
+	}
+	// the plain client has the shortest name, e.g. Wow.exe over Wow-64.exe
+	sort.Slice(clients, func(i, j int) bool {
+		return len(clients[i]) < len(clients[j])
+	})
+	return clients[0]
+}
+
+// starts client without waiting for it, the game hands over to Battle.net to log in
+func launchGameClient(client string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("open", client)
+	} else {
+		cmd = exec.Command(client)
+		cmd.Dir = filepath.Dir(client)
+	}
+	return cmd.Start()
+}
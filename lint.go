@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// how many bad lines are listed per file, the rest are counted
+const maxLintLines = 5
+
+// something in a settings file that commonly makes the client throw it away and start over from defaults
+type lintProblem struct {
+	path    string
+	line    int // 0 for the file as a whole
+	problem string
+}
+
+func (p lintProblem) String() string {
+	if p.line == 0 {
+		return fmt.Sprintf("%s: %s", p.path, p.problem)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.path, p.line, p.problem)
+}
+
+// what each line of the settings files the client parses line by line looks like, by lowercased file name
+var _settingsLinePatterns = map[string]*regexp.Regexp{
+	"config-cache.wtf":   regexp.MustCompile(`^SET \S+ ".*"$`),
+	"bindings-cache.wtf": regexp.MustCompile(`^(BINDINGMODE \d+|bind \S+ .+|modifiedclick \S+ \S+)$`),
+	"addons.txt":         regexp.MustCompile(`^[^:]+: (enabled|disabled)$`),
+}
+
+// the header line of each macro in macros-cache.txt
+var _macroHeaderPattern = regexp.MustCompile(`^MACRO \d+ ".*" \S+$`)
+
+// whether lint knows how to check a file
+func lintable(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	return _settingsLinePatterns[name] != nil || name == copyplan.MacrosFileName
+}
+
+// checks a settings file's encoding, line endings, and line format
+func lintSettings(path string, data []byte) []lintProblem {
+	var problems []lintProblem
+	fileProblem := func(problem string) {
+		problems = append(problems, lintProblem{path, 0, problem})
+	}
+
+	if len(data) == 0 {
+		fileProblem("it's empty")
+		return problems
+	}
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		fileProblem("it starts with a byte order mark the client doesn't expect (--normalize-text strips it)")
+		data = data[3:]
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		fileProblem("it has NUL bytes in it, usually a file cut short by a crash or a full disk")
+	}
+	if !utf8.Valid(data) {
+		fileProblem("it isn't valid UTF-8")
+	}
+	if crlf := bytes.Count(data, []byte("\r\n")); crlf > 0 && crlf != bytes.Count(data, []byte("\n")) {
+		fileProblem("it mixes Windows and Unix line endings (--normalize-text unifies them)")
+	}
+
+	name := strings.ToLower(filepath.Base(path))
+	pattern := _settingsLinePatterns[name]
+	var bad []lintProblem
+	inMacro := false
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		switch {
+		case pattern != nil && !pattern.MatchString(line):
+			bad = append(bad, lintProblem{path, i + 1, fmt.Sprintf("unexpected line %q", line)})
+		case name == copyplan.MacrosFileName && strings.HasPrefix(line, "MACRO "):
+			if inMacro {
+				bad = append(bad, lintProblem{path, i + 1, "a macro starts before the previous one's END"})
+			}
+			if !_macroHeaderPattern.MatchString(line) {
+				bad = append(bad, lintProblem{path, i + 1, fmt.Sprintf("malformed macro header %q", line)})
+			}
+			inMacro = true
+		case name == copyplan.MacrosFileName && line == "END":
+			if !inMacro {
+				bad = append(bad, lintProblem{path, i + 1, "END without a macro"})
+			}
+			inMacro = false
+		case name == copyplan.MacrosFileName && !inMacro:
+			bad = append(bad, lintProblem{path, i + 1, fmt.Sprintf("unexpected line %q outside a macro", line)})
+		}
+	}
+	if inMacro {
+		fileProblem("the last macro has no END, the file was probably cut short")
+	}
+
+	if len(bad) > maxLintLines {
+		problems = append(problems, bad[:maxLintLines]...)
+		fileProblem(fmt.Sprintf("and %d more unexpected lines", len(bad)-maxLintLines))
+	} else {
+		problems = append(problems, bad...)
+	}
+	return problems
+}
+
+// lints path in store, a file lint doesn't know how to check has no problems
+func lintFile(store wtf.Store, path string) ([]lintProblem, error) {
+	if !lintable(path) {
+		return nil, nil
+	}
+	file, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return lintSettings(path, data), nil
+}
+
+// lints the settings files target's account and character folders have
+func (wow WowInstall) lintTarget(target wtf.Target) ([]lintProblem, error) {
+	var problems []lintProblem
+	for _, dir := range []string{wow.AccountPath(target), wow.CharacterPath(target)} {
+		entries, err := wow.Files().ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			found, err := lintFile(wow.Files(), filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, found...)
+		}
+	}
+	return problems, nil
+}
+
+// lints the settings files plan copied, warning under operation about anything that would get them reset
+func lintCopiedFiles(plan copyplan.Plan, operation string) {
+	for _, step := range plan.Steps {
+		problems, err := lintFile(wtf.LocalStore{}, step.Dst)
+		if err != nil {
+			continue
+		}
+		for _, problem := range problems {
+			_runLog.printf(pterm.Warning, operation, "The game may reset %s to defaults on login: %s", filepath.Base(step.Dst), problem)
+		}
+	}
+}
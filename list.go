@@ -0,0 +1,12 @@
+package main
+
+// every character of every version, as rows of a table
+func (wow WowInstall) listCharacters() [][]string {
+	table := [][]string{{"Version", "Account", "Server", "Character"}}
+	for _, version := range wow.Versions {
+		for _, character := range wow.getWtfConfigurations(version) {
+			table = append(table, []string{_wowInstanceFolderNames[version], character.Account, character.Server, character.Name})
+		}
+	}
+	return table
+}
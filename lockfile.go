@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockFileName = ".wow-profile-copy.lock"
+
+// installLock guards a WoW install against concurrent syncs writing into the same WTF tree
+type installLock struct {
+	path string
+}
+
+// creates the lockfile for an install, failing if another live instance already holds it
+// locks left behind by a crashed run (their pid no longer exists) are taken over
+func acquireInstallLock(installDirectory string) (*installLock, error) {
+	lockPath := filepath.Join(installDirectory, lockFileName)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+			lockFile.Close()
+			return &installLock{path: lockPath}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		pid, startedAt := readLockFile(lockPath)
+		if pid > 0 && processExists(pid) {
+			return nil, fmt.Errorf("another wow-profile-copy (pid %d, started %s) is already working on %s. If that's wrong, delete %s", pid, startedAt, installDirectory, lockPath)
+		}
+
+		// stale lock, moved aside under a name of our own before it's removed, so of two runs taking it over at
+		// once only one gets it, and neither removes the lock the other has just created
+		stale := fmt.Sprintf("%s.%d", lockPath, os.Getpid())
+		if err := os.Rename(lockPath, stale); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if moved, movedAt := readLockFile(stale); moved != pid {
+			// another run took it over between our read and the move, its lock goes back where it was
+			os.Link(stale, lockPath)
+			os.Remove(stale)
+			return nil, fmt.Errorf("another wow-profile-copy (pid %d, started %s) is already working on %s. If that's wrong, delete %s", moved, movedAt, installDirectory, lockPath)
+		}
+		if err := os.Remove(stale); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("could not acquire %s", lockPath)
+}
+
+// removes the lockfile
+func (l *installLock) release() error {
+	return os.Remove(l.path)
+}
+
+// parses the pid and start time written by acquireInstallLock
+func readLockFile(path string) (pid int, startedAt string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	pid, _ = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if len(lines) > 1 {
+		startedAt = strings.TrimSpace(lines[1])
+	}
+	return pid, startedAt
+}
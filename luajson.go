@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wow-profile-copy/internal/savedvars"
+)
+
+// converts a .lua SavedVariables file to .json or back, returning where the result goes when out is empty:
+// next to path, with the other extension
+func convertSavedVariables(path string, out string) (string, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	var converted []byte
+	switch ext {
+	case ".lua":
+		converted, err = savedvars.ToJSON(data)
+		ext = ".json"
+	case ".json":
+		converted, err = savedvars.FromJSON(data)
+		ext = ".lua"
+	default:
+		return "", nil, fmt.Errorf("%s isn't a .lua or .json file", path)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	}
+	return out, converted, nil
+}
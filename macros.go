@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// checks a --macros value
+func validateMacrosScope(scope string) error {
+	switch scope {
+	case "", copyplan.MacrosBoth, copyplan.MacrosAccount, copyplan.MacrosCharacter:
+		return nil
+	}
+	return fmt.Errorf("--macros must be %s, %s, or %s, not %q", copyplan.MacrosBoth, copyplan.MacrosAccount, copyplan.MacrosCharacter, scope)
+}
+
+// asks which macros to copy when the source uses both general and character-specific macros and at least one
+// destination is on another account, where copying the general ones replaces that account's for every character
+// returns "" (both) when there's nothing to choose
+func promptForMacroScope(srcWow WowInstall, src wtf.Target, dsts []wtf.Target) string {
+	otherAccount := false
+	for _, dst := range dsts {
+		otherAccount = otherAccount || dst.Account != src.Account || dst.Version != src.Version
+	}
+	if !otherAccount {
+		return ""
+	}
+	general := copyplan.CountMacros(srcWow.Files(), filepath.Join(srcWow.AccountPath(src), copyplan.MacrosFileName))
+	specific := copyplan.CountMacros(srcWow.Files(), filepath.Join(srcWow.CharacterPath(src), copyplan.MacrosFileName))
+	if general == 0 || specific == 0 {
+		return ""
+	}
+
+	const bothOption = "Copy both"
+	const characterOption = "Only its own macros, keep the destination account's general macros"
+	const accountOption = "Only the general macros"
+	pterm.Info.Printfln("%s has %d general (account-wide) macros and %d macros of its own", src.CharacterName(), general, specific)
+	switch askSelect("macros", pterm.DefaultInteractiveSelect.
+		WithOptions([]string{bothOption, characterOption, accountOption}).
+		WithDefaultText("Which macros should be copied?")) {
+	case characterOption:
+		return copyplan.MacrosCharacter
+	case accountOption:
+		return copyplan.MacrosAccount
+	}
+	return copyplan.MacrosBoth
+}
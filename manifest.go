@@ -0,0 +1,461 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/planfile"
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// bumped whenever syncManifest changes in a way older manifests can't be read as
+const supportedManifestVersion = 1
+
+// what a successful sync copied, so status can tell what changed in the source since
+type syncManifest struct {
+	Version                int                `json:"version"`
+	SyncedAt               time.Time          `json:"synced_at"`
+	SourceInstallDirectory string             `json:"source_install_directory"`
+	InstallDirectory       string             `json:"install_directory"`
+	Source                 planfile.Target    `json:"source"`
+	Destination            planfile.Target    `json:"destination"`
+	Files                  []syncManifestFile `json:"files"`
+	Skipped                []string           `json:"skipped"`
+
+	// set once the game has been seen touching the destination, so the outcome is only reported once
+	LoginChecked bool `json:"login_checked,omitempty"`
+}
+
+type syncManifestFile struct {
+	Category string `json:"category"`
+	Src      string `json:"src"`
+	SHA256   string `json:"sha256"`
+
+	// the destination as it was written, and before the copy (empty if it didn't exist)
+	// older manifests have neither, and are left out of loginOutcome
+	Dst      string `json:"dst,omitempty"`
+	Written  string `json:"written_sha256,omitempty"`
+	Previous string `json:"previous_sha256,omitempty"`
+}
+
+func manifestsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "manifests"), nil
+}
+
+// one manifest per source/destination pair, named after a hash of the pair
+func manifestPath(srcInstall string, src wtf.Target, dstInstall string, dst wtf.Target) (string, error) {
+	dir, err := manifestsDir()
+	if err != nil {
+		return "", err
+	}
+	key := strings.Join([]string{srcInstall, src.Version, src.Account, src.Server, src.Name, dstInstall, dst.Version, dst.Account, dst.Server, dst.Name}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// hashes of the destination files plan is about to overwrite, for loginOutcome to compare against later
+// files that don't exist yet are left out
+func previousDestinationHashes(plan copyplan.Plan) map[string]string {
+	hashes := make(map[string]string)
+	for _, step := range plan.AllSteps() {
+		if hash, err := copyplan.HashFile(wtf.LocalStore{}, step.Dst); err == nil {
+			hashes[step.Dst] = hex.EncodeToString(hash)
+		}
+	}
+	return hashes
+}
+
+// records what plan copied into wow, replacing the pair's previous manifest
+// previous holds the destination hashes from before the copy, see previousDestinationHashes
+// only sources on the local disk can be checked again later, imported shares are gone once copied
+func writeSyncManifest(wow WowInstall, plan copyplan.Plan, previous map[string]string) error {
+	if _, ok := plan.SourceFiles().(wtf.LocalStore); !ok {
+		return nil
+	}
+	manifest := syncManifest{
+		Version:                supportedManifestVersion,
+		SyncedAt:               time.Now(),
+		SourceInstallDirectory: plan.SourceInstallDirectory,
+		InstallDirectory:       wow.Dir,
+		Source:                 newPlanDocumentTarget(plan.Source),
+		Destination:            newPlanDocumentTarget(plan.Destination),
+	}
+	for _, step := range plan.Steps {
+		hash, err := copyplan.HashFile(plan.SourceFiles(), step.Src)
+		if err != nil {
+			return err
+		}
+		written, err := copyplan.HashFile(wtf.LocalStore{}, step.Dst)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, syncManifestFile{
+			Category: step.Category,
+			Src:      step.Src,
+			SHA256:   hex.EncodeToString(hash),
+			Dst:      step.Dst,
+			Written:  hex.EncodeToString(written),
+			Previous: previous[step.Dst],
+		})
+	}
+	// an extraction with nothing of the source's to merge leaves a missing destination missing
+	for _, step := range plan.Extractions {
+		hash, err := copyplan.HashFile(plan.SourceFiles(), step.Src)
+		if err != nil {
+			return err
+		}
+		file := syncManifestFile{Category: step.Category, Src: step.Src, SHA256: hex.EncodeToString(hash), Dst: step.Dst, Previous: previous[step.Dst]}
+		if written, err := copyplan.HashFile(wtf.LocalStore{}, step.Dst); err == nil {
+			file.Written = hex.EncodeToString(written)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+	for _, skipped := range plan.Skipped {
+		manifest.Skipped = append(manifest.Skipped, skipped.Path)
+	}
+	return saveSyncManifest(manifest)
+}
+
+// writes manifest over its pair's previous one
+func saveSyncManifest(manifest syncManifest) error {
+	path, err := manifestPath(manifest.SourceInstallDirectory, planDocumentCopyTarget(manifest.Source), manifest.InstallDirectory, planDocumentCopyTarget(manifest.Destination))
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// every stored manifest, most recently synced first
+func readSyncManifests() ([]syncManifest, error) {
+	dir, err := manifestsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []syncManifest
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var manifest syncManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", entry.Name(), err)
+		}
+		if manifest.Version != supportedManifestVersion {
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].SyncedAt.After(manifests[j].SyncedAt)
+	})
+	return manifests, nil
+}
+
+// the categories whose source files changed, appeared, or disappeared since the manifest was written
+func (m syncManifest) staleCategories() ([]string, error) {
+	srcWow := WowInstall{Install: wowinstall.Install{Dir: m.SourceInstallDirectory}}
+	dstWow := WowInstall{Install: wowinstall.Install{Dir: m.InstallDirectory}}
+	src, dst := planDocumentCopyTarget(m.Source), planDocumentCopyTarget(m.Destination)
+
+	// files that were left out last time stay left out, anything else new in the source is a change
+	skipped := make(map[string]bool)
+	skippedNames := make(map[string]bool)
+	for _, path := range m.Skipped {
+		skipped[path] = true
+		skippedNames[filepath.Base(path)] = true
+	}
+
+	stale := make(map[string]bool)
+	plan, err := copyplan.Build(srcWow.Install, dstWow.Install, src, dst, currentDefaults(), copyplan.Options{SkippedAccountSavedVariables: skippedNames})
+	if err != nil {
+		return nil, err
+	}
+	synced := make(map[string]string)
+	for _, file := range m.Files {
+		synced[file.Src] = file.SHA256
+	}
+	current := make(map[string]bool)
+	for _, step := range plan.AllSteps() {
+		current[step.Src] = true
+		want, ok := synced[step.Src]
+		if !ok {
+			if !skipped[step.Src] {
+				stale[step.Category] = true
+			}
+			continue
+		}
+		hash, err := copyplan.HashFile(wtf.LocalStore{}, step.Src)
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(hash) != want {
+			stale[step.Category] = true
+		}
+	}
+	for _, file := range m.Files {
+		if !current[file.Src] {
+			stale[file.Category] = true
+		}
+	}
+
+	var categories []string
+	for _, category := range copyplan.Categories {
+		if stale[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+// a table of every synced pair, when it was last synced, and which categories are stale since
+func syncStatusTable() ([][]string, error) {
+	manifests, err := readSyncManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	table := [][]string{{"Source", "Destination", "Last synced", "Status"}}
+	for _, manifest := range manifests {
+		status := "up to date"
+		stale, err := manifest.staleCategories()
+		if err != nil {
+			status = fmt.Sprintf("can't check: %s", explainFileError(err))
+		} else if len(stale) > 0 {
+			status = "changed: " + strings.Join(stale, ", ")
+		}
+		table = append(table, []string{manifest.Source.String(), manifest.Destination.String(), manifest.SyncedAt.Format("2006-01-02 15:04"), status})
+	}
+	return table, nil
+}
+
+// what the game did to a sync's destination files since they were written
+type loginOutcome struct {
+	untouched []string // still exactly as copied, the character hasn't logged out since
+	rewritten []string // saved again by the game, as expected after logging in
+	reverted  []string // back to what they were before the copy, the game was running during it
+	missing   []string // deleted since
+}
+
+// whether the game has touched the destination at all since the sync
+func (o loginOutcome) touched() bool {
+	return len(o.rewritten) > 0 || len(o.reverted) > 0
+}
+
+// compares every destination file to what the sync wrote and what was there before it
+func (m syncManifest) loginOutcome() (loginOutcome, error) {
+	var outcome loginOutcome
+	for _, file := range m.Files {
+		if file.Written == "" {
+			continue
+		}
+		hash, err := copyplan.HashFile(wtf.LocalStore{}, file.Dst)
+		if errors.Is(err, os.ErrNotExist) {
+			outcome.missing = append(outcome.missing, file.Dst)
+			continue
+		}
+		if err != nil {
+			return outcome, err
+		}
+		switch current := hex.EncodeToString(hash); {
+		case current == file.Written:
+			outcome.untouched = append(outcome.untouched, file.Dst)
+		case current == file.Previous:
+			outcome.reverted = append(outcome.reverted, file.Dst)
+		default:
+			outcome.rewritten = append(outcome.rewritten, file.Dst)
+		}
+	}
+	return outcome, nil
+}
+
+// how far back warnAboutRevertedSyncs looks, a game left running during a copy writes its old settings back at
+// the next logout, long before this
+const revertedSyncMaxAge = 14 * 24 * time.Hour
+
+// warns about syncs the game undid since the last run, by writing back the settings it had loaded before the copy
+// each sync is only reported once, the first time the game is seen to have touched it, and only recent ones are
+// looked at, check covers the rest
+func warnAboutRevertedSyncs() error {
+	manifests, err := readSyncManifests()
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		if manifest.LoginChecked || time.Since(manifest.SyncedAt) > revertedSyncMaxAge {
+			continue
+		}
+		outcome, err := manifest.loginOutcome()
+		if err != nil {
+			return err
+		}
+		if !outcome.touched() {
+			continue
+		}
+		if len(outcome.reverted) > 0 {
+			pterm.Warning.Printfln("The game put back the old settings of %s after your last copy to it (%d files), it was probably running during the copy. Close it completely and copy again:\n%s", manifest.Destination, len(outcome.reverted), strings.Join(outcome.reverted, "\n"))
+		}
+		manifest.LoginChecked = true
+		if err := saveSyncManifest(manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the newest sync, or the newest one to dstSpec (version/account/server/character) when it's set
+func latestSyncManifest(dstSpec string) (syncManifest, bool, error) {
+	manifests, err := readSyncManifests()
+	if err != nil {
+		return syncManifest{}, false, err
+	}
+	for _, manifest := range manifests {
+		if dstSpec == "" {
+			return manifest, true, nil
+		}
+		parts := strings.Split(dstSpec, "/")
+		if len(parts) == 4 && (planfile.Target{Version: resolveVersionName(parts[0]), Account: parts[1], Server: parts[2], Character: parts[3]}) == manifest.Destination {
+			return manifest, true, nil
+		}
+	}
+	return syncManifest{}, false, nil
+}
+
+// reports whether the last sync to a character survived its first login, for `check`
+// returns false when the game undid it
+func checkSync(manifest syncManifest) (bool, error) {
+	outcome, err := manifest.loginOutcome()
+	if err != nil {
+		return false, err
+	}
+	destination := manifest.Destination.String()
+	pterm.Info.Printfln("Checking the copy from %s to %s on %s", manifest.Source, destination, manifest.SyncedAt.Format("2006-01-02 15:04"))
+	for _, path := range outcome.missing {
+		pterm.Warning.Printfln("%s was deleted since the copy", path)
+	}
+
+	switch {
+	case len(outcome.reverted) > 0:
+		pterm.Error.Printfln("The copy didn't take, the game put back the old version of %d files:\n%s", len(outcome.reverted), strings.Join(outcome.reverted, "\n"))
+		pterm.Info.Println("The game was probably open during the copy. Close it completely (including the character select screen) and copy again")
+	case len(outcome.rewritten) > 0:
+		pterm.Success.Printfln("The copy took, the game has loaded and saved %d of the copied files since", len(outcome.rewritten))
+		if len(outcome.untouched) > 0 {
+			pterm.Info.Printfln("%d files are still exactly as copied, usually settings of addons that aren't enabled on %s", len(outcome.untouched), destination)
+		}
+	default:
+		pterm.Info.Printfln("The game hasn't saved anything for %s since the copy. Log into it, then log out (or /reload), and run check again", destination)
+		return true, nil
+	}
+
+	if !manifest.LoginChecked {
+		manifest.LoginChecked = true
+		if err := saveSyncManifest(manifest); err != nil {
+			return false, err
+		}
+	}
+	return len(outcome.reverted) == 0, nil
+}
+
+// whether the pair's last sync copied exactly the files plan would copy, from sources that haven't changed since,
+// and the files it wrote are all still there, so copying again would only redo the same work
+// the destination's own changes (the game saving after a login) don't count, the source is what's synced
+func alreadySynced(p copyplan.Plan, wow WowInstall) (bool, error) {
+	if _, ok := p.SourceFiles().(wtf.LocalStore); !ok {
+		return false, nil
+	}
+	path, err := manifestPath(p.SourceInstallDirectory, p.Source, wow.Dir, p.Destination)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var manifest syncManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Version != supportedManifestVersion {
+		return false, nil
+	}
+
+	synced := make(map[string]syncManifestFile)
+	for _, file := range manifest.Files {
+		synced[file.Src+"\x00"+file.Dst] = file
+	}
+	steps := p.AllSteps()
+	if len(synced) != len(steps) {
+		return false, nil
+	}
+	for _, step := range steps {
+		file, ok := synced[step.Src+"\x00"+step.Dst]
+		if !ok {
+			return false, nil
+		}
+		if _, err := os.Stat(step.Dst); err != nil && file.Written != "" {
+			return false, nil
+		}
+		hash, err := copyplan.HashFile(wtf.LocalStore{}, step.Src)
+		if err != nil {
+			return false, err
+		}
+		if hex.EncodeToString(hash) != file.SHA256 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// leaves out the plans whose destination is already synced from the same sources, see alreadySynced
+func (wow WowInstall) skipSyncedPlans(plans []copyplan.Plan) ([]copyplan.Plan, error) {
+	var changed []copyplan.Plan
+	for _, plan := range plans {
+		synced, err := alreadySynced(plan, wow)
+		if err != nil {
+			return nil, err
+		}
+		if synced {
+			pterm.Info.Printfln("Skipping %s, nothing in %s's files changed since the last copy to it", plan.Destination.CharacterName(), plan.Source.CharacterName())
+			continue
+		}
+		changed = append(changed, plan)
+	}
+	return changed, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// raises a desktop notification, for users who alt-tab away during big copies
+// uses whatever the OS ships with: a powershell toast on windows, osascript on macOS, notify-send elsewhere
+func notify(title string, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript(title, message))
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title)))
+	default:
+		cmd = exec.Command("notify-send", "--app-name=wow-profile-copy", title, message)
+	}
+	return cmd.Run()
+}
+
+// builds a toast through the WinRT notification API, which powershell can reach without extra modules
+func windowsToastScript(title string, message string) string {
+	return fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName("text")
+$text.Item(0).AppendChild($template.CreateTextNode(%s)) | Out-Null
+$text.Item(1).AppendChild($template.CreateTextNode(%s)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier("wow-profile-copy").Show($toast)`, powershellQuote(title), powershellQuote(message))
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
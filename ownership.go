@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// gives everything under root the owner of reference, so files an admin copies into another OS user's
+// install still belong to that user; files that already match are left alone
+func matchOwnership(root string, reference string) error {
+	// a relocated WTF folder is a link, the owner that matters is the folder it points to
+	if resolved, err := filepath.EvalSymlinks(reference); err == nil {
+		reference = resolved
+	}
+	owner, err := fileOwner(reference)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		current, err := fileOwner(path)
+		if err != nil {
+			return err
+		}
+		if current.equal(owner) {
+			return nil
+		}
+		return setFileOwner(path, owner)
+	})
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+type fileOwnerInfo struct {
+	uid uint32
+	gid uint32
+}
+
+func (o fileOwnerInfo) equal(other fileOwnerInfo) bool {
+	return o == other
+}
+
+func fileOwner(path string) (fileOwnerInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fileOwnerInfo{}, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileOwnerInfo{}, fmt.Errorf("can't read the owner of %s", path)
+	}
+	return fileOwnerInfo{stat.Uid, stat.Gid}, nil
+}
+
+// only root can give files away, everyone else gets a permission error
+func setFileOwner(path string, owner fileOwnerInfo) error {
+	return os.Lchown(path, int(owner.uid), int(owner.gid))
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+type fileOwnerInfo struct {
+	sid *windows.SID
+}
+
+func (o fileOwnerInfo) equal(other fileOwnerInfo) bool {
+	return o.sid.Equals(other.sid)
+}
+
+func fileOwner(path string) (fileOwnerInfo, error) {
+	descriptor, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fileOwnerInfo{}, err
+	}
+	sid, _, err := descriptor.Owner()
+	if err != nil {
+		return fileOwnerInfo{}, err
+	}
+	return fileOwnerInfo{sid}, nil
+}
+
+// needs an elevated prompt (SeRestorePrivilege) to give files to another user
+func setFileOwner(path string, owner fileOwnerInfo) error {
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION, owner.sid, nil, nil, nil)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// windows refuses these in file and folder names
+const windowsIllegalNameCharacters = `<>:"/\|?*`
+
+// names windows reserves for devices, with or without an extension
+var _windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// the longest path the game and windows' classic file APIs can open
+const windowsMaxPath = 259
+
+// what's wrong with name as a folder name on windows (where the game, or wine, has to open it), if anything
+func folderNameProblem(name string) string {
+	if strings.TrimRight(name, " .") != name {
+		return fmt.Sprintf("%q ends in a space or dot, which windows silently drops", name)
+	}
+	if strings.TrimLeft(name, " ") != name {
+		return fmt.Sprintf("%q starts with a space", name)
+	}
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(windowsIllegalNameCharacters, r) {
+			return fmt.Sprintf("%q contains %q, which windows doesn't allow in folder names", name, r)
+		}
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if _windowsReservedNames[strings.ToUpper(base)] {
+		return fmt.Sprintf("%q is a device name windows reserves", name)
+	}
+	return ""
+}
+
+// an existing entry of dir that differs from name only by case, which windows treats as the same folder
+func caseCollision(dir string, name string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.Name() != name && strings.EqualFold(entry.Name(), name) {
+			return entry.Name()
+		}
+	}
+	return ""
+}
+
+// problems with the realm and character folders that copying to target would create, none if they already exist
+func (wow WowInstall) newCharacterFolderProblems(target wtf.Target) []string {
+	if _, err := os.Stat(wow.CharacterPath(target)); err == nil {
+		return nil
+	}
+
+	var problems []string
+	for _, name := range []string{target.Server, target.Name} {
+		if problem := folderNameProblem(name); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	accountPath := wow.AccountPath(target)
+	if existing := caseCollision(accountPath, target.Server); existing != "" {
+		problems = append(problems, fmt.Sprintf("the realm folder %q already exists as %q, the game would mix the two up", target.Server, existing))
+	}
+	if existing := caseCollision(filepath.Join(accountPath, target.Server), target.Name); existing != "" {
+		problems = append(problems, fmt.Sprintf("the character folder %q already exists as %q, the game would mix the two up", target.Name, existing))
+	}
+	return problems
+}
+
+// destination paths in plan too long for windows to open
+func longDestinationPaths(p copyplan.Plan) []string {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	var paths []string
+	for _, step := range p.Steps {
+		if len(step.Dst) > windowsMaxPath {
+			paths = append(paths, step.Dst)
+		}
+	}
+	return paths
+}
+
+// whether path is dir or inside it, going by the names alone
+// links aren't followed, WTF itself may be one (see relocate) and everything under it still counts as inside
+func isWithinDirectory(dir string, path string) bool {
+	relative, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) && !filepath.IsAbs(relative)
+}
+
+// what would make plan write outside the destination's account folder, if anything: a version, account, realm,
+// or character that isn't a plain folder name, or a step or cache file somewhere else
+// plans can come from hand edited files, config aliases, and shares made by someone else, none of which should
+// ever be able to touch the game's own files
+func (wow WowInstall) escapingPaths(plan copyplan.Plan) []string {
+	var paths []string
+	for _, step := range plan.AllSteps() {
+		paths = append(paths, step.Dst)
+	}
+	return wow.escapingTargetPaths(plan.Destination, append(paths, plan.CacheInvalidations...))
+}
+
+// what would make writing paths for target leave its account folder, like escapingPaths
+// a target without a realm and character is the account itself, e.g. a backup of account files only
+func (wow WowInstall) escapingTargetPaths(target wtf.Target, paths []string) []string {
+	var problems []string
+	names := []string{target.Version, target.Account, target.Server, target.Name}
+	if target.Server == "" && target.Name == "" {
+		names = names[:2]
+	}
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.IsAbs(name) {
+			problems = append(problems, fmt.Sprintf("%q isn't a folder name", name))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	accountPath := wow.AccountPath(target)
+	for _, path := range paths {
+		if !isWithinDirectory(accountPath, path) {
+			problems = append(problems, fmt.Sprintf("%s is outside %s", path, accountPath))
+		}
+	}
+	return problems
+}
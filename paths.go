@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// set by --data-dir, keeps config, data, and caches all under one directory instead of the per-OS locations
+var _dataDirOverride string
+
+const appDirectoryName = "wow-profile-copy"
+
+// where config.yaml and defaults.json live
+// %AppData% on windows, ~/Library/Application Support on macOS, $XDG_CONFIG_HOME (~/.config) elsewhere
+func configDir() (string, error) {
+	if _dataDirOverride != "" {
+		return _dataDirOverride, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirectoryName), nil
+}
+
+// where backups and logs live, they can get big so they stay out of roaming profiles and dotfile repos
+// %LocalAppData% on windows, ~/Library/Application Support on macOS, $XDG_DATA_HOME (~/.local/share) elsewhere
+func dataDir() (string, error) {
+	if _dataDirOverride != "" {
+		return _dataDirOverride, nil
+	}
+
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+	case "darwin", "ios":
+		var err error
+		dir, err = os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+	default:
+		// the spec says relative paths are invalid and should be ignored
+		dir = os.Getenv("XDG_DATA_HOME")
+		if !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	return filepath.Join(dir, appDirectoryName), nil
+}
+
+// where downloaded files that can be fetched again live
+// %LocalAppData% on windows, ~/Library/Caches on macOS, $XDG_CACHE_HOME (~/.cache) elsewhere
+func cacheDir() (string, error) {
+	if _dataDirOverride != "" {
+		return filepath.Join(_dataDirOverride, "cache"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirectoryName), nil
+}
+
+// the folder each run's backups are made in
+func backupsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// dropping a file with this name next to the executable turns portable mode on without passing --portable
+const portableSentinelName = "wow-profile-copy.portable"
+
+// the data directory used in portable mode, beside the executable so it travels with it on a USB stick
+// returns "" when portable mode is neither requested nor switched on by the sentinel file
+func portableDataDir(requested bool) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(executable)
+
+	if !requested {
+		if _, err := os.Stat(filepath.Join(dir, portableSentinelName)); err != nil {
+			return "", nil
+		}
+	}
+	return filepath.Join(dir, "wow-profile-copy-data"), nil
+}
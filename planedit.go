@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/planfile"
+)
+
+// why apply skips the steps turned off in the plan editor
+const disabledStepReason = "it was turned off in the plan editor"
+
+// a step in the plan editor, turned off steps stay in the list so they can be turned back on
+type editedStep struct {
+	step planfile.Step
+	on   bool
+}
+
+func (s editedStep) String() string {
+	state := "on "
+	if !s.on {
+		state = "off"
+	}
+	return fmt.Sprintf("[%s] %s: %s", state, s.step.Category, s.step.Dst)
+}
+
+func (s editedStep) content() copyplan.Content {
+	return copyplan.StepContent(copyplan.Step{Category: s.step.Category, Src: s.step.Src, Dst: s.step.Dst, Size: s.step.Size})
+}
+
+// lets the user go through doc's steps: reordering them, turning them off and on one by one or by what they
+// copy, then saving doc back to path, returns the edited doc and whether it should be applied right away
+func editPlanDocument(doc planfile.Document, path string) (planfile.Document, bool) {
+	const reviewOption = "Review the steps"
+	const toggleOption = "Turn steps on or off"
+	const contentsOption = "Pick what to copy"
+	const moveOption = "Move a step"
+	const saveOption = "Save"
+	const applyOption = "Save and apply"
+	const quitOption = "Quit"
+
+	var steps []editedStep
+	for _, step := range doc.Steps {
+		steps = append(steps, editedStep{step, true})
+	}
+	for _, step := range doc.DisabledSteps {
+		steps = append(steps, editedStep{step, false})
+	}
+
+	changed := false
+	for {
+		choice := askSelect("plan-editor", pterm.DefaultInteractiveSelect.
+			WithOptions([]string{reviewOption, toggleOption, contentsOption, moveOption, saveOption, applyOption, quitOption}).
+			WithDefaultText(fmt.Sprintf("%s to %s, %d steps", doc.Source.Character, doc.Destination.Character, len(steps))))
+		switch choice {
+		case reviewOption:
+			printEditedSteps(steps)
+		case toggleOption:
+			changed = toggleEditedSteps(steps) || changed
+		case contentsOption:
+			changed = pickEditedContents(steps) || changed
+		case moveOption:
+			if moved, ok := moveEditedStep(steps); ok {
+				steps, changed = moved, true
+			}
+		case saveOption, applyOption:
+			doc.Steps, doc.DisabledSteps = nil, nil
+			for _, step := range steps {
+				if step.on {
+					doc.Steps = append(doc.Steps, step.step)
+				} else {
+					doc.DisabledSteps = append(doc.DisabledSteps, step.step)
+				}
+			}
+			if err := planfile.Write(path, doc); err != nil {
+				fatal(explainFileError(err))
+			}
+			pterm.Success.Printfln("Saved the plan (%d steps on, %d off) to %s", len(doc.Steps), len(doc.DisabledSteps), path)
+			changed = false
+			if choice == applyOption {
+				return doc, true
+			}
+		case quitOption:
+			if !changed || askConfirm("plan-editor.discard", pterm.DefaultInteractiveConfirm.
+				WithDefaultText("Quit without saving the changes?")) {
+				return doc, false
+			}
+		}
+	}
+}
+
+func printEditedSteps(steps []editedStep) {
+	table := [][]string{{"#", "", "Category", "Source", "Destination"}}
+	for i, step := range steps {
+		state := "on"
+		if !step.on {
+			state = "off"
+		}
+		table = append(table, []string{fmt.Sprint(i + 1), state, step.step.Category, filepath.Base(step.step.Src), step.step.Dst})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+}
+
+// asks which steps are on, returning whether that changed anything
+func toggleEditedSteps(steps []editedStep) bool {
+	var options, on []string
+	for i, step := range steps {
+		option := fmt.Sprintf("%d. %s", i+1, step.step.Dst)
+		options = append(options, option)
+		if step.on {
+			on = append(on, option)
+		}
+	}
+	picked := make(map[string]bool)
+	for _, option := range askMultiselect("plan-editor.steps", pterm.DefaultInteractiveMultiselect.
+		WithOptions(options).
+		WithDefaultOptions(on).
+		WithDefaultText("Steps to run (unselected ones are turned off)").
+		WithMaxHeight(selectMaxHeight())) {
+		picked[option] = true
+	}
+
+	changed := false
+	for i := range steps {
+		if picked[options[i]] != steps[i].on {
+			steps[i].on = picked[options[i]]
+			changed = true
+		}
+	}
+	return changed
+}
+
+// asks which of the contents the steps copy are copied, turning every step of the others off and of the picked
+// ones on, returning whether that changed anything
+func pickEditedContents(steps []editedStep) bool {
+	has, on := make(map[string]bool), make(map[string]bool)
+	for _, step := range steps {
+		has[step.content().Name] = true
+		on[step.content().Name] = on[step.content().Name] || step.on
+	}
+	var options, selected []string
+	for _, content := range copyplan.Contents {
+		if !has[content.Name] {
+			continue
+		}
+		options = append(options, content.Label)
+		if on[content.Name] {
+			selected = append(selected, content.Label)
+		}
+	}
+	picked := make(map[string]bool)
+	for _, label := range askMultiselect("plan-editor.contents", pterm.DefaultInteractiveMultiselect.
+		WithOptions(options).
+		WithDefaultOptions(selected).
+		WithDefaultText("What should be copied?").
+		WithMaxHeight(selectMaxHeight())) {
+		picked[label] = true
+	}
+
+	changed := false
+	for i := range steps {
+		if want := picked[steps[i].content().Label]; want != steps[i].on {
+			steps[i].on = want
+			changed = true
+		}
+	}
+	return changed
+}
+
+// asks for a step and where it goes, returning the reordered steps and whether one was moved
+func moveEditedStep(steps []editedStep) ([]editedStep, bool) {
+	const upOption = "Up one"
+	const downOption = "Down one"
+	const topOption = "To the top"
+	const bottomOption = "To the bottom"
+
+	if len(steps) < 2 {
+		pterm.Info.Println("There's nothing to reorder")
+		return steps, false
+	}
+	var options []string
+	for i, step := range steps {
+		options = append(options, fmt.Sprintf("%d. %s", i+1, step))
+	}
+	picked := askSelect("plan-editor.move", pterm.DefaultInteractiveSelect.
+		WithOptions(options).
+		WithDefaultText("Step to move").
+		WithMaxHeight(selectMaxHeight()))
+	from := 0
+	for i, option := range options {
+		if option == picked {
+			from = i
+		}
+	}
+
+	to := from
+	switch askSelect("plan-editor.move-to", pterm.DefaultInteractiveSelect.
+		WithOptions([]string{upOption, downOption, topOption, bottomOption}).
+		WithDefaultText("Move it")) {
+	case upOption:
+		if to > 0 {
+			to--
+		}
+	case downOption:
+		if to < len(steps)-1 {
+			to++
+		}
+	case topOption:
+		to = 0
+	case bottomOption:
+		to = len(steps) - 1
+	}
+	if to == from {
+		return steps, false
+	}
+
+	step := steps[from]
+	moved := append(append([]editedStep{}, steps[:from]...), steps[from+1:]...)
+	moved = append(moved[:to], append([]editedStep{step}, moved[to:]...)...)
+	return moved, true
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/planfile"
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+func newPlanDocumentTarget(target wtf.Target) planfile.Target {
+	return planfile.Target{Version: target.Version, Account: target.Account, Server: target.Server, Character: target.Name}
+}
+
+func planDocumentCopyTarget(t planfile.Target) wtf.Target {
+	return wtf.Target{Character: wtf.Character{Account: t.Account, Server: t.Server, Name: t.Character}, Version: t.Version}
+}
+
+// the on-disk form of plan, see planfile.Document
+func newPlanDocument(wow WowInstall, plan copyplan.Plan, opts copyplan.CopyOptions) planfile.Document {
+	doc := planfile.Document{
+		Version:            planfile.SupportedVersion,
+		InstallDirectory:   wow.Dir,
+		SourceInstall:      plan.SourceInstallDirectory,
+		Source:             newPlanDocumentTarget(plan.Source),
+		Destination:        newPlanDocumentTarget(plan.Destination),
+		Options:            planfile.Options{Backup: opts.Backup, Verify: opts.Verify, NormalizeText: opts.NormalizeText},
+		CacheInvalidations: plan.CacheInvalidations,
+		CVarAllowlist:      plan.CVarAllowlist,
+		MacroSlots:         plan.MacroSlots,
+	}
+	for _, step := range plan.Steps {
+		doc.Steps = append(doc.Steps, planfile.Step{Category: step.Category, Src: step.Src, Dst: step.Dst, Size: step.Size})
+	}
+	for _, step := range plan.Extractions {
+		doc.Extractions = append(doc.Extractions, planfile.Step{Category: step.Category, Src: step.Src, Dst: step.Dst, Size: step.Size})
+	}
+	for _, skipped := range plan.Skipped {
+		doc.Skipped = append(doc.Skipped, planfile.Skip{Path: skipped.Path, Reason: skipped.Reason})
+	}
+	for _, rule := range plan.Rewrites {
+		doc.Rewrites = append(doc.Rewrites, planfile.Rule{From: rule.From, To: rule.To, Regex: rule.Regex()})
+	}
+	return doc
+}
+
+// turns a loaded document back into what `apply` needs, checking it still matches what's on disk
+// sources whose size changed since planning are only a warning, the plan copies whatever is there now
+func resolvePlanDocument(doc planfile.Document) (WowInstall, copyplan.Plan, copyplan.CopyOptions, error) {
+	var wow WowInstall
+	if doc.Version != planfile.SupportedVersion {
+		return wow, copyplan.Plan{}, copyplan.CopyOptions{}, fmt.Errorf("plan version %d is not supported, expected %d", doc.Version, planfile.SupportedVersion)
+	}
+	if !isWowInstallDirectory(doc.InstallDirectory) {
+		return wow, copyplan.Plan{}, copyplan.CopyOptions{}, fmt.Errorf("%s from the plan doesn't look like a WoW install anymore", doc.InstallDirectory)
+	}
+	if doc.SourceInstall == doc.InstallDirectory && doc.Source == doc.Destination {
+		return wow, copyplan.Plan{}, copyplan.CopyOptions{}, copyplan.ErrSameSourceAndDestination
+	}
+	wow.findAvailableVersions(doc.InstallDirectory)
+
+	plan := copyplan.Plan{
+		SourceInstallDirectory: doc.SourceInstall,
+		Source:                 planDocumentCopyTarget(doc.Source),
+		Destination:            planDocumentCopyTarget(doc.Destination),
+		CacheInvalidations:     doc.CacheInvalidations,
+		CVarAllowlist:          doc.CVarAllowlist,
+		MacroSlots:             doc.MacroSlots,
+	}
+	for _, step := range doc.Steps {
+		info, err := os.Stat(step.Src)
+		if err != nil {
+			return wow, plan, copyplan.CopyOptions{}, err
+		}
+		if info.Size() != step.Size {
+			pterm.Warning.Printfln("%s changed size since the plan was made (%s, now %s)", step.Src, formatBytes(step.Size), formatBytes(info.Size()))
+		}
+		plan.Steps = append(plan.Steps, copyplan.Step{Category: step.Category, Src: step.Src, Dst: step.Dst, Size: info.Size()})
+	}
+	for _, step := range doc.Extractions {
+		info, err := os.Stat(step.Src)
+		if err != nil {
+			return wow, plan, copyplan.CopyOptions{}, err
+		}
+		plan.Extractions = append(plan.Extractions, copyplan.Step{Category: step.Category, Src: step.Src, Dst: step.Dst, Size: info.Size()})
+	}
+	for _, step := range doc.DisabledSteps {
+		plan.Skipped = append(plan.Skipped, copyplan.SkippedFile{Path: step.Src, Reason: disabledStepReason})
+	}
+	for _, skipped := range doc.Skipped {
+		plan.Skipped = append(plan.Skipped, copyplan.SkippedFile{Path: skipped.Path, Reason: skipped.Reason})
+	}
+	for _, rule := range doc.Rewrites {
+		rewrite, err := savedvars.NewRule(rule.From, rule.To, rule.Regex)
+		if err != nil {
+			return wow, plan, copyplan.CopyOptions{}, err
+		}
+		plan.Rewrites = append(plan.Rewrites, rewrite)
+	}
+
+	// risky files were already left out of the steps when the plan was made
+	opts := copyplan.CopyOptions{Backup: doc.Options.Backup, Verify: doc.Options.Verify, NormalizeText: doc.Options.NormalizeText}
+	return wow, plan, opts, nil
+}
+
+// reads a plan written by planfile.Write, checking it against plan.schema.json, "-" reads stdin
+func readPlanDocument(path string) (planfile.Document, error) {
+	var doc planfile.Document
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return doc, err
+	}
+	if err := validateDocument(data, _planSchema); err != nil {
+		return doc, fmt.Errorf("%s is not a valid plan, %w", path, err)
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("%s is not a valid plan: %w", path, explainJSONError(data, err))
+	}
+	return doc, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/internal/planfile"
+)
+
+// replaces every ${NAME} in doc with its value from vars, asking for the ones vars doesn't have when interactive
+func fillPlanVariables(doc planfile.Document, vars planfile.Variables, interactive bool) (planfile.Document, error) {
+	values := make(map[string]string)
+	for _, name := range doc.VariableNames() {
+		value, ok := vars[name]
+		if !ok && !interactive {
+			return doc, fmt.Errorf("the plan needs a value for ${%s}, pass it with --var %s=<value>", name, name)
+		}
+		if !ok {
+			value = strings.TrimSpace(askText("var."+name, pterm.DefaultInteractiveTextInput.
+				WithDefaultText(fmt.Sprintf("The plan needs a value for ${%s}", name))))
+		}
+		if value == "" {
+			return doc, fmt.Errorf("${%s} can't be empty", name)
+		}
+		values[name] = value
+	}
+	return doc.Fill(values)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// where the community-maintained presets live unless config.yaml says otherwise
+const defaultPresetsURL = "https://raw.githubusercontent.com/gwelican/wow-profile-copy/main/presets.json"
+
+// how long a cached presets file is trusted before we try to refresh it
+const presetsMaxAge = 7 * 24 * time.Hour
+
+// Presets are shared lists that go stale faster than releases do, e.g. which account SavedVariables
+// are dangerous to copy. They add to the built-in lists, never replace them
+type Presets struct {
+	Version                             int                 `json:"version"`
+	CrossCharacterAccountSavedVariables []string            `json:"cross_character_account_saved_variables"`
+	CombatLogSavedVariables             []string            `json:"combat_log_saved_variables"`
+	SavedVariablesNamesByFlavor         []map[string]string `json:"saved_variables_names_by_flavor"`
+	CVarsByFlavor                       map[string][]string `json:"cvars_by_flavor"`
+}
+
+const supportedPresetsVersion = 1
+
+func presetsCachePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "presets.json"), nil
+}
+
+// loads presets from the local cache, nothing at all before they were ever downloaded
+// forceUpdate downloads them first; otherwise, with refresh, a cache older than presetsMaxAge (or one that fails
+// its signature check) is downloaded again in the background for the next run, this one never waits on the network
+// network failures of forceUpdate are returned alongside whatever could be loaded, they're never fatal
+// with trusted keys, a download is only used when url.sig is its signature by one of them
+func loadPresets(url string, forceUpdate bool, refresh bool, trusted []string) (Presets, error) {
+	if url == "" {
+		url = defaultPresetsURL
+	}
+
+	cachePath, err := presetsCachePath()
+	if err != nil {
+		return Presets{}, err
+	}
+
+	var fetchErr error
+	if forceUpdate {
+		fetchErr = fetchPresets(url, cachePath, trusted)
+	}
+	presets, err := readCachedPresets(cachePath, trusted)
+	if refresh && !forceUpdate {
+		info, statErr := os.Stat(cachePath)
+		// a cache from before trusted_keys was set has no signature yet, one that fails is downloaded again too
+		if statErr != nil || time.Since(info.ModTime()) >= presetsMaxAge || err != nil {
+			go func() {
+				if err := fetchPresets(url, cachePath, trusted); err != nil {
+					pterm.Debug.Printfln("couldn't refresh the community presets: %s", err)
+				}
+			}()
+		}
+	}
+	if err != nil {
+		return Presets{}, err
+	}
+	return presets, fetchErr
+}
+
+// the presets cached at cachePath, none when there's no cache yet
+func readCachedPresets(cachePath string, trusted []string) (Presets, error) {
+	data, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		// never downloaded, the built-in lists will have to do
+		return Presets{}, nil
+	}
+	if err != nil {
+		return Presets{}, err
+	}
+	// the cache is checked every time it's loaded, not only when it's downloaded, anyone can write to it
+	if err := verifyCachedPresets(cachePath, trusted); err != nil {
+		return Presets{}, fmt.Errorf("cached presets in %s can't be trusted, %w", cachePath, err)
+	}
+	presets, err := parsePresets(data)
+	if err != nil {
+		return Presets{}, fmt.Errorf("cached presets in %s are unreadable: %w", cachePath, err)
+	}
+	return presets, nil
+}
+
+// downloads url into cachePath, only replacing the cache if the download parses (and is signed by one of trusted,
+// when there are any)
+// the files are written next to the cache and renamed over it, a run that exits during a background refresh
+// leaves the old cache as it was
+func fetchPresets(url string, cachePath string, trusted []string) error {
+	data, err := downloadPresetsFile(url)
+	if err != nil {
+		return err
+	}
+	if _, err := parsePresets(data); err != nil {
+		return fmt.Errorf("presets from %s: %w", url, err)
+	}
+	var signature []byte
+	if len(trusted) > 0 {
+		signature, err = downloadPresetsFile(url + signatureExtension)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(data, signature, trusted); err != nil {
+			return fmt.Errorf("presets from %s can't be trusted, %w", url, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	if len(trusted) > 0 {
+		if err := replaceFile(cachePath+signatureExtension, signature); err != nil {
+			return err
+		}
+	}
+	return replaceFile(cachePath, data)
+}
+
+// writes data to path by way of a temporary file next to it, so path is never left half written
+func replaceFile(path string, data []byte) error {
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// checks the cached presets against the signature saved next to them, when there are trusted keys to check with
+func verifyCachedPresets(cachePath string, trusted []string) error {
+	if len(trusted) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return err
+	}
+	signature, err := os.ReadFile(cachePath + signatureExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("they aren't signed")
+	}
+	if err != nil {
+		return err
+	}
+	return verifySignature(data, signature, trusted)
+}
+
+func downloadPresetsFile(url string) ([]byte, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func parsePresets(data []byte) (Presets, error) {
+	var presets Presets
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return presets, err
+	}
+	if presets.Version > supportedPresetsVersion {
+		return presets, fmt.Errorf("presets version %d is newer than this build understands (%d), update wow-profile-copy", presets.Version, supportedPresetsVersion)
+	}
+	return presets, nil
+}
+
+// merges presets into the built-in lists
+func (p Presets) apply() {
+	_crossCharacterAccountSavedVariables = deduplicateStringSlice(append(_crossCharacterAccountSavedVariables, p.CrossCharacterAccountSavedVariables...))
+	_combatLogSavedVariables = deduplicateStringSlice(append(_combatLogSavedVariables, p.CombatLogSavedVariables...))
+	_savedVariablesNamesByFlavor = append(_savedVariablesNamesByFlavor, p.SavedVariablesNamesByFlavor...)
+	// a family without a built-in list keeps every CVar, so presets can't start filtering it by accident
+	for flavor, cvars := range p.CVarsByFlavor {
+		if len(_cvarsByFlavor[flavor]) > 0 {
+			_cvarsByFlavor[flavor] = deduplicateStringSlice(append(_cvarsByFlavor[flavor], cvars...))
+		}
+	}
+}
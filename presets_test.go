@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// a server of presets that signals requested the first time it is asked, so tests can tell whether it was
+func newPresetsServer(t *testing.T, presets string) (*httptest.Server, chan struct{}) {
+	requested := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(presets))
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requested
+}
+
+// cached presets listing list as a cross-character SavedVariables file, written age ago
+func writeCachedPresets(t *testing.T, list string, age time.Duration) string {
+	_dataDirOverride = t.TempDir()
+	t.Cleanup(func() { _dataDirOverride = "" })
+	cachePath, err := presetsCachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFixtureFile(t, cachePath, `{"version": 1, "cross_character_account_saved_variables": ["`+list+`"]}`)
+	modified := time.Now().Add(-age)
+	if err := os.Chtimes(cachePath, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	return cachePath
+}
+
+func TestLoadPresetsUsesAStaleCacheAndRefreshesItInTheBackground(t *testing.T) {
+	cachePath := writeCachedPresets(t, "Cached", 2*presetsMaxAge)
+	server, requested := newPresetsServer(t, `{"version": 1, "cross_character_account_saved_variables": ["Downloaded"]}`)
+
+	presets, err := loadPresets(server.URL, false, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := presets.CrossCharacterAccountSavedVariables; len(got) != 1 || got[0] != "Cached" {
+		t.Errorf("got %v, want the cached presets", got)
+	}
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stale cache wasn't refreshed")
+	}
+	// the download is written right after it's served
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if presets, err := readCachedPresets(cachePath, nil); err == nil && len(presets.CrossCharacterAccountSavedVariables) == 1 && presets.CrossCharacterAccountSavedVariables[0] == "Downloaded" {
+			return
+		}
+	}
+	t.Error("the refreshed presets weren't cached")
+}
+
+func TestLoadPresetsWithoutRefreshNeverDownloads(t *testing.T) {
+	writeCachedPresets(t, "Cached", 2*presetsMaxAge)
+	server, requested := newPresetsServer(t, `{"version": 1}`)
+
+	if _, err := loadPresets(server.URL, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-requested:
+		t.Error("presets were downloaded by a command that doesn't use them")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLoadPresetsForceUpdateDownloadsFirst(t *testing.T) {
+	cachePath := writeCachedPresets(t, "Cached", 0)
+	server, _ := newPresetsServer(t, `{"version": 1, "cross_character_account_saved_variables": ["Downloaded"]}`)
+
+	presets, err := loadPresets(server.URL, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := presets.CrossCharacterAccountSavedVariables; len(got) != 1 || got[0] != "Downloaded" {
+		t.Errorf("got %v, want the downloaded presets", got)
+	}
+	if _, err := os.Stat(cachePath + ".tmp"); err == nil {
+		t.Error("the temporary download was left behind")
+	}
+}
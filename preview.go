@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// files bigger than this aren't previewed, SavedVariables can run into the hundreds of megabytes
+const previewMaxBytes = 64 << 10
+
+// only plain text the game writes is previewed
+var _previewableExtensions = []string{".wtf", ".txt", ".lua"}
+
+// whether path is small plain text that previewFile can show
+func previewable(path string, size int64) bool {
+	return size <= previewMaxBytes && copyplan.MatchesAnyPattern(strings.ToLower(filepath.Ext(path)), _previewableExtensions)
+}
+
+// prints path from store if it's small plain text, and says why not otherwise
+func previewFile(store wtf.Store, path string) error {
+	if !copyplan.MatchesAnyPattern(strings.ToLower(filepath.Ext(path)), _previewableExtensions) {
+		return fmt.Errorf("only %s files can be previewed", strings.Join(_previewableExtensions, ", "))
+	}
+	info, err := store.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() > previewMaxBytes {
+		return fmt.Errorf("%s is %s, too big to preview (the limit is %s)", filepath.Base(path), formatBytes(info.Size()), formatBytes(previewMaxBytes))
+	}
+	data, err := wtf.ReadFile(store, path)
+	if err != nil {
+		return err
+	}
+	pterm.DefaultBox.WithTitle(filepath.Base(path)).Println(highlightPreview(filepath.Base(path), string(data)))
+	return nil
+}
+
+// colors text the way the file it came from is laid out, so macros and bindings can be told apart at a glance
+func highlightPreview(name string, text string) string {
+	var highlight func(string) string
+	switch {
+	case strings.EqualFold(name, "AddOns.txt"):
+		highlight = highlightAddonsLine
+	case strings.HasPrefix(strings.ToLower(name), "macros-cache"):
+		highlight = highlightMacroLine
+	case strings.HasSuffix(strings.ToLower(name), ".wtf"):
+		highlight = highlightWtfLine
+	case strings.HasSuffix(strings.ToLower(name), ".lua"):
+		highlight = highlightLuaLine
+	default:
+		return strings.TrimRight(text, "\r\n")
+	}
+
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = highlight(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// "SET name "value"" in config-cache.wtf, "bind KEY COMMAND" in bindings-cache.wtf
+func highlightWtfLine(line string) string {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 2 {
+		return line
+	}
+	fields[0] = pterm.ThemeDefault.SecondaryStyle.Sprint(fields[0])
+	fields[1] = pterm.ThemeDefault.PrimaryStyle.Sprint(fields[1])
+	return strings.Join(fields, " ")
+}
+
+// each macro starts with "MACRO <id> "<name>" <icon>" and ends with "END", the lines between are its body
+func highlightMacroLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "MACRO "), strings.HasPrefix(line, "VER "):
+		return pterm.ThemeDefault.SecondaryStyle.Sprint(line)
+	case line == "END":
+		return pterm.ThemeDefault.SecondaryStyle.Sprint(line)
+	case strings.HasPrefix(line, "#"), strings.HasPrefix(line, "/"):
+		command, rest, _ := strings.Cut(line, " ")
+		return strings.TrimSpace(pterm.ThemeDefault.PrimaryStyle.Sprint(command) + " " + rest)
+	}
+	return line
+}
+
+// "Name: enabled" or "Name: disabled"
+func highlightAddonsLine(line string) string {
+	name, state, ok := strings.Cut(line, ": ")
+	if !ok {
+		return line
+	}
+	style := pterm.ThemeDefault.SuccessMessageStyle
+	if strings.TrimSpace(state) != "enabled" {
+		style = pterm.ThemeDefault.WarningMessageStyle
+	}
+	return name + ": " + style.Sprint(state)
+}
+
+// only comments are dimmed, SavedVariables are nearly all table constructors anyway
+func highlightLuaLine(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "--") {
+		return pterm.FgGray.Sprint(line)
+	}
+	return line
+}
+
+// lets the user read the small text files a plan copies, both the source's and what the destination has now,
+// before confirming, e.g. to check these really are the right character's macros
+func (wow WowInstall) previewPlanFiles(plans []copyplan.Plan) {
+	const doneOption = "[Done previewing]"
+
+	type previewChoice struct {
+		store wtf.Store
+		path  string
+	}
+	var options []string
+	choices := make(map[string]previewChoice)
+	for _, plan := range plans {
+		for _, step := range plan.Steps {
+			if step.Category != copyplan.CategoryAccountConfig && step.Category != copyplan.CategoryCharacterConfig {
+				continue
+			}
+			if !previewable(step.Src, step.Size) {
+				continue
+			}
+			source := fmt.Sprintf("Source %s: %s", step.Category, filepath.Base(step.Src))
+			if _, ok := choices[source]; !ok {
+				options = append(options, source)
+				choices[source] = previewChoice{plan.SourceFiles(), step.Src}
+			}
+			if info, err := wow.Files().Stat(step.Dst); err == nil && previewable(step.Dst, info.Size()) {
+				destination := fmt.Sprintf("%s-%s now: %s", plan.Destination.Name, plan.Destination.Server, filepath.Base(step.Dst))
+				if step.Category == copyplan.CategoryAccountConfig {
+					destination = fmt.Sprintf("%s now: %s", plan.Destination.Account, filepath.Base(step.Dst))
+				}
+				if _, ok := choices[destination]; !ok {
+					options = append(options, destination)
+					choices[destination] = previewChoice{wow.Files(), step.Dst}
+				}
+			}
+		}
+	}
+	if len(options) == 0 {
+		pterm.Info.Println("None of the copied settings files are small enough to preview")
+		return
+	}
+	options = append(options, doneOption)
+
+	for {
+		choice := askSelect("preview", pterm.DefaultInteractiveSelect.
+			WithOptions(options).
+			WithDefaultText("Which file do you want to read?").
+			WithMaxHeight(selectMaxHeight()))
+		if choice == doneOption {
+			return
+		}
+		if err := previewFile(choices[choice].store, choices[choice].path); err != nil {
+			pterm.Warning.Println(err)
+		}
+	}
+}
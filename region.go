@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wow-profile-copy/wtf"
+)
+
+// config-cache.wtf and Config.wtf record which region's servers the client last talked to
+// SET portal "EU" on modern clients, SET realmList "eu.logon.worldofwarcraft.com" on older ones
+var _regionSettingPattern = regexp.MustCompile(`(?i)^SET (?:portal|realmList) "([a-z]{2})\b`)
+
+// works out which region (US, EU, KR...) an account plays on, or "" if it can't be told
+// the account's own config-cache.wtf wins over the version-wide Config.wtf
+func (wow WowInstall) accountRegion(version string, account string) string {
+	candidates := []string{
+		filepath.Join(wow.Dir, version, "WTF", "Account", account, "config-cache.wtf"),
+		filepath.Join(wow.Dir, version, "WTF", "Config.wtf"),
+	}
+	for _, path := range candidates {
+		if region := readRegionSetting(wow.Files(), path); region != "" {
+			return region
+		}
+	}
+	return ""
+}
+
+func readRegionSetting(store wtf.Store, path string) string {
+	file, err := store.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := _regionSettingPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			return strings.ToUpper(match[1])
+		}
+	}
+	return ""
+}
+
+// the region of a copy target, for display
+func (wow WowInstall) targetRegion(target wtf.Target) string {
+	region := wow.accountRegion(target.Version, target.Account)
+	if region == "" {
+		return "unknown"
+	}
+	return region
+}
+
+// labels an account for the pickers, e.g. "WOWACCOUNT (EU)"
+func (wow WowInstall) accountLabel(version string, account string) string {
+	if region := wow.accountRegion(version, account); region != "" {
+		return account + " (" + region + ")"
+	}
+	return account
+}
@@ -1,6 +1,7 @@
 #!/bin/sh
 
 go get
+go generate
 #echo "building linux/amd64"
 #GOOS=linux GOARCH=amd64 go build -o wow-profile-copy-linux-amd64 .
 echo "building windows/amd64"
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// the WTF folder of version, which may be a link to wherever it was relocated
+func (wow WowInstall) wtfPath(version string) string {
+	return filepath.Join(wow.Dir, version, "WTF")
+}
+
+// where WTF is relocated to, or "" when it's a normal folder
+func relocatedWtfTarget(wtfDir string) (string, error) {
+	info, err := os.Lstat(wtfDir)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return "", nil
+	}
+	return os.Readlink(wtfDir)
+}
+
+// moves version's WTF folder into to and leaves a link behind, so the game and every copy keep finding it
+func (wow WowInstall) relocateWtf(version string, to string) (string, error) {
+	wtfDir := wow.wtfPath(version)
+	current, err := relocatedWtfTarget(wtfDir)
+	if err != nil {
+		return "", err
+	}
+	if current != "" {
+		return "", fmt.Errorf("%s is already relocated to %s, undo that first", wtfDir, current)
+	}
+
+	target := filepath.Join(to, version, "WTF")
+	if _, err := os.Lstat(target); err == nil {
+		return "", fmt.Errorf("%s already exists, pick an empty folder to relocate to", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := moveDirectory(wtfDir, target); err != nil {
+		return "", err
+	}
+	if err := linkDirectory(target, wtfDir); err != nil {
+		// put things back so the game still finds its settings
+		if undoErr := moveDirectory(target, wtfDir); undoErr != nil {
+			return "", fmt.Errorf("%w, and moving it back from %s failed too: %s", err, target, undoErr)
+		}
+		return "", err
+	}
+	return target, nil
+}
+
+// removes the link left by relocateWtf and moves the folder back into the install
+func (wow WowInstall) restoreWtf(version string) (string, error) {
+	wtfDir := wow.wtfPath(version)
+	target, err := relocatedWtfTarget(wtfDir)
+	if err != nil {
+		return "", err
+	}
+	if target == "" {
+		return "", fmt.Errorf("%s is not relocated", wtfDir)
+	}
+	if _, err := os.Stat(target); err != nil {
+		return "", fmt.Errorf("%s is relocated to %s, which can't be reached: %w", wtfDir, target, err)
+	}
+
+	// removing a link never touches what it points to
+	if err := os.Remove(wtfDir); err != nil {
+		return "", err
+	}
+	if err := moveDirectory(target, wtfDir); err != nil {
+		if linkErr := linkDirectory(target, wtfDir); linkErr != nil {
+			return "", fmt.Errorf("%w, and linking %s again failed too: %s", err, target, linkErr)
+		}
+		return "", err
+	}
+	return target, nil
+}
+
+// renames src to dst, falling back to copying and deleting when they're on different drives
+func moveDirectory(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+
+	copyErr := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if _, err := copyplan.CopyFile(path, target, 0); err != nil {
+			return err
+		}
+		// the source is deleted afterwards, so every file has to have made it
+		return copyplan.VerifyCopy(wtf.LocalStore{}, path, target)
+	})
+	if copyErr != nil {
+		// the source is untouched, only the partial copy goes
+		os.RemoveAll(dst)
+		return copyErr
+	}
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// makes link point at the directory target
+func linkDirectory(target string, link string) error {
+	return os.Symlink(target, link)
+}
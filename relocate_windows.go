@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// makes link a junction to the directory target
+// unlike symlinks, junctions don't need an administrator or developer mode to create
+func linkDirectory(target string, link string) error {
+	output, err := exec.Command("cmd.exe", "/C", "mklink", "/J", link, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("creating a junction at %s: %s", link, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// the name of every run's backup directory, see newCopier
+const backupTimeLayout = "20060102-150405"
+
+// the files one run backed up of a character: its own, and its account's
+// a run that backed up only account files has a target without a server and character
+type characterBackup struct {
+	directory string // the run's backup directory
+	taken     time.Time
+	target    wtf.Target
+	files     []string // relative to directory, which mirrors the install directory
+}
+
+func (b characterBackup) String() string {
+	who := fmt.Sprintf("%s/%s/%s", b.target.Account, b.target.Server, b.target.Name)
+	if b.target.Name == "" {
+		who = b.target.Account + " (account files only)"
+	}
+	return fmt.Sprintf("%s  %s  %s  (%d files)", b.taken.Format("2006-01-02 15:04:05"), _wowInstanceFolderNames[b.target.Version], who, len(b.files))
+}
+
+// the timestamp restore takes to pick this backup
+func (b characterBackup) id() string {
+	return filepath.Base(b.directory)
+}
+
+// every character backup of wow's versions, newest first
+func (wow WowInstall) listBackups() ([]characterBackup, error) {
+	root, err := backupsDir()
+	if err != nil {
+		return nil, err
+	}
+	runs, err := os.ReadDir(root)
+	if classifyFileError(err) == fileErrorNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []characterBackup
+	for _, run := range runs {
+		taken, err := time.ParseInLocation(backupTimeLayout, run.Name(), time.Local)
+		if !run.IsDir() || err != nil {
+			continue
+		}
+		found, err := wow.readBackup(filepath.Join(root, run.Name()), taken)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, found...)
+	}
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].taken.After(backups[j].taken) })
+	return backups, nil
+}
+
+// groups the files of one run's backup directory by character
+// version/WTF/Account/<account>/<file> and .../<account>/SavedVariables/<file> are account files, they go
+// with every character of that account the run backed up
+func (wow WowInstall) readBackup(dir string, taken time.Time) ([]characterBackup, error) {
+	accountFiles := make(map[wtf.Target][]string)
+	characterFiles := make(map[wtf.Target][]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(relativePath, string(filepath.Separator))
+		if len(parts) < 5 || parts[1] != "WTF" || parts[2] != "Account" || !wow.HasVersion(parts[0]) {
+			return nil
+		}
+		account := wtf.Target{Version: parts[0], Character: wtf.Character{Account: parts[3]}}
+		switch {
+		case len(parts) == 5, len(parts) == 6 && parts[4] == "SavedVariables":
+			accountFiles[account] = append(accountFiles[account], relativePath)
+		case len(parts) >= 7:
+			character := wtf.Target{Version: parts[0], Character: wtf.Character{Account: parts[3], Server: parts[4], Name: parts[5]}}
+			characterFiles[character] = append(characterFiles[character], relativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []characterBackup
+	for target, files := range characterFiles {
+		account := wtf.Target{Version: target.Version, Character: wtf.Character{Account: target.Account}}
+		files = append(append([]string{}, accountFiles[account]...), files...)
+		backups = append(backups, characterBackup{dir, taken, target, files})
+	}
+	for account, files := range accountFiles {
+		hasCharacter := false
+		for target := range characterFiles {
+			hasCharacter = hasCharacter || target.Version == account.Version && target.Account == account.Account
+		}
+		if !hasCharacter {
+			backups = append(backups, characterBackup{dir, taken, account, files})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].String() < backups[j].String() })
+	return backups, nil
+}
+
+// copies backup's files back to where they were backed up from, then clears the caches a copy clears
+// what the restore overwrites is backed up first, so a restore can itself be restored, returns how many files it
+// restored and the copier that did it
+// files the bad copy added that didn't exist before it have no backup, they are left in place
+func (wow WowInstall) restoreBackup(backup characterBackup, opts copyplan.CopyOptions, removed func(path string)) (int, *copyplan.Copier, error) {
+	copier, err := newCopier(opts, wow.Dir)
+	if err != nil {
+		return 0, copier, err
+	}
+	accountPath, characterPath := wow.AccountPath(backup.target), wow.CharacterPath(backup.target)
+	patterns := copyplan.CacheInvalidationPatterns(backup.target.Version, accountPath, characterPath)
+
+	// backup folders are only ours as long as nobody put anything else in them
+	var paths []string
+	for _, file := range backup.files {
+		paths = append(paths, filepath.Join(wow.Dir, file))
+	}
+	if problems := wow.escapingTargetPaths(backup.target, append(paths, patterns...)); len(problems) > 0 {
+		return 0, copier, fmt.Errorf("refusing to restore %s, it would write outside its account folder:\n%s", backup, strings.Join(problems, "\n"))
+	}
+
+	restored := 0
+	for _, file := range backup.files {
+		dst := filepath.Join(wow.Dir, file)
+		// the cleanup below would only delete it again
+		if copyplan.MatchesAnyPattern(dst, patterns) {
+			continue
+		}
+		if err := copier.Copy(filepath.Join(backup.directory, file), dst); err != nil {
+			return restored, copier, err
+		}
+		restored++
+	}
+	return restored, copier, copyplan.InvalidateCaches(patterns, removed)
+}
+
+// narrows backups down to target's, account files only backups of its account included, and to the run id names
+// when it's not empty
+func filterBackups(backups []characterBackup, target *wtf.Target, id string) []characterBackup {
+	var kept []characterBackup
+	for _, backup := range backups {
+		if id != "" && backup.id() != id {
+			continue
+		}
+		if target != nil && (backup.target.Version != target.Version || backup.target.Account != target.Account ||
+			backup.target.Name != "" && backup.target.Character != target.Character) {
+			continue
+		}
+		kept = append(kept, backup)
+	}
+	return kept
+}
+
+// lets the user pick one of backups, newest first
+func selectBackup(backups []characterBackup) characterBackup {
+	var options []string
+	for _, backup := range backups {
+		options = append(options, backup.String())
+	}
+	picked := askSelect("restore", pterm.DefaultInteractiveSelect.
+		WithOptions(options).
+		WithMaxHeight(selectMaxHeight()).
+		WithDefaultText("Backup to restore"))
+	for _, backup := range backups {
+		if backup.String() == picked {
+			return backup
+		}
+	}
+	return backups[0]
+}
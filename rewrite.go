@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wtf"
+)
+
+// the rewrite_rules from config.yaml, after the identity rewrites of every copy
+func (c Config) rewriteRules() ([]savedvars.Rule, error) {
+	var rules []savedvars.Rule
+	for _, configured := range c.RewriteRules {
+		rule, err := savedvars.NewRule(configured.From, configured.To, configured.Regex)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// the rules the rewrite command applies: from and to are "Name-Realm" pairs (character names can't contain a
+// dash, so everything after the first one is the realm), rewritten in every form addons key them by
+func rewriteCommandRules(from string, to string) ([]savedvars.Rule, error) {
+	fromName, fromRealm, fromOk := strings.Cut(from, "-")
+	toName, toRealm, toOk := strings.Cut(to, "-")
+	if !fromOk || !toOk || fromName == "" || fromRealm == "" || toName == "" || toRealm == "" {
+		return nil, fmt.Errorf("--from and --to should look like Name-Realm, e.g. --from Mainchar-Old-Realm --to Mainchar-New-Realm")
+	}
+	src := wtf.Target{Character: wtf.Character{Name: fromName, Server: fromRealm}}
+	dst := wtf.Target{Character: wtf.Character{Name: toName, Server: toRealm}}
+	return copyplan.IdentityRewriteRules(src, dst), nil
+}
+
+// the rules the rewrite command applies to rename a realm for every character on it: the realm in each form
+// addons key characters by, and on its own
+// realm names can contain a dash and character names can't, so in Name-Realm the realm is all after the first one
+func realmRewriteRules(from string, to string) ([]savedvars.Rule, error) {
+	if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+		return nil, errors.New("--from-realm and --to-realm need a realm name each, e.g. --from-realm Old-Realm --to-realm New-Realm")
+	}
+	old, replacement := regexp.QuoteMeta(from), strings.ReplaceAll(to, "$", "$$")
+	var rules []savedvars.Rule
+	for _, spec := range [][2]string{
+		{`^([^-\s]+)-` + old + `$`, `${1}-` + replacement},
+		{`^([^-\s]+) - ` + old + `$`, `${1} - ` + replacement},
+		{`^` + old + ` - ([^-\s]+)$`, replacement + ` - ${1}`},
+		{`^` + old + `$`, replacement},
+	} {
+		rule, err := savedvars.NewRule(spec[0], spec[1], true)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// the WoW install dir is in, "" when it isn't in one
+func containingInstall(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if isWowInstallDirectory(dir) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// the .lua files under dir that rules would change
+func filesToRewrite(dir string, rules []savedvars.Rule) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".lua") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(savedvars.Rewrite(data, rules), data) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
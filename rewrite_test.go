@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"wow-profile-copy/internal/savedvars"
+)
+
+func TestRewriteCommandRules(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		rules     func() ([]savedvars.Rule, error)
+		data      string
+		rewritten string
+	}{
+		{"a character, with a dash in its realm",
+			func() ([]savedvars.Rule, error) { return rewriteCommandRules("Main-Old-Realm", "Main-New-Realm") },
+			`A = { "Main-Old-Realm", "Main - Old-Realm", "Old-Realm - Main", "Alt-Old-Realm", "Old-Realm" }`,
+			`A = { "Main-New-Realm", "Main - New-Realm", "New-Realm - Main", "Alt-Old-Realm", "Old-Realm" }`},
+		{"a realm, for every character on it",
+			func() ([]savedvars.Rule, error) { return realmRewriteRules("Old-Realm", "New-Realm") },
+			`A = { "Main-Old-Realm", "Alt - Old-Realm", "Old-Realm - Bank", "Old-Realm", "Main-Other-Old-Realm", "Old-Realms" }`,
+			`A = { "Main-New-Realm", "Alt - New-Realm", "New-Realm - Bank", "New-Realm", "Main-Other-Old-Realm", "Old-Realms" }`},
+		{"a realm without a dash",
+			func() ([]savedvars.Rule, error) { return realmRewriteRules("Stormrage", "Area 52") },
+			`A = { ["Main-Stormrage"] = "Stormrage", "Main-Area 52" }`,
+			`A = { ["Main-Area 52"] = "Area 52", "Main-Area 52" }`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := test.rules()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rewritten := string(savedvars.Rewrite([]byte(test.data), rules)); rewritten != test.rewritten {
+				t.Errorf("got  %s\nwant %s", rewritten, test.rewritten)
+			}
+		})
+	}
+}
+
+func TestRewriteCommandRulesErrors(t *testing.T) {
+	if _, err := rewriteCommandRules("Old-Realm", "Realm"); err == nil {
+		t.Error("a --to without a realm was accepted")
+	}
+	if _, err := realmRewriteRules("Old-Realm", " "); err == nil {
+		t.Error("an empty --to-realm was accepted")
+	}
+}
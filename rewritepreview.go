@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/internal/savedvars"
+)
+
+// lines of unchanged context around each change in a diff
+const diffContextLines = 3
+
+// what rewriting one file would do to it
+type rewritePreview struct {
+	path         string
+	before       []byte
+	after        []byte
+	replacements int
+}
+
+// reads every file in paths and works out what applying rules to it would change
+func previewRewrites(paths []string, rules []savedvars.Rule) ([]rewritePreview, error) {
+	var previews []rewritePreview
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, rewritePreview{path, data, savedvars.Rewrite(data, rules), savedvars.CountRewrites(data, rules)})
+	}
+	return previews, nil
+}
+
+// a table of how many replacements each file would get, with a total
+func rewritePreviewTable(previews []rewritePreview, dir string) [][]string {
+	table := [][]string{{"File", "Replacements", "Size"}}
+	total := 0
+	for _, preview := range previews {
+		table = append(table, []string{relativeTo(dir, preview.path), fmt.Sprint(preview.replacements), formatBytes(int64(len(preview.before)))})
+		total += preview.replacements
+	}
+	return append(table, []string{"Total", fmt.Sprint(total), ""})
+}
+
+// asks about each of previews in turn, whether to rewrite it, skip it, or look at the changes first
+// returns the paths to rewrite, yes rewrites them all without asking
+func reviewRewrites(previews []rewritePreview, yes bool) []string {
+	const rewriteOption = "Rewrite it"
+	const diffOption = "Show the changes"
+	const skipOption = "Skip it"
+	const allOption = "Rewrite it and every file after it"
+	const cancelOption = "Cancel, rewrite nothing"
+
+	var paths []string
+	for i, preview := range previews {
+		options := []string{rewriteOption}
+		// SavedVariables can run into the hundreds of megabytes, nobody reads a diff of that
+		if len(preview.before) <= previewMaxBytes {
+			options = append(options, diffOption)
+		}
+		options = append(options, skipOption, allOption, cancelOption)
+
+		choice := rewriteOption
+		for !yes {
+			choice = askSelect("rewrite.file", pterm.DefaultInteractiveSelect.
+				WithOptions(options).
+				WithDefaultText(fmt.Sprintf("%s (%d of %d): %d replacements", filepath.Base(preview.path), i+1, len(previews), preview.replacements)))
+			if choice != diffOption {
+				break
+			}
+			pterm.Println(unifiedDiff(preview.path, preview.before, preview.after))
+		}
+		switch choice {
+		case rewriteOption:
+			paths = append(paths, preview.path)
+		case allOption:
+			paths = append(paths, preview.path)
+			yes = true
+		case cancelOption:
+			return nil
+		}
+	}
+	return paths
+}
+
+// the changes from before to after as a unified diff with colored lines
+func unifiedDiff(name string, before []byte, after []byte) string {
+	a := strings.Split(strings.TrimSuffix(string(before), "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(string(after), "\n"), "\n")
+
+	// common[i][j] is how many lines a[i:] and b[j:] have in common, in order
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	// every line as kept (' '), removed ('-'), or added ('+'), with where it is in a and b
+	type diffLine struct {
+		kind   byte
+		text   string
+		aIndex int
+		bIndex int
+	}
+	var lines []diffLine
+	var changed []int
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			changed = append(changed, len(lines))
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			changed = append(changed, len(lines))
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(pterm.Bold.Sprintf("--- %s\n+++ %s (rewritten)", name, name))
+	for start := 0; start < len(changed); {
+		// changes closer together than twice the context share a hunk
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*diffContextLines {
+			end++
+		}
+		first, last := changed[start]-diffContextLines, changed[end]+diffContextLines
+		if first < 0 {
+			first = 0
+		}
+		if last >= len(lines) {
+			last = len(lines) - 1
+		}
+
+		var aCount, bCount int
+		var body strings.Builder
+		for _, line := range lines[first : last+1] {
+			switch line.kind {
+			case ' ':
+				aCount++
+				bCount++
+				body.WriteString("\n " + line.text)
+			case '-':
+				aCount++
+				body.WriteString("\n" + pterm.FgRed.Sprint("-"+line.text))
+			case '+':
+				bCount++
+				body.WriteString("\n" + pterm.FgGreen.Sprint("+"+line.text))
+			}
+		}
+		aStart, bStart := lines[first].aIndex+1, lines[first].bIndex+1
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		out.WriteString("\n" + pterm.FgCyan.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount))
+		out.WriteString(body.String())
+		start = end + 1
+	}
+	return out.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+)
+
+// how many runs' logs are kept, older ones are removed when a new one starts
+const maxRunLogs = 20
+
+// where each copy's log is written, one file per run
+func runLogsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs"), nil
+}
+
+// the output of a copy, printed to the terminal and written to a log file with the operation (usually a
+// destination) each line is about
+// lines are written whole and one at a time, so copies to several destinations can report from several goroutines
+type runLog struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+
+	// whether the terminal shows the operation too, only worth it when there's more than one
+	tagTerminal bool
+}
+
+// until openRunLog is called, lines only go to the terminal
+var _runLog = &runLog{}
+
+// starts a new log file for this run, removing the oldest ones beyond maxRunLogs
+func openRunLog() error {
+	dir, err := runLogsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405")+".log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_runLog.mu.Lock()
+	_runLog.file, _runLog.path = file, path
+	_runLog.mu.Unlock()
+	onExit(func(int) { _runLog.close() })
+
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return err
+	}
+	// the timestamped names sort oldest first
+	sort.Strings(logs)
+	for len(logs) > maxRunLogs {
+		os.Remove(logs[0])
+		logs = logs[1:]
+	}
+	return nil
+}
+
+func (l *runLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// prints a line through printer, tagged with operation when it's set, and writes it to the log file
+func (l *runLog) printf(printer pterm.PrefixPrinter, operation string, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if operation != "" && l.tagTerminal {
+		printer.Printfln("[%s] %s", operation, message)
+	} else {
+		printer.Println(message)
+	}
+	if l.file == nil {
+		return
+	}
+	tag := operation
+	if tag == "" {
+		tag = "-"
+	}
+	level := strings.TrimSpace(printer.Prefix.Text)
+	// multi-line messages stay attributed on every line
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Fprintf(l.file, "%s %-7s [%s] %s\n", time.Now().Format(time.RFC3339), level, tag, line)
+	}
+}
+
+// the copyplan.Log copies report through, printed and logged like the rest of the run, with file errors explained
+func (l *runLog) copyLog() copyplan.Log {
+	printers := map[copyplan.Level]pterm.PrefixPrinter{
+		copyplan.LevelDebug:   pterm.Debug,
+		copyplan.LevelInfo:    pterm.Info,
+		copyplan.LevelWarning: pterm.Warning,
+		copyplan.LevelError:   pterm.Error,
+	}
+	return func(level copyplan.Level, operation string, format string, args ...any) {
+		for i, arg := range args {
+			if err, ok := arg.(error); ok {
+				args[i] = explainFileError(err)
+			}
+		}
+		l.printf(printers[level], operation, format, args...)
+	}
+}
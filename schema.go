@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// the schemas config.yaml, plan files, and batch job files are checked against when they're read, also handy for editors
+//
+//go:embed config.schema.json
+var _embeddedConfigSchema []byte
+
+//go:embed plan.schema.json
+var _embeddedPlanSchema []byte
+
+//go:embed job.schema.json
+var _embeddedJobSchema []byte
+
+var (
+	_configSchema = mustParseSchema(_embeddedConfigSchema)
+	_planSchema   = mustParseSchema(_embeddedPlanSchema)
+	_jobSchema    = mustParseSchema(_embeddedJobSchema)
+)
+
+// the part of JSON Schema the schemas use: types, properties, items, enums, required fields, minimums,
+// patterns, and references to $defs
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"` // false, or the schema of every other property
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Required             []string               `json:"required"`
+	Minimum              *float64               `json:"minimum"`
+	Pattern              string                 `json:"pattern"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+func mustParseSchema(data []byte) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		panic(fmt.Sprintf("embedded schema is invalid: %s", err))
+	}
+	return &schema
+}
+
+// something in a file that doesn't match its schema
+type schemaProblem struct {
+	line    int
+	field   string // the path to it, like syncs.raid.dst[1]
+	problem string
+}
+
+func (p schemaProblem) String() string {
+	if p.field == "" {
+		return fmt.Sprintf("line %d: %s", p.line, p.problem)
+	}
+	return fmt.Sprintf("line %d: %s: %s", p.line, p.field, p.problem)
+}
+
+// every problem found in a file, as one error
+type schemaError []schemaProblem
+
+func (e schemaError) Error() string {
+	var lines []string
+	for _, problem := range e {
+		lines = append(lines, "  "+problem.String())
+	}
+	return "it doesn't look right:\n" + strings.Join(lines, "\n")
+}
+
+// checks a YAML or JSON document against schema, returning a schemaError listing everything wrong with it
+// documents that don't parse are left to the decoder that reads them, its syntax errors say where they are
+func validateDocument(data []byte, schema *jsonSchema) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	v := schemaValidator{root: schema}
+	v.validate(doc.Content[0], schema, "")
+	if len(v.problems) > 0 {
+		return v.problems
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root     *jsonSchema
+	problems schemaError
+}
+
+func (v *schemaValidator) report(node *yaml.Node, field string, format string, args ...any) {
+	v.problems = append(v.problems, schemaProblem{node.Line, field, fmt.Sprintf(format, args...)})
+}
+
+// follows a #/$defs/name reference
+func (v *schemaValidator) resolve(schema *jsonSchema) *jsonSchema {
+	for schema.Ref != "" {
+		schema = v.root.Defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+	}
+	return schema
+}
+
+func (v *schemaValidator) validate(node *yaml.Node, schema *jsonSchema, field string) {
+	schema = v.resolve(schema)
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// an empty value is the same as leaving the field out
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			v.report(node, field, "expected a mapping of names to values, found %s", describeNode(node))
+			return
+		}
+		v.validateObject(node, schema, field)
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			v.report(node, field, "expected a list, found %s", describeNode(node))
+			return
+		}
+		for i, item := range node.Content {
+			v.validate(item, schema.Items, fmt.Sprintf("%s[%d]", field, i))
+		}
+	case "string", "integer", "number", "boolean":
+		v.validateScalar(node, schema, field)
+	}
+}
+
+func (v *schemaValidator) validateObject(node *yaml.Node, schema *jsonSchema, field string) {
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		seen[key.Value] = true
+		child := key.Value
+		if field != "" {
+			child = field + "." + key.Value
+		}
+
+		if property, ok := schema.Properties[key.Value]; ok {
+			v.validate(value, property, child)
+			continue
+		}
+		switch additional := strings.TrimSpace(string(schema.AdditionalProperties)); additional {
+		case "", "true":
+		case "false":
+			message := fmt.Sprintf("unknown field %q", key.Value)
+			if suggestion := closestName(key.Value, schema.Properties); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+			v.report(key, field, "%s", message)
+		default:
+			var valueSchema jsonSchema
+			if err := json.Unmarshal(schema.AdditionalProperties, &valueSchema); err == nil {
+				v.validate(value, &valueSchema, child)
+			}
+		}
+	}
+	for _, required := range schema.Required {
+		if !seen[required] {
+			v.report(node, field, "%q is missing", required)
+		}
+	}
+}
+
+func (v *schemaValidator) validateScalar(node *yaml.Node, schema *jsonSchema, field string) {
+	if node.Kind != yaml.ScalarNode {
+		v.report(node, field, "expected a %s, found %s", schema.Type, describeNode(node))
+		return
+	}
+	switch schema.Type {
+	case "integer":
+		if node.Tag != "!!int" {
+			v.report(node, field, "expected a whole number, found %q", node.Value)
+			return
+		}
+	case "number":
+		if node.Tag != "!!int" && node.Tag != "!!float" {
+			v.report(node, field, "expected a number, found %q", node.Value)
+			return
+		}
+	case "boolean":
+		if node.Tag != "!!bool" {
+			v.report(node, field, "expected true or false, found %q", node.Value)
+			return
+		}
+	}
+
+	if schema.Minimum != nil {
+		if number, err := strconv.ParseFloat(node.Value, 64); err == nil && number < *schema.Minimum {
+			v.report(node, field, "%s is too small, the least it can be is %v", node.Value, *schema.Minimum)
+		}
+	}
+	if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(node.Value) {
+		message := fmt.Sprintf("%q doesn't look right", node.Value)
+		if schema.Description != "" {
+			message += ", expected " + schema.Description
+		}
+		v.report(node, field, "%s", message)
+	}
+	if len(schema.Enum) > 0 {
+		var allowed []string
+		for _, value := range schema.Enum {
+			allowed = append(allowed, fmt.Sprint(value))
+		}
+		for _, value := range allowed {
+			if value == node.Value {
+				return
+			}
+		}
+		v.report(node, field, "%q isn't one of %s", node.Value, strings.Join(allowed, ", "))
+	}
+}
+
+// how a node reads in an error message
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(node.Value)
+}
+
+// the property name closest to name, for typos, or "" when none is close
+func closestName(name string, properties map[string]*jsonSchema) string {
+	var names []string
+	for property := range properties {
+		names = append(names, property)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, property := range names {
+		if distance := editDistance(strings.ToLower(name), property); distance < bestDistance {
+			best, bestDistance = property, distance
+		}
+	}
+	return best
+}
+
+// the Levenshtein distance between a and b
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// adds the line to JSON syntax and type errors, which only know their byte offset
+func explainJSONError(data []byte, err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var offset int64
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.As(err, &typeError):
+		offset = typeError.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return fmt.Errorf("line %d: %w", 1+bytes.Count(data[:offset], []byte("\n")), err)
+}
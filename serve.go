@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pterm/pterm"
+)
+
+// where serve listens unless --listen says otherwise
+const defaultServeAddress = "127.0.0.1:8790"
+
+// runs the configured syncs when something on this machine asks for one, e.g. a stream deck button or an
+// addon manager's post-update hook doing `curl -X POST http://127.0.0.1:8790/sync/alts`
+type syncTrigger struct {
+	syncs      map[string]SyncConfig
+	installDir string
+
+	// one sync at a time, two copies writing the same destination would fight over it
+	running sync.Mutex
+}
+
+// listens on address until the process is stopped, only loopback addresses are accepted
+// since a trigger overwrites characters without asking
+func serveSyncTriggers(address string, config Config, installDir string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%s is reachable from other machines, serve only listens on localhost (e.g. %s)", address, defaultServeAddress)
+	}
+	if len(config.Syncs) == 0 {
+		return fmt.Errorf("there's nothing to trigger, add a sync under syncs in config.yaml")
+	}
+
+	trigger := &syncTrigger{syncs: config.Syncs, installDir: installDir}
+	if len(config.SyncAfterAddonUpdates) > 0 {
+		for _, name := range config.SyncAfterAddonUpdates {
+			if _, ok := config.Syncs[name]; !ok {
+				return fmt.Errorf("sync_after_addon_updates names %q, which isn't one of the syncs", name)
+			}
+		}
+		managers := findAddonManagers()
+		if len(managers) == 0 {
+			pterm.Warning.Println("Neither WowUp nor CurseForge is installed, so sync_after_addon_updates has nothing to watch")
+		} else {
+			go trigger.syncAfterAddonUpdates(managers, config.SyncAfterAddonUpdates)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", trigger.list)
+	mux.HandleFunc("/sync/", trigger.sync)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	pterm.Info.Printfln("Waiting for triggers on http://%s, POST /sync/<name> runs one of: %s", listener.Addr(), strings.Join(trigger.names(), ", "))
+	return http.Serve(listener, mux)
+}
+
+func (t *syncTrigger) names() []string {
+	var names []string
+	for name := range t.syncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GET / lists the syncs that can be triggered
+func (t *syncTrigger) list(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintln(w, strings.Join(t.names(), "\n"))
+}
+
+// POST /sync/<name> runs that sync and answers once it's done, with its output
+func (t *syncTrigger) sync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "syncs are triggered with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	// browsers send an Origin with cross-site requests, so a web page can't trigger a copy behind the user's back
+	if r.Header.Get("Origin") != "" {
+		http.Error(w, "syncs can't be triggered from a web page", http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/sync/")
+	config, ok := t.syncs[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("there's no sync named %q", name), http.StatusNotFound)
+		return
+	}
+	if !t.running.TryLock() {
+		http.Error(w, "another sync is still running", http.StatusConflict)
+		return
+	}
+	defer t.running.Unlock()
+
+	output, err := t.runLogged(name, config)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write(output)
+}
+
+// runs the sync named name once whatever is running now has finished
+func (t *syncTrigger) trigger(name string) {
+	t.running.Lock()
+	defer t.running.Unlock()
+	t.runLogged(name, t.syncs[name])
+}
+
+func (t *syncTrigger) runLogged(name string, config SyncConfig) ([]byte, error) {
+	pterm.Info.Printfln("Running %s", name)
+	output, err := t.run(config)
+	if err != nil {
+		pterm.Error.Printfln("%s failed: %s", name, err)
+	} else {
+		pterm.Success.Printfln("%s finished", name)
+	}
+	return output, err
+}
+
+// runs the sync as a separate copy of this program, so a failing copy can exit the way it always does
+// without taking serve down with it, destinations already synced from the same source files are left alone
+func (t *syncTrigger) run(config SyncConfig) ([]byte, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"copy", "--yes", "--no-pause", "--if-changed", "--src", config.Src}
+	for _, dst := range config.Dst {
+		args = append(args, "--dst", dst)
+	}
+	installDir := config.InstallDir
+	if installDir == "" {
+		installDir = t.installDir
+	}
+	if installDir != "" {
+		args = append(args, "--install-dir", installDir)
+	}
+	if _dataDirOverride != "" {
+		args = append(args, "--data-dir", _dataDirOverride)
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Run()
+	return output.Bytes(), err
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/savedvars"
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
+)
+
+// stand-ins for the sharer's names, so a share doesn't give away who made it
+// import swaps them for the destination's names with the usual identity rewrites
+var _sharedWtf = wtf.Character{Account: "{{ACCOUNT}}", Server: "{{REALM}}", Name: "{{CHARACTER}}"}
+
+// bumped whenever the archive layout changes in a way older versions can't import
+const supportedShareVersion = 1
+
+const shareManifestName = "manifest.json"
+
+// the manifest's signature, in signed shares
+const shareSignatureName = shareManifestName + signatureExtension
+
+// shares are settings, not history, anything bigger than this is refused on download
+const maxShareSize = 50 << 20
+
+// describes a share archive, stored next to the files in it
+type shareManifest struct {
+	Version     int      `json:"version"`
+	GameVersion string   `json:"game_version"`
+	Files       []string `json:"files"`
+
+	// the sha256 of every file, so signing the manifest vouches for the files too
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// zips src's profile with every mention of its account, realm, and character replaced by _sharedWtf
+// account-wide SavedVariables that hold data about other characters are always left out
+// key, if set, signs the manifest, see verifyShareArchive
+func buildShareArchive(wow WowInstall, src wtf.Target, key ed25519.PrivateKey) ([]byte, error) {
+	accountSavedVariables, err := wow.Files().ReadDir(filepath.Join(wow.AccountPath(src), "SavedVariables"))
+	if err != nil {
+		return nil, err
+	}
+	skipped := make(map[string]bool)
+	for _, file := range findCrossCharacterSavedVariables(accountSavedVariables) {
+		skipped[file] = true
+	}
+
+	// planning into an install with no directory gives paths relative to the archive root
+	shared := wtf.Target{Character: _sharedWtf, Version: src.Version}
+	plan, err := copyplan.Build(wow.Install, wowinstall.Install{}, src, shared, currentDefaults(), copyplan.Options{SkippedAccountSavedVariables: skipped})
+	if err != nil {
+		return nil, err
+	}
+	rules := copyplan.IdentityRewriteRules(src, shared)
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	manifest := shareManifest{Version: supportedShareVersion, GameVersion: src.Version, Hashes: make(map[string]string)}
+	for _, step := range plan.Steps {
+		data, err := wtf.ReadFile(plan.SourceFiles(), step.Src)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(step.Src, ".lua") {
+			data = savedvars.Rewrite(data, rules)
+		}
+		name := filepath.ToSlash(step.Dst)
+		writer, err := archive.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, name)
+		hash := sha256.Sum256(data)
+		manifest.Hashes[name] = hex.EncodeToString(hash[:])
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := archive.Create(shareManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(manifestData); err != nil {
+		return nil, err
+	}
+	if key != nil {
+		if writer, err = archive.Create(shareSignatureName); err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(signData(key, manifestData)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// opens a share archive in place, as a store that works as a source install for copyplan.Build
+// with trusted keys, only shares signed by one of them are opened
+func openShareArchive(data []byte, trusted []string) (wtf.ZipStore, shareManifest, error) {
+	var manifest shareManifest
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return wtf.ZipStore{}, manifest, fmt.Errorf("not a profile share: %w", err)
+	}
+	store := wtf.ZipStore{Archive: archive}
+
+	var total uint64
+	for _, file := range archive.File {
+		if problem := shareEntryProblem(file); problem != "" {
+			return store, manifest, fmt.Errorf("the share can't be imported, %s", problem)
+		}
+		// and don't let a small download unpack into something huge, reading past the declared size is an error
+		// in archive/zip, so an entry can't lie about it
+		total += file.UncompressedSize64
+		if total > maxShareSize {
+			return store, manifest, fmt.Errorf("the share unpacks to more than %s", formatBytes(maxShareSize))
+		}
+	}
+
+	contents, err := wtf.ReadFile(store, shareManifestName)
+	if err != nil {
+		return store, manifest, fmt.Errorf("the share has no manifest: %w", err)
+	}
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return store, manifest, fmt.Errorf("the share's manifest is unreadable: %w", err)
+	}
+
+	if manifest.Version != supportedShareVersion {
+		return store, manifest, fmt.Errorf("share version %d is not supported, expected %d", manifest.Version, supportedShareVersion)
+	}
+	if _, ok := _wowInstanceFolderNames[manifest.GameVersion]; !ok {
+		return store, manifest, fmt.Errorf("the share is for an unknown game version %q", manifest.GameVersion)
+	}
+	if len(trusted) > 0 {
+		if err := verifyShareArchive(store, contents, manifest, trusted); err != nil {
+			return store, manifest, fmt.Errorf("the share can't be trusted, %w", err)
+		}
+	}
+	return store, manifest, nil
+}
+
+// checks a share's manifest was signed by one of trusted, and that every file in it is the one the manifest hashed
+func verifyShareArchive(store wtf.ZipStore, manifestData []byte, manifest shareManifest, trusted []string) error {
+	signature, err := wtf.ReadFile(store, shareSignatureName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("it isn't signed, and trusted_keys is set")
+	}
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(manifestData, signature, trusted); err != nil {
+		return err
+	}
+	for _, file := range store.Archive.File {
+		if file.Name == shareManifestName || file.Name == shareSignatureName || file.Mode().IsDir() {
+			continue
+		}
+		want, ok := manifest.Hashes[file.Name]
+		if !ok {
+			return fmt.Errorf("%s was added after it was signed", file.Name)
+		}
+		data, err := wtf.ReadFile(store, file.Name)
+		if err != nil {
+			return err
+		}
+		if hash := sha256.Sum256(data); hex.EncodeToString(hash[:]) != want {
+			return fmt.Errorf("%s was changed after it was signed", file.Name)
+		}
+	}
+	return nil
+}
+
+// the settings files a share can hold, anything else in one didn't come from share
+var _shareFileExtensions = map[string]bool{".lua": true, ".wtf": true, ".txt": true}
+
+// what's wrong with an entry of a downloaded share, if anything
+// never trust a download to stay inside the install it's copied into, or to only hold plain files
+func shareEntryProblem(file *zip.File) string {
+	name := filepath.FromSlash(strings.TrimSuffix(file.Name, "/"))
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(file.Name, "/") {
+		return fmt.Sprintf("it contains an absolute path: %s", file.Name)
+	}
+	if name != filepath.Clean(name) || strings.Contains(file.Name, `\`) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return fmt.Sprintf("it contains an unsafe path: %s", file.Name)
+	}
+	mode := file.Mode()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return fmt.Sprintf("it contains a link: %s", file.Name)
+	case mode.IsDir():
+		return ""
+	case !mode.IsRegular():
+		return fmt.Sprintf("%s isn't a regular file", file.Name)
+	}
+	if file.Name != shareManifestName && file.Name != shareSignatureName && !_shareFileExtensions[strings.ToLower(filepath.Ext(name))] {
+		return fmt.Sprintf("%s isn't a settings file a share would hold", file.Name)
+	}
+	return ""
+}
+
+// encrypts data with a fresh random AES-256-GCM key, the nonce is prepended to the result
+// the key only ever travels in the share code, never to the server
+func encryptShare(data []byte) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), key, nil
+}
+
+func decryptShare(data []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("the share is too short to be encrypted")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("the share couldn't be decrypted, check the code was copied completely")
+	}
+	return plain, nil
+}
+
+// uploads data to a paste-style endpoint and returns the code (or URL) it answers with
+func uploadShare(url string, data []byte) (string, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("uploading to %s: %s", url, resp.Status)
+	}
+	code := strings.TrimSpace(string(body))
+	if code == "" {
+		return "", fmt.Errorf("uploading to %s: the server didn't return a code", url)
+	}
+	return code, nil
+}
+
+// uploads a share of src, signed with signingKey if it's set and encrypted first if asked, and returns the code to hand out
+func shareProfile(wow WowInstall, src wtf.Target, url string, encrypt bool, signingKey ed25519.PrivateKey) (string, error) {
+	data, err := buildShareArchive(wow, src, signingKey)
+	if err != nil {
+		return "", err
+	}
+	var key []byte
+	if encrypt {
+		data, key, err = encryptShare(data)
+		if err != nil {
+			return "", err
+		}
+	}
+	code, err := uploadShare(url, data)
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		code += "#" + base64.RawURLEncoding.EncodeToString(key)
+	}
+	return code, nil
+}
+
+// downloads a share by the code shareProfile printed, either a full URL or an id relative to url
+// anything after a # is the decryption key
+func downloadShare(url string, code string) ([]byte, error) {
+	code, encodedKey, encrypted := strings.Cut(code, "#")
+	if !strings.HasPrefix(code, "http://") && !strings.HasPrefix(code, "https://") {
+		if url == "" {
+			return nil, errors.New("set share_url in config.yaml to import shares by code, or pass the full URL")
+		}
+		code = strings.TrimSuffix(url, "/") + "/" + code
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(code)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", code, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxShareSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxShareSize {
+		return nil, fmt.Errorf("downloading %s: the share is bigger than %s", code, formatBytes(maxShareSize))
+	}
+
+	if !encrypted {
+		return data, nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.New("the key at the end of the share code is invalid")
+	}
+	return decryptShare(data, key)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// signatures are written next to what they sign with this added to the name, e.g. presets.json.sig
+const signatureExtension = ".sig"
+
+// where the key share --sign and sign use lives, next to config.yaml, created on first use
+func signingKeyPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "signing.key"), nil
+}
+
+// the signing key, made (and saved, readable only by the user) the first time
+func loadSigningKey() (ed25519.PrivateKey, error) {
+	path, err := signingKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s isn't a signing key", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+	return key, os.WriteFile(path, []byte(encoded), 0600)
+}
+
+// how a public key is written in trusted_keys
+func encodePublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// signs data, returning the signature as it's saved
+func signData(key ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n")
+}
+
+// checks signature (as signData saves it) against every key in trusted, returning an error unless one made it
+func verifySignature(data []byte, signature []byte, trusted []string) error {
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || len(decoded) != ed25519.SignatureSize {
+		return errors.New("its signature is unreadable")
+	}
+	for _, encoded := range trusted {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("%q in trusted_keys isn't a public key", encoded)
+		}
+		if ed25519.Verify(ed25519.PublicKey(key), data, decoded) {
+			return nil
+		}
+	}
+	return errors.New("it isn't signed by any of the trusted_keys")
+}
+
+// writes path's signature next to it, for presets and other files handed out to be checked with trusted_keys
+// returns where the signature went and the public key to trust
+func signFile(path string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := loadSigningKey()
+	if err != nil {
+		return "", "", err
+	}
+	signaturePath := path + signatureExtension
+	return signaturePath, encodePublicKey(key), os.WriteFile(signaturePath, signData(key, data), 0644)
+}
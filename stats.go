@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// bumped whenever statsHistory changes in a way older files can't be read as
+const supportedStatsVersion = 1
+
+// samples older than this are dropped, a year is plenty to see a database creep up
+const statsKeptDays = 366
+
+// how many of the latest samples a trend is drawn from, so it fits next to the table
+const statsTrendWidth = 30
+
+// an addon counts as running away once it's grown by this much and by at least half of what it was
+const statsRunawayBytes = 1 << 20
+
+// the sizes of one install's WTF folders, measured after a copy or by du and stats
+type statsSample struct {
+	InstallDirectory string           `json:"install_directory"`
+	Taken            time.Time        `json:"taken"`
+	Total            int64            `json:"total"`
+	Scopes           map[string]int64 `json:"scopes"` // accounts and characters, as du names them
+	Addons           map[string]int64 `json:"addons"`
+}
+
+type statsHistory struct {
+	Version int           `json:"version"`
+	Samples []statsSample `json:"samples"`
+}
+
+func statsHistoryPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stats.json"), nil
+}
+
+// the recorded samples, oldest first, an unreadable or outdated history starts over
+func readStatsHistory() (statsHistory, error) {
+	path, err := statsHistoryPath()
+	if err != nil {
+		return statsHistory{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return statsHistory{Version: supportedStatsVersion}, nil
+	}
+	if err != nil {
+		return statsHistory{}, err
+	}
+	var history statsHistory
+	if err := json.Unmarshal(data, &history); err != nil || history.Version != supportedStatsVersion {
+		return statsHistory{Version: supportedStatsVersion}, nil
+	}
+	return history, nil
+}
+
+// measures wow and adds it to the history, a day keeps only its last sample
+// returns wow's samples, oldest first
+func recordStats(wow WowInstall) ([]statsSample, error) {
+	scopes, addons, err := wow.diskUsage()
+	if err != nil {
+		return nil, err
+	}
+	sample := statsSample{
+		InstallDirectory: wow.Dir,
+		Taken:            time.Now(),
+		Scopes:           make(map[string]int64),
+		Addons:           make(map[string]int64),
+	}
+	for _, usage := range scopes {
+		sample.Scopes[usage.name] = usage.bytes
+		sample.Total += usage.bytes
+	}
+	for _, usage := range addons {
+		sample.Addons[usage.name] = usage.bytes
+	}
+
+	history, err := readStatsHistory()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := sample.Taken.AddDate(0, 0, -statsKeptDays)
+	var kept, samples []statsSample
+	for _, old := range history.Samples {
+		sameDay := old.InstallDirectory == sample.InstallDirectory && old.Taken.Format("2006-01-02") == sample.Taken.Format("2006-01-02")
+		if sameDay || old.Taken.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, old)
+		if old.InstallDirectory == sample.InstallDirectory {
+			samples = append(samples, old)
+		}
+	}
+	history.Samples = append(kept, sample)
+
+	path, err := statsHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return append(samples, sample), nil
+}
+
+// a one line chart of values, like ▁▂▄█
+func sparkline(values []int64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	if len(values) > statsTrendWidth {
+		values = values[len(values)-statsTrendWidth:]
+	}
+	low, high := values[0], values[0]
+	for _, value := range values {
+		if value < low {
+			low = value
+		}
+		if value > high {
+			high = value
+		}
+	}
+	line := make([]rune, len(values))
+	for i, value := range values {
+		bar := 0
+		if high > low {
+			bar = int((value - low) * int64(len(bars)-1) / (high - low))
+		}
+		line[i] = bars[bar]
+	}
+	return string(line)
+}
+
+// a size change, like +1.2 MB
+func formatGrowth(change int64) string {
+	if change < 0 {
+		return "-" + formatBytes(-change)
+	}
+	return "+" + formatBytes(change)
+}
+
+// a table of how each name's size moved over samples, fastest growing first, runaway ones stand out
+func statsGrowthTable(header string, samples []statsSample, sizes func(statsSample) map[string]int64, limit int) [][]string {
+	first, last := sizes(samples[0]), sizes(samples[len(samples)-1])
+	type growth struct {
+		name   string
+		change int64
+	}
+	var growths []growth
+	for name, size := range last {
+		growths = append(growths, growth{name, size - first[name]})
+	}
+	sort.Slice(growths, func(i, j int) bool {
+		if growths[i].change != growths[j].change {
+			return growths[i].change > growths[j].change
+		}
+		return growths[i].name < growths[j].name
+	})
+
+	table := [][]string{{header, "Trend", samples[0].Taken.Format("2006-01-02"), "Now", "Growth"}}
+	for i, growth := range growths {
+		if i == limit || growth.change <= 0 {
+			break
+		}
+		var values []int64
+		for _, sample := range samples {
+			values = append(values, sizes(sample)[growth.name])
+		}
+		row := []string{growth.name, sparkline(values), formatBytes(first[growth.name]), formatBytes(last[growth.name]), formatGrowth(growth.change)}
+		if growth.change >= statsRunawayBytes && growth.change*2 >= first[growth.name] {
+			for i := range row {
+				row[i] = pterm.ThemeDefault.WarningMessageStyle.Sprint(row[i])
+			}
+		}
+		table = append(table, row)
+	}
+	return table
+}
+
+// records how big wow's WTF folders are now, and prints how they've grown since the oldest sample
+func printStats(wow WowInstall) error {
+	samples, err := recordStats(wow)
+	if err != nil {
+		return err
+	}
+	if len(samples) < 2 {
+		pterm.Info.Printfln("This is the first size recorded for %s (%s). Every copy, du, and stats adds one (one a day), check back after a few to see trends", wow.Dir, formatBytes(samples[0].Total))
+		return nil
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	var totals []int64
+	for _, sample := range samples {
+		totals = append(totals, sample.Total)
+	}
+	pterm.DefaultSection.Printfln("WTF size since %s", first.Taken.Format("2006-01-02"))
+	pterm.Printfln("%s  %s, %s to %s (%d samples)", sparkline(totals), formatGrowth(last.Total-first.Total), formatBytes(first.Total), formatBytes(last.Total), len(samples))
+
+	sections := []struct {
+		title  string
+		header string
+		sizes  func(statsSample) map[string]int64
+	}{
+		{"Fastest growing addons, counting their SavedVariables on every account and character", "Addon", func(s statsSample) map[string]int64 { return s.Addons }},
+		{"Fastest growing accounts and characters", "Account or character", func(s statsSample) map[string]int64 { return s.Scopes }},
+	}
+	for _, section := range sections {
+		table := statsGrowthTable(section.header, samples, section.sizes, duTopAddons)
+		if len(table) == 1 {
+			continue
+		}
+		pterm.DefaultSection.Println(section.title)
+		if err := pterm.DefaultTable.WithHasHeader().WithData(table).Render(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
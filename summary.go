@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// what summary can write a profile as
+const (
+	summaryMarkdown = "markdown"
+	summaryHTML     = "html"
+)
+
+// checks a --format value
+func validateSummaryFormat(format string) error {
+	switch format {
+	case summaryMarkdown, summaryHTML:
+		return nil
+	}
+	return fmt.Errorf("--format must be %s or %s, not %q", summaryMarkdown, summaryHTML, format)
+}
+
+// a readable overview of a character's profile, with nothing in it that identifies the account
+type profileSummary struct {
+	Character string
+	Version   string
+	Addons    []string
+	Macros    []string
+	Bindings  [][2]string // key, what it does
+	CVars     [][2]string // name, value
+}
+
+// reads src's enabled addons, macro names, keybindings, and CVars
+// the game only writes CVars that differ from their defaults to config-cache.wtf, so those are the changed ones
+func (wow WowInstall) summarizeProfile(src wtf.Target) (profileSummary, error) {
+	summary := profileSummary{Character: src.CharacterName(), Version: _wowInstanceFolderNames[src.Version]}
+	accountPath, characterPath := wow.AccountPath(src), wow.CharacterPath(src)
+	store := wow.Files()
+
+	err := scanLines(store, filepath.Join(characterPath, "AddOns.txt"), func(line string) {
+		if name, state, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(state) == "enabled" {
+			summary.Addons = append(summary.Addons, strings.TrimSpace(name))
+		}
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	for _, dir := range []string{accountPath, characterPath} {
+		err := scanLines(store, filepath.Join(dir, copyplan.MacrosFileName), func(line string) {
+			// MACRO <id> "<name>" <icon>
+			if !strings.HasPrefix(line, "MACRO ") {
+				return
+			}
+			if start, end := strings.Index(line, `"`), strings.LastIndex(line, `"`); start >= 0 && end > start {
+				summary.Macros = append(summary.Macros, line[start+1:end])
+			}
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	// character bindings replace the account's when there are any
+	bindingsPath := filepath.Join(accountPath, copyplan.BindingsFileName)
+	if _, err := store.Stat(filepath.Join(characterPath, copyplan.BindingsFileName)); err == nil {
+		bindingsPath = filepath.Join(characterPath, copyplan.BindingsFileName)
+	}
+	err = scanLines(store, bindingsPath, func(line string) {
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "bind" {
+			summary.Bindings = append(summary.Bindings, [2]string{fields[1], strings.Join(fields[2:], " ")})
+		}
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	// the character's CVars take precedence over the account's
+	cvarIndex := make(map[string]int)
+	for _, dir := range []string{accountPath, characterPath} {
+		err := scanLines(store, filepath.Join(dir, copyplan.ConfigCacheFileName), func(line string) {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.EqualFold(fields[0], "SET") {
+				return
+			}
+			cvar := [2]string{fields[1], strings.Trim(strings.Join(fields[2:], " "), `"`)}
+			if i, ok := cvarIndex[strings.ToLower(cvar[0])]; ok {
+				summary.CVars[i] = cvar
+				return
+			}
+			cvarIndex[strings.ToLower(cvar[0])] = len(summary.CVars)
+			summary.CVars = append(summary.CVars, cvar)
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	sort.Strings(summary.Addons)
+	sort.Strings(summary.Macros)
+	return summary, nil
+}
+
+// calls fn with every line of path in store, a missing file has no lines
+func scanLines(store wtf.Store, path string, fn func(string)) error {
+	file, err := store.Open(path)
+	if classifyFileError(err) == fileErrorNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fn(strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return scanner.Err()
+}
+
+// the summary as markdown, e.g. for a Discord post
+func (s profileSummary) markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s (%s)\n", s.Character, s.Version)
+
+	fmt.Fprintf(&out, "\n## Addons (%d)\n\n", len(s.Addons))
+	for _, addon := range s.Addons {
+		fmt.Fprintf(&out, "- %s\n", addon)
+	}
+	fmt.Fprintf(&out, "\n## Macros (%d)\n\n", len(s.Macros))
+	for _, macro := range s.Macros {
+		fmt.Fprintf(&out, "- %s\n", macro)
+	}
+	fmt.Fprintf(&out, "\n## Keybindings (%d)\n\n| Key | Action |\n| --- | --- |\n", len(s.Bindings))
+	for _, binding := range s.Bindings {
+		fmt.Fprintf(&out, "| `%s` | %s |\n", binding[0], escapeMarkdownCell(binding[1]))
+	}
+	fmt.Fprintf(&out, "\n## Changed settings (%d)\n\n| CVar | Value |\n| --- | --- |\n", len(s.CVars))
+	for _, cvar := range s.CVars {
+		fmt.Fprintf(&out, "| %s | `%s` |\n", cvar[0], escapeMarkdownCell(cvar[1]))
+	}
+	return out.String()
+}
+
+// keeps a value from splitting its markdown table cell
+func escapeMarkdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", `\|`)
+}
+
+var _summaryTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Character}} ({{.Version}})</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Character}} ({{.Version}})</h1>
+<h2>Addons ({{len .Addons}})</h2>
+<ul>{{range .Addons}}
+<li>{{.}}</li>{{end}}
+</ul>
+<h2>Macros ({{len .Macros}})</h2>
+<ul>{{range .Macros}}
+<li>{{.}}</li>{{end}}
+</ul>
+<h2>Keybindings ({{len .Bindings}})</h2>
+<table>
+<tr><th>Key</th><th>Action</th></tr>{{range .Bindings}}
+<tr><td><code>{{index . 0}}</code></td><td>{{index . 1}}</td></tr>{{end}}
+</table>
+<h2>Changed settings ({{len .CVars}})</h2>
+<table>
+<tr><th>CVar</th><th>Value</th></tr>{{range .CVars}}
+<tr><td>{{index . 0}}</td><td><code>{{index . 1}}</code></td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// the summary as a standalone HTML page
+func (s profileSummary) html() (string, error) {
+	var out bytes.Buffer
+	if err := _summaryTemplate.Execute(&out, s); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/wtf"
+)
+
+// the class tokens addons store, lowercased they're the tags detected classes get
+var _classTokens = map[string]bool{
+	"WARRIOR": true, "PALADIN": true, "HUNTER": true, "ROGUE": true, "PRIEST": true, "DEATHKNIGHT": true,
+	"SHAMAN": true, "MAGE": true, "WARLOCK": true, "MONK": true, "DRUID": true, "DEMONHUNTER": true, "EVOKER": true,
+}
+
+// a table keyed by a character, ["Name - Realm"] = {, and a class entry in it, ["class"] = "PRIEST",
+var _characterTablePattern = regexp.MustCompile(`^(\s*)\["([^"]+)"\] = \{\s*$`)
+var _classEntryPattern = regexp.MustCompile(`^\s*\["(?:class|englishClass|classFile|classFilename)"\] = "([A-Z]+)",`)
+
+// the classes of the account's characters, by Name-Realm, as far as the account's SavedVariables tell
+// addons like DataStore, Altoholic, and Details keep a table per character with its class token in it
+func (wow WowInstall) detectClasses(version string, account string) map[string]string {
+	// the keys each character's table can have
+	characters := make(map[string]string)
+	for _, character := range wow.getWtfConfigurations(version) {
+		if character.Account != account {
+			continue
+		}
+		name := wtf.Target{Character: character, Version: version}.CharacterName()
+		characters[character.Name+"-"+character.Server] = name
+		characters[character.Name+" - "+character.Server] = name
+		characters["Default."+character.Server+"."+character.Name] = name
+	}
+
+	classes := make(map[string]string)
+	dir := filepath.Join(wow.AccountPath(wtf.Target{Character: wtf.Character{Account: account}, Version: version}), "SavedVariables")
+	files, err := wow.Files().ReadDir(dir)
+	if err != nil {
+		return classes
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".lua") {
+			continue
+		}
+		reader, err := wow.Files().Open(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		// the character whose table we're in, and how far its entries are indented
+		var current, indent string
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if match := _characterTablePattern.FindStringSubmatch(line); match != nil && characters[match[2]] != "" {
+				current, indent = characters[match[2]], match[1]
+				continue
+			}
+			if current == "" {
+				continue
+			}
+			if strings.TrimSpace(line) == "}," && strings.TrimSuffix(line, "},") == indent {
+				current = ""
+				continue
+			}
+			if match := _classEntryPattern.FindStringSubmatch(line); match != nil && _classTokens[match[1]] && classes[current] == "" {
+				classes[current] = match[1]
+			}
+		}
+		reader.Close()
+	}
+	return classes
+}
+
+// target's tags: the ones in config's character_tags, and its class (lowercased, e.g. priest) when classes has it
+func (c Config) characterTags(target wtf.Target, classes map[string]string) []string {
+	var tags []string
+	for spec, specTags := range c.CharacterTags {
+		if targetMatchesSpec(spec, target) {
+			tags = append(tags, specTags...)
+		}
+	}
+	if class := classes[target.CharacterName()]; class != "" {
+		tags = append(tags, strings.ToLower(class))
+	}
+	return deduplicateStringSlice(tags)
+}
+
+// leaves out the files config's tagged_files only copies to characters with other tags than the destination's
+func placeTaggedFiles(p *copyplan.Plan, taggedFiles map[string][]string, tags []string) {
+	if len(taggedFiles) == 0 {
+		return
+	}
+	has := make(map[string]bool)
+	for _, tag := range tags {
+		has[strings.ToLower(tag)] = true
+	}
+
+	var steps []copyplan.Step
+	for _, step := range p.Steps {
+		if wanted := requiredTags(taggedFiles, filepath.Base(step.Src)); wanted != nil && !anyTag(has, wanted) {
+			p.Skipped = append(p.Skipped, copyplan.SkippedFile{Path: step.Src, Reason: fmt.Sprintf("it's only copied to characters tagged %s", strings.Join(wanted, " or "))})
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
+
+// the tags tagged_files asks of a file, nil when it's copied to everyone
+func requiredTags(taggedFiles map[string][]string, name string) []string {
+	var wanted []string
+	for pattern, tags := range taggedFiles {
+		if copyplan.MatchesAnyPattern(strings.ToLower(name), []string{strings.ToLower(pattern)}) {
+			wanted = append(wanted, tags...)
+		}
+	}
+	return wanted
+}
+
+// whether has holds any of wanted, ignoring case
+func anyTag(has map[string]bool, wanted []string) bool {
+	for _, tag := range wanted {
+		if has[strings.ToLower(tag)] {
+			return true
+		}
+	}
+	return false
+}
+
+// applies config's tagged_files to plans, tagging each destination with its configured and detected tags
+func (wow WowInstall) placeTaggedFiles(plans []copyplan.Plan, config Config) {
+	if len(config.TaggedFiles) == 0 {
+		return
+	}
+	classes := make(map[string]map[string]string)
+	for i, plan := range plans {
+		key := plan.Destination.Version + "/" + plan.Destination.Account
+		if classes[key] == nil {
+			classes[key] = wow.detectClasses(plan.Destination.Version, plan.Destination.Account)
+		}
+		tags := config.characterTags(plan.Destination, classes[key])
+		before := len(plans[i].Steps)
+		placeTaggedFiles(&plans[i], config.TaggedFiles, tags)
+		if left := before - len(plans[i].Steps); left > 0 && len(tags) == 0 {
+			pterm.Info.Printfln("Leaving %d tagged files out for %s, it has no tags", left, plan.Destination.CharacterName())
+		} else if left > 0 {
+			pterm.Info.Printfln("Leaving %d tagged files out for %s (tagged %s)", left, plan.Destination.CharacterName(), strings.Join(tags, ", "))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pterm/pterm"
+)
+
+// output themes, by the name used in config.yaml and --theme
+// pterm's printers all point at pterm.ThemeDefault, so replacing it restyles everything
+var _themes = map[string]func(base pterm.Theme) pterm.Theme{
+	"default": func(base pterm.Theme) pterm.Theme {
+		return base
+	},
+	// bold, bright foregrounds and solid prefix blocks that stay readable on any background
+	"high-contrast": func(base pterm.Theme) pterm.Theme {
+		base.DefaultText = pterm.Style{pterm.FgLightWhite}
+		base.PrimaryStyle = pterm.Style{pterm.Bold, pterm.FgLightWhite}
+		base.SecondaryStyle = pterm.Style{pterm.Bold, pterm.FgLightYellow}
+		base.HighlightStyle = pterm.Style{pterm.Bold, pterm.FgBlack, pterm.BgLightYellow}
+		base.InfoMessageStyle = pterm.Style{pterm.Bold, pterm.FgLightWhite}
+		base.InfoPrefixStyle = pterm.Style{pterm.Bold, pterm.FgBlack, pterm.BgLightWhite}
+		base.SuccessMessageStyle = pterm.Style{pterm.Bold, pterm.FgLightGreen}
+		base.SuccessPrefixStyle = pterm.Style{pterm.Bold, pterm.FgBlack, pterm.BgLightGreen}
+		base.WarningMessageStyle = pterm.Style{pterm.Bold, pterm.FgLightYellow}
+		base.WarningPrefixStyle = pterm.Style{pterm.Bold, pterm.FgBlack, pterm.BgLightYellow}
+		base.ErrorMessageStyle = pterm.Style{pterm.Bold, pterm.FgLightRed}
+		base.ErrorPrefixStyle = pterm.Style{pterm.Bold, pterm.FgLightWhite, pterm.BgRed}
+		base.HeaderTextStyle = pterm.Style{pterm.Bold, pterm.FgBlack}
+		base.HeaderBackgroundStyle = pterm.Style{pterm.BgLightWhite}
+		return base
+	},
+	// blue/orange instead of green/red, which deuteranopia and protanopia can't tell apart
+	"colorblind": func(base pterm.Theme) pterm.Theme {
+		base.InfoMessageStyle = pterm.Style{pterm.FgLightWhite}
+		base.InfoPrefixStyle = pterm.Style{pterm.FgBlack, pterm.BgLightWhite}
+		base.SuccessMessageStyle = pterm.Style{pterm.FgLightBlue}
+		base.SuccessPrefixStyle = pterm.Style{pterm.FgLightWhite, pterm.BgBlue}
+		base.WarningMessageStyle = pterm.Style{pterm.FgYellow}
+		base.WarningPrefixStyle = pterm.Style{pterm.FgBlack, pterm.BgYellow}
+		base.ErrorMessageStyle = pterm.Style{pterm.Bold, pterm.FgLightMagenta}
+		base.ErrorPrefixStyle = pterm.Style{pterm.FgLightWhite, pterm.BgMagenta}
+		base.FatalMessageStyle = base.ErrorMessageStyle
+		base.FatalPrefixStyle = base.ErrorPrefixStyle
+		return base
+	},
+	// handled by disabling color entirely in applyTheme
+	"monochrome": func(base pterm.Theme) pterm.Theme {
+		return base
+	},
+}
+
+// switches all output over to the named theme
+func applyTheme(name string) error {
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := _themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, choose one of: %v", name, themeNames())
+	}
+
+	pterm.ThemeDefault = theme(pterm.ThemeDefault)
+	if name == "monochrome" {
+		pterm.DisableColor()
+	}
+	return nil
+}
+
+func themeNames() []string {
+	var names []string
+	for name := range _themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
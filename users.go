@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"wow-profile-copy/wowinstall"
+)
+
+// where every OS user's home folder lives
+var _userHomeRoots = map[string]string{
+	"windows": `C:\Users`,
+	"darwin":  "/Users",
+	"linux":   "/home",
+}
+
+// an install found in some OS user's home folder
+type userInstall struct {
+	user string
+	path string
+}
+
+func (u userInstall) String() string {
+	return fmt.Sprintf("%s (%s)", u.path, u.user)
+}
+
+// looks for installs in every OS user's home folder, e.g. each family member's own wine prefix
+// only the per-user locations from wowinstall.ProbableLocations are checked, system-wide installs are shared anyway
+func findUserInstalls() []userInstall {
+	root, ok := _userHomeRoots[runtime.GOOS]
+	if !ok {
+		return nil
+	}
+	homes, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var installs []userInstall
+	for _, home := range homes {
+		if !home.IsDir() {
+			continue
+		}
+		homeDir := filepath.Join(root, home.Name())
+		for _, candidate := range wowinstall.ProbableLocations[runtime.GOOS] {
+			if !strings.HasPrefix(candidate, "~") {
+				continue
+			}
+			path := wowinstall.ExpandHome(candidate, homeDir)
+			if isWowInstallDirectory(path) {
+				installs = append(installs, userInstall{home.Name(), path})
+			}
+		}
+	}
+	return installs
+}
+
+// asks which of installs to use
+func promptForUserInstall(installs []userInstall, text string) string {
+	var choices []string
+	paths := make(map[string]string)
+	for _, install := range installs {
+		choices = append(choices, install.String())
+		paths[install.String()] = install.path
+	}
+	choice := askSelect("user-install", pterm.DefaultInteractiveSelect.
+		WithOptions(choices).
+		WithDefaultText(text).
+		WithMaxHeight(selectMaxHeight()))
+	return paths[choice]
+}
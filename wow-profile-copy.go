@@ -1,51 +1,39 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"github.com/pterm/pterm"
-	"io"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime"
+	"sort"
 	"strings"
-	"bytes"
+
+	"github.com/pterm/pterm"
 	// "github.com/pterm/pterm/putils"
+
+	"wow-profile-copy/copyplan"
+	"wow-profile-copy/internal/planfile"
+	"wow-profile-copy/wowinstall"
+	"wow-profile-copy/wtf"
 )
 
+// a WoW install as the CLI sees it
 type WowInstall struct {
-	availableVersions []string
-	installDirectory  string
-}
-
-type Wtf struct {
-	account   string
-	server    string
-	character string
-}
-
-type CopyTarget struct {
-	wtf     Wtf
-	version string
+	wowinstall.Install
+	preferredVersion string // the version folder prompts start on when none was picked before, from config.yaml
 }
 
 // smelly?
-var _wowInstanceFolderNames = map[string]string{
-	"_classic_":        "WoTLK Classic",
-	"_classic_ptr_":    "WoTLK Classic PTR",
-	"_classic_beta_":   "WoTLK Classic Beta",
-	"_classic_era_":    "Classic SoM",
-	"_classic_era_ptr": "Classic SoM PTR",
-	"_retail_":         "Retail",
-	"_ptr_":            "Retail PTR",
-}
+var _wowInstanceFolderNames = _defaults.Flavors
 
-var _probableWowInstallLocations = map[string]string{
-	"darwin":  "/Applications/World of Warcraft",
-	"windows": "C:\\World of Warcraft",
-}
+// account-level SavedVariables that aggregate data from every character on the account
+// copying these between different accounts clobbers the destination's other characters
+var _crossCharacterAccountSavedVariables = _defaults.CrossCharacterAccountSavedVariables
+
+// SavedVariables full of recorded combat and log upload history, huge and meaningless on another character
+// skipped unless --include-combat-logs is passed
+var _combatLogSavedVariables = _defaults.CombatLogSavedVariables
 
 //
 //
@@ -54,110 +42,71 @@ var _probableWowInstallLocations = map[string]string{
 //
 
 // Finds all valid WTF configs (account, server, character) for a given WoW version
-func (wow WowInstall) getWtfConfigurations(version string) []Wtf {
-	var configurations []Wtf
-
-	wtfPath := filepath.Join(wow.installDirectory, version, "WTF", "Account") // a fitting name
-
-	// enumerate available accounts on this instance
-	wtfFiles, err := os.ReadDir(wtfPath)
+func (wow WowInstall) getWtfConfigurations(version string) []wtf.Character {
+	characters, err := wow.Characters(version)
 	if err != nil {
-		log.Fatal(err)
+		fatal(explainFileError(err))
 	}
+	return characters
+}
 
-	// search all directories in WTF/Account
-	for _, acct := range wtfFiles {
-		if acct.IsDir() && acct.Name() != "SavedVariables" {
-			accountPath := filepath.Join(wtfPath, acct.Name())
-			serverFiles, err := os.ReadDir(accountPath) // enumerate available servers under each account
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, server := range serverFiles {
-				if server.IsDir() && server.Name() != "SavedVariables" { // assume that any folder that isn't SavedVariables here is a realm
-					serverPath := filepath.Join(accountPath, server.Name())
-					characterFiles, err := os.ReadDir(serverPath)
-					if err != nil {
-						log.Fatal(err)
-					}
-					for _, character := range characterFiles { // any subdirectories of the server directories are characters, they have arbitrary names
-						if character.IsDir() {
-							finalWtf := Wtf{
-								account:   acct.Name(),
-								server:    server.Name(),
-								character: character.Name(),
-							}
-							configurations = append(configurations, finalWtf)
-						}
-					}
-				}
-			}
-		}
+// lists every account folder for a given WoW version, including ones nobody has logged a character into yet
+func (wow WowInstall) getAccounts(version string) []string {
+	accounts, err := wow.Accounts(version)
+	if err != nil {
+		fatal(explainFileError(err))
 	}
-	return configurations
+	return accounts
 }
 
 // determines which WoW versions are available in a given WoW install directory (classic, retail, SoM, etc..)
 func (wow *WowInstall) findAvailableVersions(dir string) {
-	files, err := os.ReadDir(dir)
+	install, err := wowinstall.Open(dir, _wowInstanceFolderNames)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	for _, file := range files {
-		// if this directory contains a wow instance folder name, it's probably where WoW is installed
-		_, matchesInstanceName := _wowInstanceFolderNames[file.Name()]
-		if file.IsDir() && matchesInstanceName {
-			wow.availableVersions = append(wow.availableVersions, file.Name())
-		}
+		fatal(explainFileError(err))
 	}
+	wow.Install = install
 }
 
 // prompts the user to select a wow game version, and a WTF tuple to copy to/from
 // wtf tuples are (account, server, character)
 // isSource: whether we are selecting the source of the copy or the destination
-func (wow WowInstall) selectWtf(isSource bool) CopyTarget {
+// allowBulk: whether a destination can be every character on a realm, account, or version, returned with
+// bulkWildcard as its character (and server, and account)
+func (wow WowInstall) selectWtf(isSource bool, allowBulk bool) wtf.Target {
 	preposition := "to"
+	// source and destination remember their previous choices separately
+	role := "destination"
 	if isSource {
 		preposition = "from"
+		role = "source"
 	}
 
 	optionsHiddenText := "[Some options hidden, use arrow keys to reveal]"
-	const selectHeight = 15
 	var versions []string
 
 	//
 	// prompt for WoW version
 	//
 
-	for _, version := range wow.availableVersions {
+	for _, version := range wow.Versions {
 		versions = append(versions, version)
 	}
 
 	defaultText := fmt.Sprintf("WoW Version to copy %s", preposition)
 	// give the user an indication that they can scroll the selection window
-	if len(versions) > selectHeight {
+	if len(versions) > selectMaxHeight() {
 		defaultText = fmt.Sprintf("WoW Version to copy %s %s", preposition, optionsHiddenText)
 	}
 
-	wowVersion, _ := pterm.DefaultInteractiveSelect.
-		WithOptions(versions).
-		WithDefaultText(defaultText).
-		WithMaxHeight(selectHeight).
-		Show()
+	wowVersion := selectRemembered(role+".version", versions, defaultText, wow.preferredVersion)
 	pterm.Debug.Printfln("chose %s", wowVersion)
 
-	// validate that the chosen wow version actually has configurations to copy from/to
+	// validate that the chosen wow version actually has configurations to copy from
 	// wtf configs are only generated when you login to a character
 	wtfConfigs := wow.getWtfConfigurations(wowVersion)
-	if len(wtfConfigs) == 0 {
-		pterm.Error.Printfln("No valid WTF configurations found in %s. Try logging into a character on this version of the client, first!", wowVersion)
-		if runtime.GOOS == "windows" {
-			// make windows users feel at home
-			fmt.Println("Press Enter to continue...")
-			fmt.Scanln()
-		}
-		os.Exit(1)
+	if isSource && len(wtfConfigs) == 0 {
+		fatalf("No valid WTF configurations found in %s. Try logging into a character on this version of the client, first!", wowVersion)
 	}
 
 	//
@@ -165,22 +114,51 @@ func (wow WowInstall) selectWtf(isSource bool) CopyTarget {
 	//
 
 	var accountOptions []string
-	for _, wtf := range wtfConfigs {
-		accountOptions = append(accountOptions, wtf.account)
+	if isSource {
+		for _, character := range wtfConfigs {
+			accountOptions = append(accountOptions, character.Account)
+		}
+	} else {
+		// destinations can be brand new accounts that only have account-level files so far
+		accountOptions = wow.getAccounts(wowVersion)
 	}
 	accountOptions = deduplicateStringSlice(accountOptions)
+	if len(accountOptions) == 0 {
+		fatalf("No accounts found in %s. Log into this version of the client once, first!", wowVersion)
+	}
 
-	if len(accountOptions) > selectHeight {
+	if len(accountOptions) > selectMaxHeight() {
 		defaultText = fmt.Sprintf("Account to copy %s %s", preposition, optionsHiddenText)
 	} else {
 		defaultText = fmt.Sprintf("Account to copy %s", preposition)
 	}
 
-	chosenAccount, _ := pterm.DefaultInteractiveSelect.
-		WithOptions(accountOptions).
-		WithDefaultText(defaultText).
-		WithMaxHeight(selectHeight).
-		Show()
+	// same-named realms exist in several regions, so show which one each account plays on
+	var accountLabels []string
+	accountsByLabel := make(map[string]string)
+	for _, account := range accountOptions {
+		label := wow.accountLabel(wowVersion, account)
+		accountLabels = append(accountLabels, label)
+		accountsByLabel[label] = account
+	}
+
+	// multiboxers copy to characters on several accounts at once
+	if allowBulk && len(accountLabels) > 1 {
+		accountLabels = append(accountLabels, bulkVersionOption)
+	}
+
+	chosenAccountLabel := selectRemembered(role+".account", accountLabels, defaultText, "")
+	if chosenAccountLabel == bulkVersionOption {
+		return wtf.Target{
+			Character: wtf.Character{
+				Account: bulkWildcard,
+				Server:  bulkWildcard,
+				Name:    bulkWildcard,
+			},
+			Version: wowVersion,
+		}
+	}
+	chosenAccount := accountsByLabel[chosenAccountLabel]
 	pterm.Debug.Printfln("chose %s", chosenAccount)
 
 	//
@@ -188,58 +166,116 @@ func (wow WowInstall) selectWtf(isSource bool) CopyTarget {
 	//
 
 	var serverOptions []string
-	for _, wtf := range wtfConfigs {
-		if wtf.account == chosenAccount {
-			serverOptions = append(serverOptions, wtf.server)
+	for _, character := range wtfConfigs {
+		if character.Account == chosenAccount {
+			serverOptions = append(serverOptions, character.Server)
 		}
 	}
 	serverOptions = deduplicateStringSlice(serverOptions)
+	if !isSource {
+		serverOptions = append(serverOptions, newCharacterOption)
+		if allowBulk && len(serverOptions) > 1 {
+			serverOptions = append(serverOptions, bulkAccountOption)
+		}
+	}
 
-	if len(serverOptions) > selectHeight {
+	if len(serverOptions) > selectMaxHeight() {
 		defaultText = fmt.Sprintf("Server to copy %s %s", preposition, optionsHiddenText)
 	} else {
 		defaultText = fmt.Sprintf("Server to copy %s", preposition)
 	}
 
-	chosenServer, _ := pterm.DefaultInteractiveSelect.
-		WithOptions(serverOptions).
-		WithDefaultText(fmt.Sprintf("Server to copy %s", preposition)).
-		WithMaxHeight(selectHeight).
-		Show()
+	chosenServer := newCharacterOption
+	if len(serverOptions) > 1 || isSource {
+		chosenServer = selectRemembered(role+".server", serverOptions, defaultText, "")
+	}
 	pterm.Debug.Printfln("chose %s", chosenServer)
 
+	if chosenServer == bulkAccountOption {
+		return wtf.Target{
+			Character: wtf.Character{
+				Account: chosenAccount,
+				Server:  bulkWildcard,
+				Name:    bulkWildcard,
+			},
+			Version: wowVersion,
+		}
+	}
+
+	if chosenServer == newCharacterOption {
+		server, character := promptForNewCharacter("")
+		return wtf.Target{
+			Character: wtf.Character{
+				Account: chosenAccount,
+				Server:  server,
+				Name:    character,
+			},
+			Version: wowVersion,
+		}
+	}
+
 	//
 	// prompt for character
 	//
 
 	var characterOptions []string
-	for _, wtf := range wtfConfigs {
-		if wtf.account == chosenAccount && wtf.server == chosenServer {
-			characterOptions = append(characterOptions, wtf.character)
+	for _, character := range wtfConfigs {
+		if character.Account == chosenAccount && character.Server == chosenServer {
+			characterOptions = append(characterOptions, character.Name)
+		}
+	}
+	if !isSource {
+		characterOptions = append(characterOptions, newCharacterOption)
+		if allowBulk {
+			characterOptions = append(characterOptions, bulkRealmOption)
 		}
 	}
 
-	if len(characterOptions) > selectHeight {
+	if len(characterOptions) > selectMaxHeight() {
 		defaultText = fmt.Sprintf("Character to copy %s %s", preposition, optionsHiddenText)
 	} else {
 		defaultText = fmt.Sprintf("Character to copy %s", preposition)
 	}
 
-	chosenCharacter, _ := pterm.DefaultInteractiveSelect.
-		WithOptions(characterOptions).
-		WithDefaultText(defaultText).
-		WithMaxHeight(selectHeight).
-		Show()
+	chosenCharacter := selectRemembered(role+".character", characterOptions, defaultText, "")
 	pterm.Debug.Printfln("chose %s", chosenCharacter)
 
-	return CopyTarget{
-		wtf: Wtf{
-			account:   chosenAccount,
-			server:    chosenServer,
-			character: chosenCharacter,
+	if chosenCharacter == newCharacterOption {
+		chosenServer, chosenCharacter = promptForNewCharacter(chosenServer)
+	}
+	if chosenCharacter == bulkRealmOption {
+		chosenCharacter = bulkWildcard
+	}
+
+	return wtf.Target{
+		Character: wtf.Character{
+			Account: chosenAccount,
+			Server:  chosenServer,
+			Name:    chosenCharacter,
 		},
-		version: wowVersion,
+		Version: wowVersion,
+	}
+}
+
+// the select entry for copying to a character that has no WTF folder yet
+const newCharacterOption = "[New character - create its folders]"
+
+// asks for the realm and name of a character whose WTF folders don't exist yet
+// if server is set, only the character name is asked for
+func promptForNewCharacter(server string) (string, string) {
+	for server == "" {
+		server = askText("new-character.server", pterm.DefaultInteractiveTextInput.
+			WithDefaultText("Server (realm) name, exactly as WoW spells it"))
+		server = strings.TrimSpace(server)
+	}
+
+	var character string
+	for character == "" {
+		character = askText("new-character.name", pterm.DefaultInteractiveTextInput.
+			WithDefaultText(fmt.Sprintf("Character name on %s", server)))
+		character = strings.TrimSpace(character)
 	}
+	return server, character
 }
 
 //
@@ -250,48 +286,88 @@ func (wow WowInstall) selectWtf(isSource bool) CopyTarget {
 
 // determines if a given path appears to contain a WoW install
 func isWowInstallDirectory(dir string) bool {
-	var isInstallDir = false
+	return wowinstall.IsInstallDirectory(dir, _wowInstanceFolderNames)
+}
 
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		// directory probably doesn't exist
-		return false
+// asks whether the user wants to paste the install path or browse to it, starting the browser at base
+func chooseWowDirectory(base string) (wowDir string, err error) {
+	const typeOption = "Type or paste the install path"
+	const browseOption = "Browse for the install directory"
+
+	method := askSelect("install.method", pterm.DefaultInteractiveSelect.
+		WithOptions([]string{typeOption, browseOption}).
+		WithDefaultText("How do you want to find your WoW install?"))
+	if method == browseOption {
+		return promptForWowDirectory(base)
 	}
 
-	for _, file := range files {
-		// if this directory contains a wow instance folder name, it's probably where WoW is installed
-		_, matchesInstanceName := _wowInstanceFolderNames[file.Name()]
-		if file.IsDir() && matchesInstanceName {
-			isInstallDir = true
-			break
+	for {
+		typedPath := askText("install.path", pterm.DefaultInteractiveTextInput.
+			WithDefaultText("WoW install path (the folder containing _retail_, _classic_, etc), leave empty to browse instead"))
+		typedPath = strings.Trim(strings.TrimSpace(typedPath), `"`)
+		if typedPath == "" {
+			return promptForWowDirectory(base)
 		}
+		typedPath = filepath.Clean(typedPath)
+		if isWowInstallDirectory(typedPath) {
+			return typedPath, nil
+		}
+		pterm.Warning.Printfln("%s doesn't look like a WoW install", typedPath)
 	}
-
-	return isInstallDir
 }
 
+// lets the user browse the filesystem, starting at dir, until they pick a WoW install
+// only directories are listed, sorted by name, with anything that looks like a WoW install flagged
 func promptForWowDirectory(dir string) (wowDir string, err error) {
+	const goBackOption = ".. (go back)"
+	const typePathOption = "[Type a path]"
+	const wowInstallMarker = " <- WoW install"
+
 	files, err := os.ReadDir(dir)
 	if err != nil {
 		return "", err
 	}
 
-	var fileChoices = []string{".. (go back)"}
-
+	var directories []string
 	for _, file := range files {
-		fileChoices = append(fileChoices, file.Name())
+		if file.IsDir() {
+			directories = append(directories, file.Name())
+		}
+	}
+	sort.Slice(directories, func(i, j int) bool {
+		return strings.ToLower(directories[i]) < strings.ToLower(directories[j])
+	})
+
+	var fileChoices = []string{goBackOption, typePathOption}
+	choicePaths := make(map[string]string)
+	for _, directory := range directories {
+		choice := directory
+		if isWowInstallDirectory(filepath.Join(dir, directory)) {
+			choice += wowInstallMarker
+		}
+		fileChoices = append(fileChoices, choice)
+		choicePaths[choice] = directory
 	}
 
-	selectedFile, _ := pterm.DefaultInteractiveSelect.
+	selectedFile := askSelect("install.browse", pterm.DefaultInteractiveSelect.
 		WithOptions(fileChoices).
-		WithDefaultText("Select a WoW Install directory").
-		WithMaxHeight(15).
-		Show()
+		WithDefaultText(fmt.Sprintf("Select a WoW Install directory (in %s)", dir)).
+		WithMaxHeight(selectMaxHeight()))
 	var fullSelectedPath string
-	if selectedFile == ".. (go back)" {
+	switch selectedFile {
+	case goBackOption:
 		fullSelectedPath = filepath.Clean(filepath.Join(dir, ".."))
-	} else {
-		fullSelectedPath = filepath.Join(dir, selectedFile)
+	case typePathOption:
+		typedPath := askText("install.browse-path", pterm.DefaultInteractiveTextInput.
+			WithDefaultText("Path to browse to"))
+		typedPath = strings.Trim(strings.TrimSpace(typedPath), `"`)
+		if info, err := os.Stat(typedPath); err != nil || !info.IsDir() {
+			pterm.Warning.Printfln("%s is not a directory", typedPath)
+			return promptForWowDirectory(dir)
+		}
+		fullSelectedPath = filepath.Clean(typedPath)
+	default:
+		fullSelectedPath = filepath.Join(dir, choicePaths[selectedFile])
 	}
 	isWowDir := isWowInstallDirectory(fullSelectedPath)
 	if !isWowDir {
@@ -301,6 +377,17 @@ func promptForWowDirectory(dir string) (wowDir string, err error) {
 	}
 }
 
+// returns the names of any SavedVariables files matching _crossCharacterAccountSavedVariables
+func findCrossCharacterSavedVariables(files []fs.DirEntry) []string {
+	var matches []string
+	for _, file := range files {
+		if copyplan.MatchesAnyPattern(file.Name(), _crossCharacterAccountSavedVariables) {
+			matches = append(matches, file.Name())
+		}
+	}
+	return matches
+}
+
 // deduplicates slices by throwing them into a map
 // not mine, credit to @kylewbanks
 func deduplicateStringSlice(input []string) []string {
@@ -315,209 +402,752 @@ func deduplicateStringSlice(input []string) []string {
 	return u
 }
 
-// small wrapper around os and io to copy files from source to destination
-func copyFile(src string, dest string) (bytes int64, err error) {
-	srcFileHandle, err := os.Open(src)
-	if err != nil {
-		return -1, err
+// finds the WoW install from --install-dir, the one confirmed last time, the usual locations, or by asking
+func resolveInstall(installDir string, config Config, interactive bool) WowInstall {
+	var wow WowInstall
+	var installLocation string
+	base := "/"
+
+	// scripted answers are given in the order the prompts come, so those runs always go through the same ones
+	remembered := loadRememberedChoices()[installChoiceKey]
+	if installDir != "" {
+		installLocation = filepath.Clean(installDir)
+		if !isWowInstallDirectory(installLocation) {
+			fatalf("%s doesn't look like a WoW install, it should contain folders like _retail_ or _classic_", installLocation)
+		}
+	} else if !_rescanInstall && _answers == nil && remembered != "" && isWowInstallDirectory(remembered) {
+		installLocation = remembered
+		pterm.Info.Printfln("Using the install confirmed last time, %s (pass --rescan to look for it again)", installLocation)
+	} else {
+		drives := listDrives()
+		var dirOk bool
+		var driveRoots []string
+		for _, drive := range drives {
+			driveRoots = append(driveRoots, drive.root)
+		}
+		installLocation, dirOk = wowinstall.Find(wowinstall.Candidates(config.InstallSearchPaths, driveRoots), _wowInstanceFolderNames)
+		if !dirOk && !interactive {
+			fatalf("Couldn't find a WoW install, pass its location with --install-dir")
+		}
+		if !dirOk {
+			if len(drives) > 0 {
+				base = promptForDrive(drives)
+			}
+			installLocation, _ = chooseWowDirectory(base)
+		}
+
+		pterm.Success.Printfln("Found WoW install. Location: %s", installLocation)
+
+		if interactive {
+			dirConfirm := askConfirm("install.confirm", pterm.DefaultInteractiveConfirm.
+				WithDefaultText("Is this directory correct?").
+				WithDefaultValue(true))
+			if !dirConfirm {
+				installLocation, _ = chooseWowDirectory(base)
+			}
+			if _answers == nil {
+				rememberChoice(installChoiceKey, installLocation)
+			}
+		}
 	}
-	defer srcFileHandle.Close()
 
-	dstFileHandle, err := os.Create(dest)
+	wow.preferredVersion = resolveVersionName(config.Version)
+	wow.findAvailableVersions(installLocation)
+	return wow
+}
+
+// the remembered choice holding the install confirmed at the last run's prompts
+const installChoiceKey = "install"
+
+// --rescan, look for the install again instead of using the one confirmed last time
+var _rescanInstall bool
+
+// picks the character to copy from, from --src or by asking
+func resolveSource(wow WowInstall, srcSpec string) wtf.Target {
+	if srcSpec == "" {
+		pterm.Info.Println("First, pick the Version, Account, Server, and Character to copy configuration data from.")
+		return wow.selectWtf(true, false)
+	}
+	srcConfig, err := parseCopyTarget(srcSpec, wow, false)
 	if err != nil {
-		return -1, err
+		fatal(err)
 	}
-	defer dstFileHandle.Close()
+	return srcConfig
+}
 
-	bytes, err = io.Copy(dstFileHandle, srcFileHandle)
-	return bytes, err
+// picks the character to copy to, from --dst or by asking
+// folders that would be created with names windows can't handle are warned about
+func resolveDestination(wow WowInstall, dstSpec string, create bool) wtf.Target {
+	var dstConfig wtf.Target
+	if dstSpec == "" {
+		pterm.Info.Println("Next, pick the Version, Account, Server, and Character to apply that configuration data to.")
+		dstConfig = wow.selectWtf(false, false)
+	} else {
+		var err error
+		dstConfig, err = parseCopyTarget(dstSpec, wow, create)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	return checkDestinationName(wow, dstConfig)
 }
 
-func main() {
-	var wow WowInstall
+// warns about destination folders that would be created with names windows can't handle
+func checkDestinationName(wow WowInstall, dstConfig wtf.Target) wtf.Target {
+	for _, problem := range wow.newCharacterFolderProblems(dstConfig) {
+		pterm.Warning.Printfln("Check the destination's name: %s", problem)
+	}
+	return dstConfig
+}
+
+// prints where we're copying from and to, warning about copies that can't be fully translated
+func describeTargets(srcWow WowInstall, dstWow WowInstall, srcConfig wtf.Target, dstConfig wtf.Target) {
+	srcRegion, dstRegion := srcWow.targetRegion(srcConfig), dstWow.targetRegion(dstConfig)
+	pterm.Info.Printfln("Source: { Version: %s, Region: %s, Account: %s, Server: %s, Character: %s }", _wowInstanceFolderNames[srcConfig.Version], srcRegion, srcConfig.Account, srcConfig.Server, srcConfig.Name)
+	pterm.Info.Printfln("Destination: { Version: %s, Region: %s, Account: %s, Server: %s, Character: %s }", _wowInstanceFolderNames[dstConfig.Version], dstRegion, dstConfig.Account, dstConfig.Server, dstConfig.Name)
+
+	// "Name-Realm" keys carry no region, so a same-named realm in another region is indistinguishable once copied
+	if srcRegion != dstRegion && srcRegion != "unknown" && dstRegion != "unknown" {
+		pterm.Warning.Printfln("Copying from a %s account to a %s account. SavedVariables entries for %s realms are kept as-is, and will look like %s realms of the same name on the destination.", srcRegion, dstRegion, srcRegion, dstRegion)
+	}
+}
 
-	userHomeDir, err := os.UserHomeDir()
+// works out the copy plan, asking which risky account SavedVariables to copy anyway when there's a terminal
+// opts says what to pick up, the risky account SavedVariables to skip are filled in here
+func resolvePlan(srcWow WowInstall, dstWow WowInstall, srcConfig wtf.Target, dstConfig wtf.Target, copyOptions copyplan.CopyOptions, opts copyplan.Options, interactive bool) copyplan.Plan {
+	accountSavedVariablesFiles, err := srcWow.Files().ReadDir(filepath.Join(srcWow.AccountPath(srcConfig), "SavedVariables"))
 	if err != nil {
-		log.Fatal(err)
+		fatal(explainFileError(err))
 	}
 
-	_probableWowInstallLocations["linux"] = fmt.Sprintf("%s/.var/app/com.usebottles.bottles/data/bottles/bottles/WoW/drive_c/Program Files (x86)/World of Warcraft", userHomeDir)
+	// aggregate SVs are only safe to overwrite when they stay on the same account
+	skippedAccountSavedVariables := make(map[string]bool)
+	// --character-only leaves every account file alone anyway
+	if copyOptions.SkipRisky && srcConfig.Account != dstConfig.Account && !opts.CharacterOnly {
+		riskyFiles := findCrossCharacterSavedVariables(accountSavedVariablesFiles)
+		if len(riskyFiles) > 0 {
+			pterm.Warning.Printfln("Source and destination accounts differ. These account-level SavedVariables hold data for every character on the account:\n%s", strings.Join(riskyFiles, "\n"))
+			var copyAnyway []string
+			if interactive {
+				unselected := "unselected files are skipped"
+				if opts.ExtractCharacterData {
+					unselected = fmt.Sprintf("only %s's own data is merged from unselected files", srcConfig.CharacterName())
+				}
+				copyAnyway = askMultiselect("copy-anyway", pterm.DefaultInteractiveMultiselect.
+					WithOptions(riskyFiles).
+					WithDefaultText(fmt.Sprintf("Select any of these to copy anyway (%s)", unselected)).
+					WithMaxHeight(selectMaxHeight()))
+			}
+			for _, file := range riskyFiles {
+				skippedAccountSavedVariables[file] = true
+			}
+			for _, file := range copyAnyway {
+				delete(skippedAccountSavedVariables, file)
+			}
+		}
+	}
 
-	// this will crash when not on linux, macOS, or windows
-	// if you're trying to run wow on BSD or plan9, you can probably fix this yourself
-	installLocation := _probableWowInstallLocations[runtime.GOOS]
-	base := "/"
+	opts.SkippedAccountSavedVariables = skippedAccountSavedVariables
+	plan, err := copyplan.Build(srcWow.Install, dstWow.Install, srcConfig, dstConfig, currentDefaults(), opts)
+	if err != nil {
+		fatal(explainFileError(err))
+	}
+	// retail<->classic differences are reviewed together, instead of turning up one warning at a time
+	if interactive {
+		dstWow.reviewFlavorIncompatibilities(&plan)
+	}
+	if len(plan.Extractions) > 0 {
+		var names []string
+		for _, step := range plan.Extractions {
+			names = append(names, filepath.Base(step.Src))
+		}
+		pterm.Info.Printfln("%s's own entries in these account SavedVariables are merged in under %s's name, every other character's are kept: %s", srcConfig.CharacterName(), dstConfig.CharacterName(), strings.Join(names, ", "))
+	}
+	for path, names := range plan.OverflowingMacros() {
+		pterm.Warning.Printfln("%s has fewer macro slots, these %d macros won't fit in %s and are left out: %s", _wowInstanceFolderNames[dstConfig.Version], len(names), path, strings.Join(names, ", "))
+	}
+	if long := longDestinationPaths(plan); len(long) > 0 {
+		pterm.Warning.Printfln("These destination paths are longer than windows allows (%d characters), consider moving the install closer to the drive root:\n%s", windowsMaxPath, strings.Join(long, "\n"))
+	}
+	return plan
+}
 
-	dirOk := isWowInstallDirectory(installLocation)
-	if !dirOk {
-		if runtime.GOOS == "windows" {
-			baseInput, _ := pterm.DefaultInteractiveTextInput.
-				WithDefaultText("Which drive is WoW located on? e.g. C, D").
-				Show()
-			base = fmt.Sprintf("%s:\\", string(baseInput[0]))
+// confirms (unless yes) and carries out plans, exiting when done
+// several plans (one per destination) share a single pass over their source files
+// swap, if set, turns a plan around and is offered at the confirmation
+func executePlans(wow WowInstall, plans []copyplan.Plan, copyOptions copyplan.CopyOptions, config Config, yes bool, notifyWhenDone bool, launch bool, interactive bool, swap func(copyplan.Plan) copyplan.Plan) {
+	// nothing is written anywhere but the destination's account folder, whatever the plan says
+	for _, plan := range plans {
+		if problems := wow.escapingPaths(plan); len(problems) > 0 {
+			fatalf("Refusing to copy to %s, it would write outside its account folder:\n%s", plan.Destination.CharacterName(), strings.Join(problems, "\n"))
 		}
-		installLocation, _ = promptForWowDirectory(base)
 	}
 
-	pterm.Success.Printfln("Found WoW install. Location: %s", installLocation)
+	// --yes isn't enough for a protected character, its name has to be typed
+	for _, plan := range plans {
+		if !config.isProtected(plan.Destination) {
+			continue
+		}
+		name := plan.Destination.Name
+		if !interactive {
+			path, _ := configFilePath()
+			fatalf("%s-%s is protected in %s, copying onto it needs its name typed at the prompt", name, plan.Destination.Server, path)
+		}
+		typed := askText("protected", pterm.DefaultInteractiveTextInput.
+			WithDefaultText(fmt.Sprintf("%s-%s is protected. Type %s to overwrite it anyway", name, plan.Destination.Server, name)))
+		if !strings.EqualFold(strings.TrimSpace(typed), name) {
+			pterm.Error.Printfln("That's not %s, nothing was copied", name)
+			exit(1)
+		}
+	}
 
-	dirConfirm, _ := pterm.DefaultInteractiveConfirm.
-		WithDefaultText("Is this directory correct?").
-		WithDefaultValue(true).
-		Show()
-	if !dirConfirm {
-		installLocation, _ = promptForWowDirectory(base)
+	copiers := make([]*copyplan.Copier, len(plans))
+	var destinations []string
+	var totalFiles int
+	var totalBytes int64
+	for i := range plans {
+		copier, err := newCopier(copyOptions, wow.Dir)
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if i > 0 {
+			// one run, one backup folder
+			copier.BackupDirectory = copiers[0].BackupDirectory
+		}
+		copier.Source = plans[i].SourceFiles()
+		copiers[i] = copier
+
+		// without a terminal conflicts are reported and the copy goes ahead as configured
+		if conflicts := wow.findConflicts(plans[i]); len(conflicts) > 0 {
+			if interactive {
+				applyConflictResolutions(&plans[i], promptForConflictResolutions(conflicts), copier)
+			} else {
+				for _, conflict := range conflicts {
+					pterm.Warning.Printfln("Overwriting %s even though %s", conflict.step.Dst, strings.Join(conflict.reasons, ", "))
+				}
+			}
+		}
+
+		destination := fmt.Sprintf("%s-%s", plans[i].Destination.Name, plans[i].Destination.Server)
+		destinations = append(destinations, destination)
+		if len(plans) > 1 {
+			pterm.DefaultSection.Println(destination)
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(planSummaryTable(plans[i])).Render()
+		totalFiles += len(plans[i].Steps)
+		totalBytes += plans[i].TotalBytes()
 	}
 
-	wow.installDirectory = installLocation
-	wow.findAvailableVersions(installLocation)
+	destination := strings.Join(destinations, ", ")
+	owner := destination + "'s"
+	if len(plans) > 1 {
+		owner = fmt.Sprintf("%d characters' (%s)", len(plans), destination)
+	}
+	contents := describeCopyContents(plans)
+	confirmText := fmt.Sprintf("Overwrite %s %s (%d files, %s)?\nThis can cause data loss - make a backup if unsure!", owner, contents, totalFiles, formatBytes(totalBytes))
+	if copyOptions.Backup {
+		confirmText = fmt.Sprintf("Overwrite %s %s (%d files, %s)?\nTheir account and character files will be backed up to %s first", owner, contents, totalFiles, formatBytes(totalBytes), copiers[0].BackupDirectory)
+	}
 
-	pterm.DefaultHeader.Printfln("WoW Install Directory: %s", wow.installDirectory)
+	// a destination that was never logged into has nothing to copy back
+	canSwap := false
+	if swap != nil && len(plans) == 1 {
+		_, err := os.Stat(wow.CharacterPath(plans[0].Destination))
+		canSwap = err == nil
+	}
 
-	pterm.Info.Println("First, pick the Version, Account, Server, and Character to copy configuration data from.")
-	srcConfig := wow.selectWtf(true)
-	pterm.Info.Println("Next, pick the Version, Account, Server, and Character to apply that configuration data to.")
-	dstConfig := wow.selectWtf(false)
+	// at a terminal the files being copied can be read first, scripted answers stick to yes or no
+	canPreview := interactive && _answers == nil
+	for !yes && (canSwap || canPreview) {
+		const copyOption = "Yes, copy"
+		const swapOption = "Swap source and destination"
+		const previewOption = "Preview the settings files first"
+		const cancelOption = "No, cancel"
+		options := []string{copyOption}
+		if canSwap {
+			options = append(options, swapOption)
+		}
+		if canPreview {
+			options = append(options, previewOption)
+		}
+		printer := pterm.DefaultInteractiveSelect.
+			WithOptions(append(options, cancelOption)).
+			WithDefaultText(confirmText)
+		printer.TextStyle = &pterm.ThemeDefault.WarningMessageStyle
+		switch askSelect("confirm", printer) {
+		case swapOption:
+			executePlans(wow, []copyplan.Plan{swap(plans[0])}, copyOptions, config, yes, notifyWhenDone, launch, interactive, swap)
+			return
+		case previewOption:
+			wow.previewPlanFiles(plans)
+			continue
+		case cancelOption:
+			exit(1)
+		}
+		break
+	}
+	if !yes && !canSwap && !canPreview {
+		confirmation := askConfirm("confirm", pterm.DefaultInteractiveConfirm.
+			WithTextStyle(&pterm.ThemeDefault.WarningMessageStyle).
+			WithDefaultText(confirmText))
+		if !confirmation {
+			exit(1)
+		}
+	}
 
-	pterm.Info.Printfln("Source: { Version: %s, Account: %s, Server: %s, Character: %s }", _wowInstanceFolderNames[srcConfig.version], srcConfig.wtf.account, srcConfig.wtf.server, srcConfig.wtf.character)
-	pterm.Info.Printfln("Destination: { Version: %s, Account :%s, Server: %s, Character: %s }", _wowInstanceFolderNames[dstConfig.version], dstConfig.wtf.account, dstConfig.wtf.server, dstConfig.wtf.character)
+	lock, err := acquireInstallLock(wow.Dir)
+	if err != nil {
+		fatal(explainFileError(err))
+	}
+	onExit(func(int) { lock.release() })
 
-	confirmation, _ := pterm.DefaultInteractiveConfirm.
-		WithTextStyle(&pterm.ThemeDefault.WarningMessageStyle).
-		WithDefaultText(fmt.Sprintf("Overwrite %s-%s's Keybindings, Macros, and SavedVariables?\nThis can cause data loss - make a backup if unsure!", dstConfig.wtf.character, dstConfig.wtf.server)).
-		Show()
-	if !confirmation {
-		os.Exit(1)
+	// several destinations report side by side, so their lines are tagged with the one they're about
+	if err := openRunLog(); err != nil {
+		pterm.Warning.Printfln("Couldn't start a log file for this copy: %s", err)
+	}
+	_runLog.tagTerminal = len(plans) > 1
+	for _, plan := range plans {
+		_runLog.printf(pterm.Info, plan.Destination.CharacterName(), "Copying %s's %s profile", plan.Source.CharacterName(), _wowInstanceFolderNames[plan.Source.Version])
 	}
 
-	//
-	// account-level client configuration
-	//
+	if notifyWhenDone {
+		onExit(func(code int) {
+			var err error
+			if code == 0 {
+				err = notify("WoW profile copied", fmt.Sprintf("Finished copying to %s", destination))
+			} else {
+				err = notify("WoW profile copy failed", fmt.Sprintf("Copying to %s did not finish, check the terminal for details", destination))
+			}
+			if err != nil {
+				pterm.Debug.Printfln("couldn't send notification: %s", err)
+			}
+		})
+	}
 
-	srcWtfAccountPath := filepath.Join(wow.installDirectory, srcConfig.version, "WTF", "Account", srcConfig.wtf.account)
-	dstWtfAccountPath := filepath.Join(wow.installDirectory, dstConfig.version, "WTF", "Account", dstConfig.wtf.account)
+	// anything that touches the destination from here on, other than us, means the game is probably running
+	// one watch covers every destination, since destinations on the same account share files
+	var accountPaths []string
+	for _, plan := range plans {
+		accountPaths = append(accountPaths, wow.AccountPath(plan.Destination))
+		for _, skipped := range plan.Skipped {
+			_runLog.printf(pterm.Info, plan.Destination.CharacterName(), "Skipped %s, %s", skipped.Path, skipped.Reason)
+		}
+	}
+	watch, err := copyplan.NewWatch(deduplicateStringSlice(accountPaths)...)
+	if err != nil {
+		fatal(explainFileError(err))
+	}
+	for _, copier := range copiers {
+		copier.Watch = watch
+	}
 
-	accountFilesToCopy := [3]string{"bindings-cache.wtf", "config-cache.wtf", "macros-cache.txt"}
+	// the whole destination is snapshotted, not only what's overwritten, so a bad copy can be undone completely
+	if copyOptions.Backup {
+		for i, plan := range plans {
+			if _, err := copiers[i].SnapshotTarget(wow.Install, plan.Destination); err != nil {
+				fatal(explainFileError(fmt.Errorf("backing up %s: %w", plan.Destination.CharacterName(), err)))
+			}
+		}
+	}
 
-	for _, file := range accountFilesToCopy {
-		src := filepath.Join(srcWtfAccountPath, file)
-		dst := filepath.Join(dstWtfAccountPath, file)
-		_, err := copyFile(src, dst)
-		if err != nil {
-			log.Fatal(err)
+	// what was there before, so the next run can tell the game writing back old settings apart from a normal login
+	previous := make(map[string]string)
+	for _, plan := range plans {
+		for path, hash := range previousDestinationHashes(plan) {
+			previous[path] = hash
 		}
-		pterm.Info.Printfln("Copied %s", src)
 	}
 
-	//
-	// character-level client configuration
-	//
+	log := _runLog.copyLog()
+	failed := copyplan.CopyAll(plans, copiers, log)
 
-	srcWtfCharacterPath := filepath.Join(srcWtfAccountPath, srcConfig.wtf.server, srcConfig.wtf.character)
-	dstWtfCharacterPath := filepath.Join(dstWtfAccountPath, dstConfig.wtf.server, dstConfig.wtf.character)
+	var lockedFiles []string
+	rewritten := make(map[string]bool)
+	for i, plan := range plans {
+		if failed[i] != nil {
+			continue
+		}
+		copier := copiers[i]
+		operation := plan.Destination.CharacterName()
 
-	characterFilesToCopy := [4]string{"AddOns.txt", "config-cache.wtf", "layout-local.txt", "macros-cache.txt"}
+		if err := copyplan.Finish(plan, copyplan.FirstRewrites(plan, rewritten), copyOptions, watch, log); err != nil {
+			fatal(explainFileError(err))
+		}
+		_runLog.printf(pterm.Info, operation, "WTF lua files are updated")
+		lintCopiedFiles(plan, operation)
 
-	for _, file := range characterFilesToCopy {
-		src := filepath.Join(srcWtfCharacterPath, file)
-		dst := filepath.Join(dstWtfCharacterPath, file)
-		_, err := copyFile(src, dst)
-		if err != nil {
-			log.Fatal(err)
+		// an admin copying into another OS user's install shouldn't leave files that user can't write
+		if err := matchOwnership(wow.AccountPath(plan.Destination), filepath.Join(wow.Dir, plan.Destination.Version, "WTF")); err != nil {
+			_runLog.printf(pterm.Warning, operation, "Couldn't give the copied files the same owner as the rest of the destination, run as an administrator to fix that: %s", err)
 		}
-		pterm.Info.Printfln("Copied %s", src)
-	}
 
-	//
-	// account-level saved variables
-	//
+		//
+		// clean up
+		//
+		if err := copyplan.CleanUp(plan, watch, log); err != nil {
+			fatal(explainFileError(err))
+		}
 
-	svFileRegex := regexp.MustCompile(`.*\.lua$`)
+		lockedFiles = append(lockedFiles, copier.LockedFiles...)
+		if len(copier.LockedFiles) == 0 {
+			if err := writeSyncManifest(wow, plan, previous); err != nil {
+				_runLog.printf(pterm.Warning, operation, "Couldn't record this sync for status: %s", err)
+			}
+		}
+	}
 
-	accountSavedVariablesFiles, err := os.ReadDir(filepath.Join(srcWtfAccountPath, "SavedVariables"))
+	changedFiles, err := watch.ChangedFiles()
 	if err != nil {
-		log.Fatal(err)
+		fatal(explainFileError(err))
+	}
+	if len(changedFiles) > 0 {
+		_runLog.printf(pterm.Warning, "", "These destination files were modified by another program during the copy, WoW was probably launched mid-copy:\n%s\nClose the game and run the copy again.", strings.Join(changedFiles, "\n"))
+	}
+	for i, err := range failed {
+		_runLog.printf(pterm.Error, destinations[i], "Copying failed: %s", explainFileError(err))
+	}
+	if len(lockedFiles) > 0 {
+		_runLog.printf(pterm.Error, "", "These files were locked by another program and could not be copied:\n%s\nClose any programs using them (WoW, Battle.net, antivirus scans) and run the copy again.", strings.Join(lockedFiles, "\n"))
+	} else if len(failed) == 0 {
+		_runLog.printf(pterm.Success, "", "All files copied successfully!")
+	}
+	backedUp := copyOptions.Backup
+	for _, copier := range copiers {
+		backedUp = backedUp || len(copier.ForceBackup) > 0
+	}
+	if backedUp {
+		_runLog.printf(pterm.Info, "", "Previous destination files were backed up to %s", copiers[0].BackupDirectory)
+	}
+	if _runLog.path != "" {
+		pterm.Info.Printfln("This copy's log is in %s", _runLog.path)
 	}
 
-	for _, file := range accountSavedVariablesFiles {
-		if svFileRegex.MatchString(file.Name()) {
-			src := filepath.Join(srcWtfAccountPath, "SavedVariables", file.Name())
-			dst := filepath.Join(dstWtfAccountPath, "SavedVariables", file.Name())
-			_, err := copyFile(src, dst)
-			if err != nil {
-				log.Fatal(err)
+	if len(lockedFiles) > 0 || len(failed) > 0 {
+		exit(1)
+	}
+	// stats draws its trends from these
+	if _, err := recordStats(wow); err != nil {
+		pterm.Debug.Printfln("couldn't record the sizes for stats: %s", err)
+	}
+
+	// only offered when there's a single client to start, destinations can be on several versions
+	version := plans[0].Destination.Version
+	for _, plan := range plans {
+		if plan.Destination.Version != version {
+			version = ""
+		}
+	}
+	if client := wow.gameClient(version); version != "" && client != "" && (launch || interactive && !yes) {
+		if launch || askConfirm("launch", pterm.DefaultInteractiveConfirm.
+			WithDefaultText(fmt.Sprintf("Launch %s now?", _wowInstanceFolderNames[plans[0].Destination.Version])).
+			WithDefaultValue(false)) {
+			if err := launchGameClient(client); err != nil {
+				pterm.Warning.Printfln("Couldn't start %s: %s", client, err)
 			}
-			pterm.Info.Printfln("Copied %s", src)
 		}
 	}
+	exit(0)
+}
 
-	//
-	// character-level saved variables
-	//
+// the flags copy and plan pick their source and destination with
+type targetFlags struct {
+	src           string
+	dst           stringListFlag
+	create        bool
+	srcInstallDir string
+	allUsers      bool
+}
 
-	charSavedVariablesFiles, err := os.ReadDir(filepath.Join(srcWtfCharacterPath, "SavedVariables"))
-	if err != nil {
-		log.Fatal(err)
+// several is whether --dst can be repeated, only copy writes to several destinations at once
+func (f *targetFlags) register(fs *flag.FlagSet, several bool) {
+	fs.StringVar(&f.src, "src", "", "copy from this version/account/server/character instead of prompting")
+	dstUsage := "copy to this version/account/server/character instead of prompting"
+	if several {
+		dstUsage += ", repeat it to copy to several characters at once"
 	}
+	fs.Var(&f.dst, "dst", dstUsage)
+	fs.BoolVar(&f.create, "create", false, "with --dst, create the destination's realm and character folders if they don't exist yet")
+	fs.StringVar(&f.srcInstallDir, "src-install-dir", "", "copy from the WoW install at this path, e.g. another OS user's, while --install-dir is the destination")
+	fs.BoolVar(&f.allUsers, "all-users", false, "look for WoW installs in every OS user's home folder and ask which to copy from and to")
+}
+
+// the plans from the source to every destination, and the install they write to
+// askContents is whether the contents prompt may be shown, it's asked once for every destination
+// swap, when there's a single destination in the source's install, turns the plan around for the confirmation
+func (f targetFlags) resolvePlans(s session, copyOptions copyplan.CopyOptions, planOpts copyplan.Options, preselectedContents map[string]bool, askContents bool) (WowInstall, []copyplan.Plan, func(copyplan.Plan) copyplan.Plan) {
+	// copies between OS users read from one install and write to another
+	srcInstallPath, dstInstallPath := f.srcInstallDir, s.installDir
+	if f.allUsers && s.interactive {
+		installs := findUserInstalls()
+		if len(installs) == 0 {
+			fatalf("Couldn't find a WoW install in any user's home folder, pass them with --src-install-dir and --install-dir")
+		}
+		if srcInstallPath == "" {
+			srcInstallPath = promptForUserInstall(installs, "Copy from which user's install?")
+		}
+		if dstInstallPath == "" {
+			dstInstallPath = promptForUserInstall(installs, "Copy to which user's install?")
+		}
+	}
+	wow := resolveInstall(dstInstallPath, s.config, s.interactive)
+	srcWow := wow
+	if srcInstallPath != "" && filepath.Clean(srcInstallPath) != wow.Dir {
+		srcWow = resolveInstall(srcInstallPath, s.config, s.interactive)
+		pterm.DefaultHeader.Printfln("Source WoW Install Directory: %s", srcWow.Dir)
+	}
+	pterm.DefaultHeader.Printfln("WoW Install Directory: %s", wow.Dir)
+
+	srcConfig := resolveSource(srcWow, s.expandAlias(f.src))
+	dstSpecs := []string(f.dst)
+	if len(dstSpecs) == 0 {
+		dstSpecs = []string{""}
+	}
+	var dstConfigs []wtf.Target
+	for _, dstSpec := range dstSpecs {
+		dstConfigs = append(dstConfigs, resolveDestinations(wow, s.expandAlias(dstSpec), f.create, srcWow, srcConfig, s.config, s.interactive)...)
+	}
+	// scripted runs pick with --only
+	if askContents && s.terminal && _answers == nil {
+		planOpts.Contents = promptForCopyContents(preselectedContents)
+		if len(planOpts.Contents) == 0 {
+			fatalf("Nothing was picked to copy")
+		}
+	}
+	// asked once for every destination, it's about how the source uses its macros
+	if planOpts.MacrosScope == "" && s.interactive {
+		planOpts.MacrosScope = promptForMacroScope(srcWow, srcConfig, dstConfigs)
+	}
+	var plans []copyplan.Plan
+	for _, dstConfig := range dstConfigs {
+		describeTargets(srcWow, wow, srcConfig, dstConfig)
+		plans = append(plans, resolvePlan(srcWow, wow, srcConfig, dstConfig, copyOptions, planOpts, s.interactive))
+	}
+	wow.placeTaggedFiles(plans, s.config)
+
+	// picking source and destination the wrong way round is easy, so the confirmation offers to turn it around
+	var swap func(copyplan.Plan) copyplan.Plan
+	if len(plans) == 1 && srcWow.Dir == wow.Dir {
+		swap = func(plan copyplan.Plan) copyplan.Plan {
+			describeTargets(wow, wow, plan.Destination, plan.Source)
+			return resolvePlan(wow, wow, plan.Destination, plan.Source, copyOptions, planOpts, s.interactive)
+		}
+	}
+	return wow, plans, swap
+}
 
-	for _, file := range charSavedVariablesFiles {
-		if svFileRegex.MatchString(file.Name()) {
-			src := filepath.Join(srcWtfCharacterPath, "SavedVariables", file.Name())
-			dst := filepath.Join(dstWtfCharacterPath, "SavedVariables", file.Name())
-			_, err := copyFile(src, dst)
+// copy: resolves what to copy and copies it in one go
+func copyCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	var targets targetFlags
+	targets.register(fs, true)
+	var copying copyFlags
+	copying.register(fs, "force", "no-backup", "no-verify", "normalize-text", "copy-risky", "throttle")
+	var planning planFlags
+	planning.register(fs)
+	yes := fs.Bool("yes", false, "don't ask for confirmation before overwriting")
+	notifyFlag := fs.Bool("notify", false, "raise a desktop notification when the copy finishes or fails")
+	launch := fs.Bool("launch", false, "start the destination's game client once the copy succeeds, instead of asking")
+	ifChanged := fs.Bool("if-changed", false, "skip destinations whose last copy was from the same, unchanged source files, for syncs run on a schedule")
+	dryRun := fs.Bool("dry-run", false, "show what would be copied to each destination and anything worth a second look, without writing anything")
+	detailedExitCode := fs.Bool("detailed-exitcode", false, "with --dry-run, exit with 3 when the copy would change something and 0 when the destination is already in sync")
+	return func(string) {
+		s := common.start("copy", targets.src != "" && len(targets.dst) > 0 && (*yes || *dryRun))
+		copyOptions := copying.options(s.config)
+		planOpts, preselectedContents := planning.options(s.config)
+		wow, plans, swap := targets.resolvePlans(s, copyOptions, planOpts, preselectedContents, planning.only == "" && !*yes)
+
+		if *ifChanged {
+			var err error
+			if plans, err = wow.skipSyncedPlans(plans); err != nil {
+				fatal(explainFileError(err))
+			}
+			if len(plans) == 0 {
+				pterm.Success.Println("Every destination is already in sync, nothing to copy")
+				exit(0)
+			}
+		}
+		if *dryRun {
+			changing, err := wow.printDryRun(plans, s.config)
 			if err != nil {
-				log.Fatal(err)
+				fatal(explainFileError(err))
 			}
-			pterm.Info.Printfln("Copied %s", src)
+			if *detailedExitCode && changing > 0 {
+				exit(exitCodeChangesPending)
+			}
+			exit(0)
 		}
+		executePlans(wow, plans, copyOptions, s.config, *yes, s.config.Notify || *notifyFlag, *launch, s.interactive, swap)
 	}
+}
 
-  filepath.WalkDir(dstWtfAccountPath, func(path string, d fs.DirEntry, err error) error {
-			if strings.HasSuffix(path, ".lua") {
-				fmt.Println("Processing lua file:", path)
-				data, err := os.ReadFile(path)
-				if err != nil {
-					log.Fatalln(err)
-			  }
+// plan: resolves what to copy like copy does, and writes it down instead
+func planCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	var targets targetFlags
+	targets.register(fs, false)
+	var copying copyFlags
+	copying.register(fs, "force", "no-backup", "no-verify", "normalize-text", "copy-risky", "throttle")
+	var planning planFlags
+	planning.register(fs)
+	out := fs.String("out", "", "write the plan to this file instead of stdout")
+	planVars := planfile.Variables{}
+	fs.Var(planVars, "var", "write this NAME=value as ${NAME} so the plan works for other characters too (repeatable)")
+	detailedExitCode := fs.Bool("detailed-exitcode", false, "exit with 3 when applying the plan would change something and 0 when the destination is already in sync")
+	return func(string) {
+		// a plan printed to stdout has to stay parseable
+		if *out == "" {
+			pterm.SetDefaultOutput(os.Stderr)
+		}
+		s := common.start("plan", targets.src != "" && len(targets.dst) > 0)
+		if len(targets.dst) > 1 {
+			fmt.Fprintln(os.Stderr, "plan takes a single --dst, only copy can copy to several characters at once")
+			os.Exit(2)
+		}
+		copyOptions := copying.options(s.config)
+		planOpts, preselectedContents := planning.options(s.config)
+		wow, plans, _ := targets.resolvePlans(s, copyOptions, planOpts, preselectedContents, planning.only == "")
+		if len(plans) > 1 {
+			fatalf("plan takes a single destination, only copy can copy to several characters at once")
+		}
+		plan := plans[0]
 
-				updated := bytes.ReplaceAll(data, []byte(srcConfig.wtf.character + "-" + srcConfig.wtf.server), []byte(dstConfig.wtf.character + "-" + dstConfig.wtf.server))
-				updated = bytes.ReplaceAll(data, []byte(srcConfig.wtf.character + " - " + srcConfig.wtf.server), []byte(dstConfig.wtf.character + " - " + dstConfig.wtf.server))
-				updated = bytes.ReplaceAll(data, []byte(srcConfig.wtf.server + " - " + srcConfig.wtf.character), []byte(dstConfig.wtf.server + " - " + dstConfig.wtf.account))
-				os.WriteFile(path, updated, 0666)
-		
-			}
-			return nil
-	})
-	fmt.Println("WTF lua files are updated")
-				
-	//
-	// clean up
-	//
-	dstAccountCache := filepath.Join(dstWtfAccountPath, "cache.md5")
-	err = os.Remove(dstAccountCache)
-	if err != nil {
-		if !strings.Contains(err.Error(), "no such file or directory") {
-			log.Fatal(err)
+		doc, err := newPlanDocument(wow, plan, copyOptions).Template(planVars)
+		if err != nil {
+			fatal(err)
+		}
+		if err := planfile.Write(*out, doc); err != nil {
+			fatal(explainFileError(err))
 		}
+		if *out != "" {
+			pterm.Success.Printfln("Wrote the plan (%d files, %s) to %s, run it with: wow-profile-copy apply --plan %s", len(plan.Steps), formatBytes(plan.TotalBytes()), *out, *out)
+		}
+
+		changed, err := plan.ChangedSteps()
+		if err != nil {
+			fatal(explainFileError(err))
+		}
+		if len(changed) == 0 {
+			pterm.Info.Println("The destination is already in sync, applying this plan would change nothing")
+			exit(0)
+		}
+		pterm.Info.Printfln("Applying this plan would change %d of its %d files", len(changed), len(plan.AllSteps()))
+		// lets cron jobs tell "nothing to do" apart from "something to do" without parsing the plan
+		if *detailedExitCode {
+			exit(exitCodeChangesPending)
+		}
+		exit(0)
 	}
+}
 
-	pterm.Info.Printfln("Removed %s", dstAccountCache)
+// apply and edit-plan: run a saved plan exactly as it was written, including the options it was made with,
+// edit-plan goes through its steps first
+func savedPlanCommand(command string) func(fs *flag.FlagSet, common *commonFlags) func(string) {
+	return func(fs *flag.FlagSet, common *commonFlags) func(string) {
+		planUsage := "the plan file to execute (- reads stdin)"
+		if command == "edit-plan" {
+			planUsage = "the plan file to edit"
+		}
+		planFlag := fs.String("plan", "", planUsage)
+		planVars := planfile.Variables{}
+		fs.Var(planVars, "var", "fill ${NAME} in with value (repeatable)")
+		var copying copyFlags
+		copying.register(fs, "throttle")
+		yes := fs.Bool("yes", false, "don't ask for confirmation before overwriting")
+		notifyFlag := fs.Bool("notify", false, "raise a desktop notification when the copy finishes or fails")
+		launch := fs.Bool("launch", false, "start the destination's game client once the copy succeeds, instead of asking")
+		return func(string) {
+			if command == "apply" && *planFlag == "" {
+				fmt.Fprintln(os.Stderr, "apply needs the plan to execute, pass it with --plan")
+				os.Exit(2)
+			}
+			if command == "edit-plan" && (*planFlag == "" || *planFlag == "-") {
+				fmt.Fprintln(os.Stderr, "edit-plan needs the plan file to edit, pass it with --plan")
+				os.Exit(2)
+			}
+			if common.answers == "-" && *planFlag == "-" {
+				fmt.Fprintln(os.Stderr, "--answers and --plan can't both read stdin")
+				os.Exit(2)
+			}
+			s := common.start(command, command == "apply" && *yes)
 
-	dstCharacterCache := filepath.Join(dstWtfCharacterPath, "cache.md5")
-	err = os.Remove(dstCharacterCache)
-	if err != nil {
-		if !strings.Contains(err.Error(), "no such file or directory") {
-			log.Fatal(err)
+			doc, err := readPlanDocument(*planFlag)
+			if err != nil {
+				fatal(explainFileError(err))
+			}
+			if command == "edit-plan" {
+				var apply bool
+				if doc, apply = editPlanDocument(doc, *planFlag); !apply {
+					exit(0)
+				}
+			}
+			if doc, err = fillPlanVariables(doc, planVars, s.interactive); err != nil {
+				fatal(err)
+			}
+			wow, plan, copyOptions, err := resolvePlanDocument(doc)
+			if err != nil {
+				fatal(explainFileError(err))
+			}
+			copyOptions.BytesPerSecond = copying.bytesPerSecond()
+			pterm.DefaultHeader.Printfln("WoW Install Directory: %s", wow.Dir)
+			describeTargets(WowInstall{Install: wowinstall.Install{Dir: plan.SourceInstallDirectory}}, wow, plan.Source, plan.Destination)
+			executePlans(wow, []copyplan.Plan{plan}, copyOptions, s.config, *yes, s.config.Notify || *notifyFlag, *launch, s.interactive, nil)
 		}
 	}
+}
+
+// import <code>: copies a profile someone shared onto one of ours
+func importCommand(fs *flag.FlagSet, common *commonFlags) func(string) {
+	dstFlag := fs.String("dst", "", "copy to this version/account/server/character instead of prompting")
+	create := fs.Bool("create", false, "with --dst, create the destination's realm and character folders if they don't exist yet")
+	var copying copyFlags
+	copying.register(fs, "force", "no-backup", "no-verify", "normalize-text", "copy-risky", "throttle")
+	var planning planFlags
+	planning.register(fs)
+	yes := fs.Bool("yes", false, "don't ask for confirmation before overwriting")
+	notifyFlag := fs.Bool("notify", false, "raise a desktop notification when the copy finishes or fails")
+	launch := fs.Bool("launch", false, "start the destination's game client once the copy succeeds, instead of asking")
+	return func(code string) {
+		if code == "" {
+			fmt.Fprintln(os.Stderr, "import needs the code printed by share: wow-profile-copy import <code>")
+			os.Exit(2)
+		}
+		s := common.start("import", *dstFlag != "" && *yes)
+		copyOptions := copying.options(s.config)
+		planOpts, _ := planning.options(s.config)
+
+		data, err := downloadShare(s.config.ShareURL, code)
+		if err != nil {
+			fatal(err)
+		}
+		store, manifest, err := openShareArchive(data, s.config.TrustedKeys)
+		if err != nil {
+			fatal(err)
+		}
+
+		// the share is laid out like an install, with _sharedWtf standing in for the sharer
+		shareWow := WowInstall{Install: wowinstall.Install{Versions: []string{manifest.GameVersion}, Store: store}}
+		src := wtf.Target{Character: _sharedWtf, Version: manifest.GameVersion}
+		pterm.Info.Printfln("Importing a %s profile with %d files", _wowInstanceFolderNames[manifest.GameVersion], len(manifest.Files))
 
-	pterm.Info.Printfln("Removed %s", dstCharacterCache)
-	pterm.Success.Println("All files copied successfully!")
+		wow := s.resolveInstall()
+		pterm.DefaultHeader.Printfln("WoW Install Directory: %s", wow.Dir)
+		dst := resolveDestination(wow, s.expandAlias(*dstFlag), *create)
+		plan := resolvePlan(shareWow, wow, src, dst, copyOptions, planOpts, s.interactive)
+		executePlans(wow, []copyplan.Plan{plan}, copyOptions, s.config, *yes, s.config.Notify || *notifyFlag, *launch, s.interactive, nil)
+	}
+}
 
-	if runtime.GOOS == "windows" {
-		fmt.Println("Press Enter to continue...")
-		fmt.Scanln()
+func main() {
+	// the subcommands are listed in _subcommands, copy when the first argument is a flag or there is none
+	name := "copy"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	command := findSubcommand(name)
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected %s\n", name, subcommandNames())
+		os.Exit(2)
 	}
+	command.run(args)
 }
 
 // vim: tabstop=2
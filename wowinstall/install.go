@@ -0,0 +1,131 @@
+// Package wowinstall finds WoW installs, and the game versions, accounts and characters in them
+package wowinstall
+
+import (
+	"os"
+	"path/filepath"
+
+	"wow-profile-copy/wtf"
+)
+
+// Install is a WoW install, the folder holding a version folder (_retail_, _classic_era_, ...) per client
+type Install struct {
+	Dir      string
+	Versions []string  // the version folders it has, in the order the folder lists them
+	Store    wtf.Store // where the files are read from, nil for the local disk
+}
+
+// IsInstallDirectory reports whether dir appears to contain a WoW install, a folder named like one of flavors' keys
+// (wtf.Defaults.Flavors)
+func IsInstallDirectory(dir string, flavors map[string]string) bool {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		// directory probably doesn't exist
+		return false
+	}
+
+	for _, file := range files {
+		// if this directory contains a wow instance folder name, it's probably where WoW is installed
+		if _, matchesInstanceName := flavors[file.Name()]; file.IsDir() && matchesInstanceName {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reads which versions the install in dir has, the folders named like one of flavors' keys
+func Open(dir string, flavors map[string]string) (Install, error) {
+	install := Install{Dir: dir}
+	files, err := install.Files().ReadDir(dir)
+	if err != nil {
+		return install, err
+	}
+	for _, file := range files {
+		if _, matchesInstanceName := flavors[file.Name()]; file.IsDir() && matchesInstanceName {
+			install.Versions = append(install.Versions, file.Name())
+		}
+	}
+	return install, nil
+}
+
+// Files is the store the install's files are read from, the local disk unless it was set up otherwise
+func (install Install) Files() wtf.Store {
+	if install.Store == nil {
+		return wtf.LocalStore{}
+	}
+	return install.Store
+}
+
+// HasVersion reports whether the install has version's folder
+func (install Install) HasVersion(version string) bool {
+	for _, available := range install.Versions {
+		if available == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Accounts lists every account folder of version, including ones nobody has logged a character into yet
+func (install Install) Accounts(version string) ([]string, error) {
+	var accounts []string
+	accountFiles, err := install.Files().ReadDir(filepath.Join(install.Dir, version, "WTF", "Account"))
+	if err != nil {
+		return nil, err
+	}
+	for _, acct := range accountFiles {
+		if acct.IsDir() && acct.Name() != "SavedVariables" {
+			accounts = append(accounts, acct.Name())
+		}
+	}
+	return accounts, nil
+}
+
+// Characters lists every character folder of version, on every account and realm
+func (install Install) Characters(version string) ([]wtf.Character, error) {
+	var characters []wtf.Character
+	accounts, err := install.Accounts(version)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		accountPath := filepath.Join(install.Dir, version, "WTF", "Account", account)
+		serverFiles, err := install.Files().ReadDir(accountPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range serverFiles {
+			// assume that any folder that isn't SavedVariables here is a realm
+			if !server.IsDir() || server.Name() == "SavedVariables" {
+				continue
+			}
+			characterFiles, err := install.Files().ReadDir(filepath.Join(accountPath, server.Name()))
+			if err != nil {
+				return nil, err
+			}
+			// any subdirectories of the server directories are characters, they have arbitrary names
+			for _, character := range characterFiles {
+				if character.IsDir() {
+					characters = append(characters, wtf.Character{Account: account, Server: server.Name(), Name: character.Name()})
+				}
+			}
+		}
+	}
+	return characters, nil
+}
+
+// AccountPath is WTF/Account/<account> for target
+func (install Install) AccountPath(target wtf.Target) string {
+	return filepath.Join(install.Dir, target.Version, "WTF", "Account", target.Account)
+}
+
+// CharacterPath is WTF/Account/<account>/<server>/<character> for target
+func (install Install) CharacterPath(target wtf.Target) string {
+	return filepath.Join(install.AccountPath(target), target.Server, target.Name)
+}
+
+// AddonInstalled reports whether an addon folder exists in version's Interface/AddOns
+func (install Install) AddonInstalled(version string, addon string) bool {
+	info, err := os.Stat(filepath.Join(install.Dir, version, "Interface", "AddOns", addon))
+	return err == nil && info.IsDir()
+}
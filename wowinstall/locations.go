@@ -0,0 +1,80 @@
+package wowinstall
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CommonDrivePaths are where people usually put WoW, relative to the root of a windows drive
+var CommonDrivePaths = []string{
+	"World of Warcraft",
+	filepath.Join("Program Files (x86)", "World of Warcraft"),
+	filepath.Join("Program Files", "World of Warcraft"),
+	filepath.Join("Games", "World of Warcraft"),
+	filepath.Join("Blizzard", "World of Warcraft"),
+}
+
+// ProbableLocations are likely install locations per OS (runtime.GOOS), most likely first
+// a leading ~ is the user's home directory
+var ProbableLocations = map[string][]string{
+	"darwin": {
+		"/Applications/World of Warcraft",
+		"~/Applications/World of Warcraft",
+		"~/Games/World of Warcraft",
+	},
+	"linux": {
+		"~/.var/app/com.usebottles.bottles/data/bottles/bottles/WoW/drive_c/Program Files (x86)/World of Warcraft",
+		"~/Games/world-of-warcraft/drive_c/Program Files (x86)/World of Warcraft",
+		"~/Games/battlenet/drive_c/Program Files (x86)/World of Warcraft",
+		"~/.wine/drive_c/Program Files (x86)/World of Warcraft",
+		"~/Games/World of Warcraft",
+	},
+	"windows": {
+		"~/Games/World of Warcraft",
+	},
+}
+
+// Candidates are every location worth checking for an install, in the order they should be tried
+// searchPaths come first, then this OS's ProbableLocations, then CommonDrivePaths on every one of driveRoots
+func Candidates(searchPaths []string, driveRoots []string) []string {
+	var candidates []string
+	candidates = append(candidates, searchPaths...)
+	candidates = append(candidates, ProbableLocations[runtime.GOOS]...)
+	for _, root := range driveRoots {
+		for _, path := range CommonDrivePaths {
+			candidates = append(candidates, filepath.Join(root, path))
+		}
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	seen := make(map[string]bool)
+	var unique []string
+	for _, candidate := range candidates {
+		candidate = ExpandHome(candidate, homeDir)
+		if !seen[candidate] {
+			seen[candidate] = true
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}
+
+// Find returns the first of candidates that contains a WoW install, see IsInstallDirectory
+func Find(candidates []string, flavors map[string]string) (string, bool) {
+	for _, candidate := range candidates {
+		if IsInstallDirectory(candidate, flavors) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// ExpandHome replaces a leading ~ in path with homeDir
+func ExpandHome(path string, homeDir string) string {
+	if homeDir == "" || path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
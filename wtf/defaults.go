@@ -0,0 +1,33 @@
+package wtf
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// the built-in flavor map, file lists, and exclusion lists
+// presets.json is generated from this by go generate, so the two never drift apart
+//
+//go:embed defaults.json
+var _embeddedDefaults []byte
+
+// Defaults is the data behavior is driven by, kept out of the code so it can be inspected and overridden
+type Defaults struct {
+	Flavors                             map[string]string   `json:"flavors"`
+	AccountFiles                        []string            `json:"account_files"`
+	CharacterFiles                      []string            `json:"character_files"`
+	CrossCharacterAccountSavedVariables []string            `json:"cross_character_account_saved_variables"`
+	CombatLogSavedVariables             []string            `json:"combat_log_saved_variables"`
+	SavedVariablesNamesByFlavor         []map[string]string `json:"saved_variables_names_by_flavor"`
+	CVarsByFlavor                       map[string][]string `json:"cvars_by_flavor"`
+}
+
+// BuiltinDefaults returns a fresh copy of the defaults built into the package, free to change
+func BuiltinDefaults() Defaults {
+	var defaults Defaults
+	if err := json.Unmarshal(_embeddedDefaults, &defaults); err != nil {
+		panic(fmt.Sprintf("embedded defaults.json is invalid: %s", err))
+	}
+	return defaults
+}
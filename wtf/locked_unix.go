@@ -0,0 +1,14 @@
+//go:build !windows
+
+package wtf
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsSharingViolation reports whether err means another process has the file locked, or wrote to it mid-copy
+// files are only ever advisory locked outside of windows, which OpenSourceFile reports as EWOULDBLOCK
+func IsSharingViolation(err error) bool {
+	return errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, ErrSourceChanged)
+}
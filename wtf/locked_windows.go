@@ -0,0 +1,19 @@
+//go:build windows
+
+package wtf
+
+import (
+	"errors"
+	"syscall"
+)
+
+// from winerror.h
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// IsSharingViolation reports whether err means another process currently has the file open, or wrote to it mid-copy
+func IsSharingViolation(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) || errors.Is(err, ErrSourceChanged)
+}
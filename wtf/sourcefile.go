@@ -0,0 +1,14 @@
+package wtf
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrSourceChanged is returned when a file is modified by someone else while it's being copied
+var ErrSourceChanged = errors.New("the file changed while it was being copied")
+
+// SourceChanged reports whether a file's size or modification time moved between two stats of it
+func SourceChanged(before os.FileInfo, after os.FileInfo) bool {
+	return before.Size() != after.Size() || !before.ModTime().Equal(after.ModTime())
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package wtf
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenSourceFile opens path read-only with a shared advisory lock, so a writer that also locks (like WoW under wine) is kept out
+// the lock is released when the file is closed
+func OpenSourceFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return nil, &os.PathError{Op: "lock", Path: path, Err: err}
+	}
+	return file, nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package wtf
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// OpenSourceFile opens path read-only, sharing it with other readers but not writers
+// if another process has it open for writing this fails with a sharing violation instead of reading a half-written file
+func OpenSourceFile(path string) (*os.File, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,72 @@
+package wtf
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store is somewhere an install's files are read from, so enumerating, planning, copying, and comparing
+// work the same whether the source is on the local disk or inside an archive
+// paths are OS paths, relative to the store's own root
+type Store interface {
+	ReadDir(path string) ([]fs.DirEntry, error)
+	Stat(path string) (fs.FileInfo, error)
+	Open(path string) (fs.File, error)
+}
+
+// LocalStore is the local disk, files are opened with OpenSourceFile so nothing can write them while they're copied
+type LocalStore struct{}
+
+func (LocalStore) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (LocalStore) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (LocalStore) Open(path string) (fs.File, error) {
+	file, err := OpenSourceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// ZipStore is a zip archive read in place, e.g. a downloaded share
+type ZipStore struct {
+	Archive *zip.Reader
+}
+
+// zip entries always use forward slashes, and the root is "."
+func (z ZipStore) name(path string) string {
+	if path == "" {
+		return "."
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func (z ZipStore) ReadDir(path string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(z.Archive, z.name(path))
+}
+
+func (z ZipStore) Stat(path string) (fs.FileInfo, error) {
+	return fs.Stat(z.Archive, z.name(path))
+}
+
+func (z ZipStore) Open(path string) (fs.File, error) {
+	return z.Archive.Open(z.name(path))
+}
+
+// ReadFile reads a whole file from store
+func ReadFile(store Store, path string) ([]byte, error) {
+	file, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
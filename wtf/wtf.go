@@ -0,0 +1,22 @@
+// Package wtf models what an install keeps in its WTF folders, the accounts, realms and characters whose settings,
+// keybindings, macros and SavedVariables are copied, and reads them wherever they are, on the local disk or inside
+// an archive
+package wtf
+
+// Character is a character's folder, WTF/Account/<Account>/<Server>/<Name>
+type Character struct {
+	Account string
+	Server  string
+	Name    string
+}
+
+// Target is a character of one game version, the version's folder name like _retail_ or _classic_era_
+type Target struct {
+	Character
+	Version string
+}
+
+// CharacterName is Name-Realm, how the game and most addons refer to a character
+func (c Character) CharacterName() string {
+	return c.Name + "-" + c.Server
+}